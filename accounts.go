@@ -0,0 +1,190 @@
+package gochess
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrUserExists         = errors.New("username is already taken")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+)
+
+var validUsername = regexp.MustCompile(`^[a-zA-Z0-9_-]{3,20}$`)
+
+const guestPrefix = "guest-"
+
+type User struct {
+	Username     string
+	PasswordHash []byte `json:",omitempty"`
+	Guest        bool
+	CreatedAt    time.Time
+}
+
+// UserStore persists player accounts.
+type UserStore interface {
+	CreateUser(u *User) error
+	GetUser(username string) (*User, error)
+}
+
+// Register creates an account with a hashed password.
+func Register(users UserStore, username, password string) (*User, error) {
+	if !validUsername.MatchString(username) {
+		return nil, errors.New("username must be 3 to 20 letters, digits, '-' or '_'")
+	}
+	if strings.HasPrefix(username, guestPrefix) {
+		return nil, errors.New("usernames starting with " + guestPrefix + " are reserved for guests")
+	}
+	if strings.EqualFold(username, computerName) {
+		return nil, errors.New("the username " + computerName + " is reserved for the engine")
+	}
+	if len(password) < 8 {
+		return nil, errors.New("password must be at least 8 characters")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &User{Username: username, PasswordHash: hash, CreatedAt: timeNow()}
+	if err := users.CreateUser(u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// RegisterGuest creates a throwaway account for quick play. Guests have no
+// password so they can only play for as long as their session lasts.
+func RegisterGuest(users UserStore) (*User, error) {
+	u := &User{Username: guestPrefix + newGameID()[:8], Guest: true, CreatedAt: timeNow()}
+	if err := users.CreateUser(u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// Authenticate checks the password of a registered user.
+func Authenticate(users UserStore, username, password string) (*User, error) {
+	u, err := users.GetUser(username)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Guest || bcrypt.CompareHashAndPassword(u.PasswordHash, []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return u, nil
+}
+
+type MemoryUserStore struct {
+	mu    sync.Mutex
+	users map[string]User
+}
+
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{users: map[string]User{}}
+}
+
+func (s *MemoryUserStore) CreateUser(u *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[u.Username]; ok {
+		return ErrUserExists
+	}
+	s.users[u.Username] = *u
+	return nil
+}
+
+func (s *MemoryUserStore) GetUser(username string) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[username]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return &u, nil
+}
+
+// FileUserStore keeps one JSON file per user in a directory.
+type FileUserStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func NewFileUserStore(dir string) (*FileUserStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileUserStore{dir: dir}, nil
+}
+
+func (s *FileUserStore) path(username string) (string, error) {
+	if !validUsername.MatchString(username) {
+		return "", ErrUserNotFound
+	}
+	return filepath.Join(s.dir, username+".json"), nil
+}
+
+func (s *FileUserStore) CreateUser(u *User) error {
+	path, err := s.path(u.Username)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(u, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// O_EXCL makes sure two registrations cannot claim the same name
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		return ErrUserExists
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *FileUserStore) GetUser(username string) (*User, error) {
+	path, err := s.path(username)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var u User
+	if err := json.Unmarshal(data, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
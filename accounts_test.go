@@ -0,0 +1,64 @@
+package gochess
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAccounts(t *testing.T) {
+	fileStore, err := NewFileUserStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileUserStore() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		users UserStore
+	}{
+		{name: "memory", users: NewMemoryUserStore()},
+		{name: "file", users: fileStore},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := Register(tt.users, "alice", "correct horse")
+			if err != nil {
+				t.Fatalf("Register() error = %v", err)
+			}
+			if string(u.PasswordHash) == "correct horse" {
+				t.Errorf("Expected the password to be hashed")
+			}
+
+			if _, err := Register(tt.users, "alice", "another password"); !errors.Is(err, ErrUserExists) {
+				t.Errorf("Expected %v registering a taken name, but got %v", ErrUserExists, err)
+			}
+			if _, err := Register(tt.users, "../bob", "correct horse"); err == nil {
+				t.Errorf("Expected an error registering an invalid name")
+			}
+			if _, err := Register(tt.users, "guest-bob", "correct horse"); err == nil {
+				t.Errorf("Expected an error registering a guest name")
+			}
+			if _, err := Register(tt.users, "bob", "short"); err == nil {
+				t.Errorf("Expected an error registering a short password")
+			}
+
+			if _, err := Authenticate(tt.users, "alice", "correct horse"); err != nil {
+				t.Errorf("Authenticate() error = %v", err)
+			}
+			if _, err := Authenticate(tt.users, "alice", "wrong horse"); !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("Expected %v for a wrong password, but got %v", ErrInvalidCredentials, err)
+			}
+			if _, err := Authenticate(tt.users, "nobody", "correct horse"); !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("Expected %v for an unknown user, but got %v", ErrInvalidCredentials, err)
+			}
+
+			guest, err := RegisterGuest(tt.users)
+			if err != nil {
+				t.Fatalf("RegisterGuest() error = %v", err)
+			}
+			if _, err := Authenticate(tt.users, guest.Username, ""); !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("Expected guests to not be able to log in, but got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package gochess
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// MaxAnalysisLines is the most lines Analyze looks for at once
+const MaxAnalysisLines = 10
+
+// Analyze searches for the best lines moves of the player on turn rather than
+// only the best, each with its own score and principal variation, best first.
+// Each depth searches the position once per line, leaving out the first moves
+// of the lines already found, and info is called with the lines of each depth
+// searched to the end. Unlike Search it never plays from the book.
+func (e *Engine) Analyze(g *Game, limits SearchLimits, lines int, info func([]SearchResult)) ([]SearchResult, error) {
+	if g.State != Ongoing {
+		return nil, errors.New("Game is not ongoing, got state: " + string(g.State))
+	}
+	if lines < 1 || lines > MaxAnalysisLines {
+		return nil, fmt.Errorf("the number of lines must be between 1 and %d", MaxAnalysisLines)
+	}
+	if limits.Depth == 0 && limits.Time == 0 && limits.Nodes == 0 && limits.Stop == nil {
+		limits.Depth = DefaultSearchDepth
+	}
+	if limits.Depth == 0 || limits.Depth > MaxSearchDepth {
+		limits.Depth = MaxSearchDepth
+	}
+
+	started := time.Now()
+	s := &search{engine: e, g: g.searchCopy(), limits: limits}
+	if e.TT != nil {
+		e.TT.NewSearch()
+	}
+	if limits.Time > 0 {
+		s.deadline = started.Add(limits.Time)
+	}
+
+	moves := s.g.LegalMoves()
+	if len(moves) == 0 {
+		return nil, errors.New("no legal moves")
+	}
+	if lines > len(moves) {
+		lines = len(moves)
+	}
+
+	// Some lines are always returned, even if the first iteration runs out of time
+	results := []SearchResult{}
+	for i := 0; i < lines; i++ {
+		results = append(results, SearchResult{Move: moves[i], PV: []Move{moves[i]}})
+	}
+	for depth := 1; depth <= limits.Depth; depth++ {
+		found := []SearchResult{}
+		s.excluded = []Move{}
+		for len(found) < lines {
+			// Each line starts from its move of the previous depth
+			s.pvMove = nil
+			if depth > 1 {
+				s.pvMove = &results[len(found)].Move
+			}
+			score, pv := s.negamax(depth, 0, -mateScore-1, mateScore+1)
+			if s.stopped {
+				break
+			}
+			found = append(found, SearchResult{Move: pv[0], Score: score, Depth: depth, PV: pv})
+			s.excluded = append(s.excluded, pv[0])
+		}
+		if s.stopped {
+			break
+		}
+
+		sort.SliceStable(found, func(i, j int) bool { return found[i].Score > found[j].Score })
+		setTotals(found, s.nodes, time.Now().Sub(started))
+		allMates := true
+		for _, line := range found {
+			allMates = allMates && line.IsMate()
+		}
+		results = found
+		if info != nil {
+			info(results)
+		}
+
+		// Searching deeper cannot find quicker mates
+		if allMates {
+			break
+		}
+	}
+	setTotals(results, s.nodes, time.Now().Sub(started))
+	return results, nil
+}
+
+// setTotals sets the nodes and time of the whole search on its lines.
+func setTotals(results []SearchResult, nodes int, elapsed time.Duration) {
+	for i := range results {
+		results[i].Nodes, results[i].Time = nodes, elapsed
+	}
+}
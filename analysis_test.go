@@ -0,0 +1,77 @@
+package gochess
+
+import "testing"
+
+func TestAnalyze(t *testing.T) {
+	g, err := ParseFEN("6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	depths := []int{}
+	lines, err := NewEngine().Analyze(g, SearchLimits{Depth: 3}, 3, func(lines []SearchResult) {
+		depths = append(depths, lines[0].Depth)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 lines, got %d", len(lines))
+	}
+	if got := uciMove(lines[0].Move); got != "a1a8" || lines[0].MateIn() != 1 {
+		t.Errorf("Expected mate in 1 with a1a8 first, got %s with score %d", got, lines[0].Score)
+	}
+	seen := map[string]bool{}
+	for i, line := range lines {
+		if seen[uciMove(line.Move)] {
+			t.Errorf("Expected different first moves, got %s twice", uciMove(line.Move))
+		}
+		seen[uciMove(line.Move)] = true
+		if len(line.PV) == 0 || line.PV[0] != line.Move {
+			t.Errorf("Expected line %d to start with its move, got %v", i+1, line.PV)
+		}
+		if i > 0 && line.Score > lines[i-1].Score {
+			t.Errorf("Expected the lines best first, got %d after %d", line.Score, lines[i-1].Score)
+		}
+	}
+	if len(depths) != 3 || depths[0] != 1 || depths[2] != 3 {
+		t.Errorf("Expected the lines of depths 1 to 3, got %v", depths)
+	}
+}
+
+func TestAnalyzeLimits(t *testing.T) {
+	// The king in the corner has a single move
+	g, err := ParseFEN("k7/8/8/8/8/8/8/1R5K b - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines, err := NewEngine().Analyze(g, SearchLimits{Depth: 2}, 5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 1 || uciMove(lines[0].Move) != "a8a7" {
+		t.Errorf("Expected only a8a7, got %v", lines)
+	}
+
+	if _, err := NewEngine().Analyze(g, SearchLimits{Depth: 2}, 0, nil); err == nil {
+		t.Error("Expected an error without lines")
+	}
+}
+
+func TestMateIn(t *testing.T) {
+	tests := []struct {
+		score int
+		want  int
+	}{
+		{score: 150, want: 0},
+		{score: mateScore - 1, want: 1},
+		{score: mateScore - 3, want: 2},
+		{score: -mateScore + 2, want: -1},
+		{score: -mateScore + 4, want: -2},
+	}
+	for _, test := range tests {
+		if got := (SearchResult{Score: test.score}).MateIn(); got != test.want {
+			t.Errorf("Expected mate in %d for score %d, got %d", test.want, test.score, got)
+		}
+	}
+}
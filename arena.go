@@ -0,0 +1,588 @@
+package gochess
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrArenaNotFound = errors.New("arena not found")
+
+// ArenaPlayer is a player of an arena and the points of each of their games.
+type ArenaPlayer struct {
+	Name   string
+	Rating float64
+	Points int
+	Scores []int
+	// Streak is the number of games won in a row. From the third win on,
+	// the player is on fire and scores double.
+	Streak int
+	// Playing is the game the player is in, empty while they wait
+	Playing string
+	// Paused players are not paired until they come back
+	Paused bool
+	// LastOpponent and LastColor are from the player's last game, which the
+	// pairing avoids repeating
+	LastOpponent string     `json:",omitempty"`
+	LastColor    PieceColor `json:",omitempty"`
+}
+
+// OnFire reports whether the player scores double points.
+func (p *ArenaPlayer) OnFire() bool {
+	return p.Streak >= 2
+}
+
+// ArenaGame is a game played in an arena.
+type ArenaGame struct {
+	White   string
+	Black   string
+	Berserk map[PieceColor]bool
+	Result  GameState
+}
+
+// Arena is a time-boxed tournament where players are paired again as soon as
+// their game ends. A win scores 2 points and a draw 1, doubled for players on
+// a win streak. Players who berserk play with half their time for an extra
+// point if they win.
+type Arena struct {
+	ID          string
+	Name        string
+	Organizer   string
+	TimeControl TimeControl
+	Rated       bool
+	StartsAt    time.Time
+	EndsAt      time.Time
+	Players     map[string]*ArenaPlayer
+	Games       map[string]*ArenaGame
+}
+
+func NewArena(name, organizer string, tc TimeControl, rated bool, startsAt time.Time, duration time.Duration) *Arena {
+	return &Arena{
+		ID:          newGameID(),
+		Name:        name,
+		Organizer:   organizer,
+		TimeControl: tc,
+		Rated:       rated,
+		StartsAt:    startsAt,
+		EndsAt:      startsAt.Add(duration),
+		Players:     map[string]*ArenaPlayer{},
+		Games:       map[string]*ArenaGame{},
+	}
+}
+
+// IsRunning reports whether players are being paired.
+func (a *Arena) IsRunning(now time.Time) bool {
+	return !now.Before(a.StartsAt) && now.Before(a.EndsAt)
+}
+
+// Join adds the player to the arena, or lets a paused player back in.
+func (a *Arena) Join(name string, rating float64, now time.Time) error {
+	if !now.Before(a.EndsAt) {
+		return errors.New("the arena is over")
+	}
+	if a.Rated && strings.HasPrefix(name, guestPrefix) {
+		return errors.New("guests can only play casual arenas")
+	}
+	if player, ok := a.Players[name]; ok {
+		player.Paused = false
+		return nil
+	}
+	a.Players[name] = &ArenaPlayer{Name: name, Rating: rating, Scores: []int{}}
+	return nil
+}
+
+// Pause stops pairing the player once their current game is over.
+func (a *Arena) Pause(name string) error {
+	player, ok := a.Players[name]
+	if !ok {
+		return errors.New(name + " is not playing in the arena")
+	}
+	player.Paused = true
+	return nil
+}
+
+// Waiting returns the players waiting for a game, best placed first.
+func (a *Arena) Waiting() []*ArenaPlayer {
+	waiting := []*ArenaPlayer{}
+	for _, player := range a.Players {
+		if player.Playing == "" && !player.Paused {
+			waiting = append(waiting, player)
+		}
+	}
+	sortArenaPlayers(waiting)
+	return waiting
+}
+
+// Pair matches the waiting players with the player closest to them in the
+// standings, avoiding their last opponent when someone else is waiting.
+func (a *Arena) Pair(now time.Time) [][2]string {
+	if !a.IsRunning(now) {
+		return nil
+	}
+
+	waiting := a.Waiting()
+	pairs := [][2]string{}
+	for len(waiting) >= 2 {
+		first := waiting[0]
+		next := 1
+		for i := 1; i < len(waiting); i++ {
+			if waiting[i].Name != first.LastOpponent {
+				next = i
+				break
+			}
+		}
+		second := waiting[next]
+		waiting = append(waiting[1:next], waiting[next+1:]...)
+
+		// The player who had White last gets Black
+		white, black := first.Name, second.Name
+		if first.LastColor == White || (first.LastColor == "" && second.LastColor == Black) {
+			white, black = black, white
+		}
+		pairs = append(pairs, [2]string{white, black})
+	}
+	return pairs
+}
+
+// Started records a new game between two players.
+func (a *Arena) Started(gameID, white, black string) {
+	a.Games[gameID] = &ArenaGame{White: white, Black: black, Berserk: map[PieceColor]bool{}}
+	a.Players[white].Playing = gameID
+	a.Players[black].Playing = gameID
+}
+
+// Berserk records that the player gave up half their time in the game.
+func (a *Arena) Berserk(gameID string, color PieceColor) error {
+	g, ok := a.Games[gameID]
+	if !ok {
+		return errors.New("the game is not part of the arena")
+	}
+	if g.Result != "" {
+		return errors.New("the game is over")
+	}
+	if g.Berserk[color] {
+		return errors.New("already berserk")
+	}
+	g.Berserk[color] = true
+	return nil
+}
+
+// RecordGame scores a finished arena game and puts its players back in the
+// queue. It reports whether the game belongs to the arena.
+func (a *Arena) RecordGame(g *Game) bool {
+	return a.record(g.ID, g.State)
+}
+
+func (a *Arena) record(gameID string, state GameState) bool {
+	arenaGame, ok := a.Games[gameID]
+	if !ok || arenaGame.Result != "" {
+		return ok
+	}
+	arenaGame.Result = state
+
+	for _, color := range []PieceColor{White, Black} {
+		name, opponent := arenaGame.White, arenaGame.Black
+		if color == Black {
+			name, opponent = opponent, name
+		}
+		player := a.Players[name]
+
+		score := Score(state, color)
+		points := int(2 * score)
+		if player.OnFire() {
+			points *= 2
+		}
+		if score == 1 {
+			player.Streak++
+			if arenaGame.Berserk[color] {
+				points++
+			}
+		} else {
+			player.Streak = 0
+		}
+
+		player.Points += points
+		player.Scores = append(player.Scores, points)
+		player.Playing = ""
+		player.LastOpponent = opponent
+		player.LastColor = color
+	}
+	return true
+}
+
+// Standings ranks the players by points, then by rating.
+func (a *Arena) Standings() []ArenaPlayer {
+	players := []*ArenaPlayer{}
+	for _, player := range a.Players {
+		players = append(players, player)
+	}
+	sortArenaPlayers(players)
+
+	standings := []ArenaPlayer{}
+	for _, player := range players {
+		standings = append(standings, *player)
+	}
+	return standings
+}
+
+func sortArenaPlayers(players []*ArenaPlayer) {
+	sort.Slice(players, func(i, j int) bool {
+		a, b := players[i], players[j]
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		if a.Rating != b.Rating {
+			return a.Rating > b.Rating
+		}
+		return a.Name < b.Name
+	})
+}
+
+// ArenaStore persists arenas.
+type ArenaStore interface {
+	SaveArena(a *Arena) error
+	AllArenas() ([]*Arena, error)
+}
+
+// ArenaDirector runs the arenas of the server. Results are recorded as games
+// end, and the players are paired again by Run. Arenas are saved to the store
+// after every change.
+type ArenaDirector struct {
+	NopGameListener
+
+	mu     sync.Mutex
+	store  ArenaStore
+	arenas map[string]*Arena
+	// matched holds the games created for players, until they pick them up
+	matched map[string]string
+	wake    chan struct{}
+	// While games are being created, results of games that are not known
+	// yet are kept in case they belong to an arena
+	pairing   int
+	unclaimed map[string]GameState
+}
+
+func NewArenaDirector(store ArenaStore) *ArenaDirector {
+	return &ArenaDirector{
+		store:     store,
+		arenas:    map[string]*Arena{},
+		matched:   map[string]string{},
+		wake:      make(chan struct{}, 1),
+		unclaimed: map[string]GameState{},
+	}
+}
+
+func (d *ArenaDirector) Create(a *Arena) error {
+	if !a.EndsAt.After(a.StartsAt) {
+		return errors.New("the arena needs a duration")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.store.SaveArena(a); err != nil {
+		return err
+	}
+	d.arenas[a.ID] = a
+	return nil
+}
+
+// Load reads the arenas saved in the store.
+func (d *ArenaDirector) Load() error {
+	all, err := d.store.AllArenas()
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, a := range all {
+		for _, player := range a.Players {
+			// Seats held for a game that was never created are given back
+			if player.Playing == "pairing" {
+				player.Playing = ""
+			}
+		}
+		d.arenas[a.ID] = a
+	}
+	return nil
+}
+
+// Arena runs fn with the arena while holding the director's lock. Changes to
+// the arena are made with Update instead.
+func (d *ArenaDirector) Arena(id string, fn func(a *Arena) error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	a, ok := d.arenas[id]
+	if !ok {
+		return ErrArenaNotFound
+	}
+	return fn(a)
+}
+
+// Update runs fn with the arena while holding the director's lock, and saves
+// the arena if fn succeeds.
+func (d *ArenaDirector) Update(id string, fn func(a *Arena) error) error {
+	return d.Arena(id, func(a *Arena) error {
+		if err := fn(a); err != nil {
+			return err
+		}
+		return d.store.SaveArena(a)
+	})
+}
+
+// Arenas returns all arenas, the next to start first.
+func (d *ArenaDirector) Arenas() []*Arena {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	list := []*Arena{}
+	for _, a := range d.arenas {
+		list = append(list, a)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].StartsAt.Before(list[j].StartsAt) })
+	return list
+}
+
+// Matched returns the game the player was last paired into, once.
+func (d *ArenaDirector) Matched(username string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	id := d.matched[username]
+	delete(d.matched, username)
+	return id
+}
+
+// PairWaiting pairs the waiting players of every running arena and creates
+// their games with pair.
+func (d *ArenaDirector) PairWaiting(pair PairFunc) error {
+	type arenaPairs struct {
+		arena *Arena
+		pairs [][2]string
+	}
+
+	d.mu.Lock()
+	now := timeNow()
+	pending := []arenaPairs{}
+	for _, a := range d.arenas {
+		pairs := a.Pair(now)
+		for _, p := range pairs {
+			// Hold the seats while the game is being created
+			a.Players[p[0]].Playing = "pairing"
+			a.Players[p[1]].Playing = "pairing"
+		}
+		if len(pairs) > 0 {
+			pending = append(pending, arenaPairs{a, pairs})
+		}
+	}
+	d.pairing++
+	d.mu.Unlock()
+
+	// The games are created without holding the lock, as the results of
+	// games come in while the games are locked
+	var pairErr error
+	for _, p := range pending {
+		for _, players := range p.pairs {
+			var g *Game
+			if pairErr == nil {
+				g, pairErr = pair(players[0], players[1], p.arena.TimeControl, p.arena.Rated)
+			}
+
+			d.mu.Lock()
+			if g == nil {
+				p.arena.Players[players[0]].Playing = ""
+				p.arena.Players[players[1]].Playing = ""
+			} else {
+				p.arena.Started(g.ID, players[0], players[1])
+				d.matched[players[0]] = g.ID
+				d.matched[players[1]] = g.ID
+				if state, ok := d.unclaimed[g.ID]; ok {
+					// The game ended before it was known to be part of the arena
+					p.arena.record(g.ID, state)
+				}
+				if err := d.store.SaveArena(p.arena); err != nil {
+					log.Println("Failed to save arena", p.arena.ID, err)
+				}
+			}
+			d.mu.Unlock()
+		}
+	}
+
+	d.mu.Lock()
+	d.pairing--
+	if d.pairing == 0 {
+		d.unclaimed = map[string]GameState{}
+	}
+	d.mu.Unlock()
+	return pairErr
+}
+
+// Run pairs waiting players whenever an arena game ends, and on every tick
+// for players who have just joined.
+func (d *ArenaDirector) Run(pair PairFunc, tick <-chan time.Time) {
+	for {
+		select {
+		case <-d.wake:
+		case <-tick:
+		}
+		if err := d.PairWaiting(pair); err != nil {
+			log.Println("Failed to pair arena players", err)
+		}
+	}
+}
+
+// OnGameOver scores the game in the arena it belongs to and wakes up the
+// pairing.
+func (d *ArenaDirector) OnGameOver(g *Game, state GameState) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, a := range d.arenas {
+		if a.RecordGame(g) {
+			if err := d.store.SaveArena(a); err != nil {
+				log.Println("Failed to save arena", a.ID, err)
+			}
+			select {
+			case d.wake <- struct{}{}:
+			default:
+			}
+			return
+		}
+	}
+	if d.pairing > 0 {
+		d.unclaimed[g.ID] = state
+	}
+}
+
+// GameArena returns the arena a game is played in, if any.
+func (d *ArenaDirector) GameArena(gameID string) (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, a := range d.arenas {
+		if _, ok := a.Games[gameID]; ok {
+			return a.ID, true
+		}
+	}
+	return "", false
+}
+
+// Berserk records that the player gave up half their time in an arena game.
+func (d *ArenaDirector) Berserk(gameID string, color PieceColor) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, a := range d.arenas {
+		if _, ok := a.Games[gameID]; ok {
+			if err := a.Berserk(gameID, color); err != nil {
+				return err
+			}
+			return d.store.SaveArena(a)
+		}
+	}
+	return errors.New("only arena games can go berserk")
+}
+
+type MemoryArenaStore struct {
+	mu     sync.Mutex
+	arenas map[string][]byte
+}
+
+func NewMemoryArenaStore() *MemoryArenaStore {
+	return &MemoryArenaStore{arenas: map[string][]byte{}}
+}
+
+func (s *MemoryArenaStore) SaveArena(a *Arena) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.arenas[a.ID] = data
+	return nil
+}
+
+func (s *MemoryArenaStore) AllArenas() ([]*Arena, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all := []*Arena{}
+	for _, data := range s.arenas {
+		var a Arena
+		if err := json.Unmarshal(data, &a); err != nil {
+			return nil, err
+		}
+		all = append(all, &a)
+	}
+	return all, nil
+}
+
+// FileArenaStore keeps one JSON file per arena in a directory.
+type FileArenaStore struct {
+	dir string
+}
+
+func NewFileArenaStore(dir string) (*FileArenaStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileArenaStore{dir: dir}, nil
+}
+
+func (s *FileArenaStore) path(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return "", errors.New("invalid arena ID: " + id)
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+func (s *FileArenaStore) SaveArena(a *Arena) error {
+	path, err := s.path(a.ID)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *FileArenaStore) AllArenas() ([]*Arena, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	all := []*Arena{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, name))
+		if err != nil {
+			return nil, err
+		}
+		var a Arena
+		if err := json.Unmarshal(data, &a); err != nil {
+			return nil, err
+		}
+		all = append(all, &a)
+	}
+	return all, nil
+}
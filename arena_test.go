@@ -0,0 +1,155 @@
+package gochess
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestArena(t *testing.T, players ...string) (*Arena, time.Time) {
+	t.Helper()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	a := NewArena("Hourly blitz", "alice", TimeControl{Initial: 3 * time.Minute}, false, now, time.Hour)
+	for i, name := range players {
+		if err := a.Join(name, float64(2000-i), now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return a, now
+}
+
+// finishArenaGame starts a game between two arena players and ends it.
+func finishArenaGame(a *Arena, id, white, black string, state GameState) {
+	a.Started(id, white, black)
+	g := NewGame(white, black)
+	g.ID = id
+	g.State = state
+	a.RecordGame(g)
+}
+
+func TestArenaScoring(t *testing.T) {
+	a, _ := newTestArena(t, "alice", "bob")
+
+	// Two wins in a row put alice on fire, so the third scores double
+	finishArenaGame(a, "1", "alice", "bob", WhiteWon)
+	finishArenaGame(a, "2", "bob", "alice", Draw)
+	finishArenaGame(a, "3", "alice", "bob", WhiteWon)
+	finishArenaGame(a, "4", "bob", "alice", BlackWon)
+	a.Started("5", "alice", "bob")
+	if err := a.Berserk("5", White); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Berserk("5", White); err == nil {
+		t.Errorf("Expected a second berserk to fail")
+	}
+	a.RecordGame(&Game{ID: "5", State: WhiteWon})
+	finishArenaGame(a, "6", "bob", "alice", WhiteWon)
+
+	alice := a.Players["alice"]
+	expected := []int{2, 1, 2, 2, 5, 0}
+	for i, points := range expected {
+		if alice.Scores[i] != points {
+			t.Errorf("Expected game %d to score %d, but got %v", i+1, points, alice.Scores)
+			break
+		}
+	}
+	if alice.Points != 12 || alice.Streak != 0 || a.Players["bob"].Points != 3 {
+		t.Errorf("Expected alice on 12 and bob on 3, but got %+v and %+v", alice, a.Players["bob"])
+	}
+	if standings := a.Standings(); standings[0].Name != "alice" {
+		t.Errorf("Expected alice to lead, but got %+v", standings)
+	}
+}
+
+func TestArenaPair(t *testing.T) {
+	a, now := newTestArena(t, "alice", "bob", "carol", "dave", "erin")
+
+	if pairs := a.Pair(now.Add(-time.Minute)); len(pairs) != 0 {
+		t.Errorf("Expected no pairings before the start, but got %v", pairs)
+	}
+
+	pairs := a.Pair(now)
+	if len(pairs) != 2 || pairs[0] != [2]string{"alice", "bob"} || pairs[1] != [2]string{"carol", "dave"} {
+		t.Fatalf("Expected neighbours in the standings to be paired, but got %v", pairs)
+	}
+	for i, pair := range pairs {
+		a.Started(string(rune('1'+i)), pair[0], pair[1])
+	}
+
+	// alice and bob finish first, and only erin is left to avoid a rematch
+	a.RecordGame(&Game{ID: "1", State: Draw})
+	pairs = a.Pair(now)
+	if len(pairs) != 1 || pairs[0] != [2]string{"erin", "alice"} {
+		t.Errorf("Expected alice to play erin with Black, but got %v", pairs)
+	}
+
+	a.Pause("bob")
+	if err := a.Join("frank", 1500, now.Add(2*time.Hour)); err == nil {
+		t.Errorf("Expected no one to join after the end")
+	}
+	if pairs := a.Pair(now.Add(2 * time.Hour)); len(pairs) != 0 {
+		t.Errorf("Expected no pairings after the end, but got %v", pairs)
+	}
+}
+
+func TestArenaDirectorRepairs(t *testing.T) {
+	fileStore, err := NewFileArenaStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		store ArenaStore
+	}{
+		{name: "memory", store: NewMemoryArenaStore()},
+		{name: "file", store: fileStore},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, now := newTestArena(t, "alice", "bob")
+			timeNow = func() time.Time { return now }
+			defer func() { timeNow = time.Now }()
+
+			director := NewArenaDirector(tt.store)
+			if err := director.Create(a); err != nil {
+				t.Fatal(err)
+			}
+
+			var created []*Game
+			pair := func(white, black string, tc TimeControl, rated bool) (*Game, error) {
+				g := NewGame(white, black)
+				g.AddListener(director)
+				created = append(created, g)
+				return g, nil
+			}
+
+			if err := director.PairWaiting(pair); err != nil {
+				t.Fatal(err)
+			}
+			if len(created) != 1 || director.Matched("bob") != created[0].ID {
+				t.Fatalf("Expected a game for alice and bob, but got %v", created)
+			}
+
+			created[0].Resign(White)
+			select {
+			case <-director.wake:
+			default:
+				t.Errorf("Expected the end of the game to wake up the pairing")
+			}
+
+			// The arena carries on from where it was after a restart
+			director = NewArenaDirector(tt.store)
+			if err := director.Load(); err != nil {
+				t.Fatal(err)
+			}
+			if standings := director.Arenas()[0].Standings(); standings[0].Name != "bob" || standings[0].Points != 2 || standings[0].LastOpponent != "alice" || standings[0].LastColor != Black {
+				t.Errorf("Expected bob to lead with 2 points after playing Black against alice, but got %+v", standings)
+			}
+			director.PairWaiting(pair)
+			if len(created) != 2 || created[1].Players[0].Name != "bob" {
+				t.Errorf("Expected bob to get White in the next game, but got %v", created[len(created)-1].Players)
+			}
+		})
+	}
+}
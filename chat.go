@@ -0,0 +1,216 @@
+package gochess
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ChatRoom string
+
+const (
+	// PlayersRoom is where the players talk, spectators can read along
+	PlayersRoom ChatRoom = "players"
+	// SpectatorsRoom is only seen by spectators, so they cannot help the players
+	SpectatorsRoom ChatRoom = "spectators"
+)
+
+var (
+	ErrMuted       = errors.New("you have been muted in this game")
+	ErrRateLimited = errors.New("you are sending messages too quickly")
+	ErrMuteSelf    = errors.New("you cannot mute or unmute yourself")
+	ErrNotMuter    = errors.New("only the player who muted a user can unmute them")
+)
+
+type ChatMessage struct {
+	Room     ChatRoom
+	Username string
+	Text     string
+	Time     time.Time
+}
+
+// ChatMute records that a player muted a user in the chats of a game.
+type ChatMute struct {
+	Username string
+	By       string
+}
+
+// ChatFilter inspects a message before it is posted. It can rewrite the
+// text, or reject the message by returning an error.
+type ChatFilter func(msg *ChatMessage) error
+
+// MaxLengthFilter rejects empty messages and messages longer than max characters.
+func MaxLengthFilter(max int) ChatFilter {
+	return func(msg *ChatMessage) error {
+		msg.Text = strings.TrimSpace(msg.Text)
+		if msg.Text == "" {
+			return errors.New("message is empty")
+		}
+		if len([]rune(msg.Text)) > max {
+			return errors.New("message is too long")
+		}
+		return nil
+	}
+}
+
+// WordFilter masks the given words, ignoring case.
+func WordFilter(words ...string) ChatFilter {
+	if len(words) == 0 {
+		return func(msg *ChatMessage) error { return nil }
+	}
+
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = regexp.QuoteMeta(word)
+	}
+	pattern := regexp.MustCompile(`(?i)\b(` + strings.Join(quoted, "|") + `)\b`)
+
+	return func(msg *ChatMessage) error {
+		msg.Text = pattern.ReplaceAllStringFunc(msg.Text, func(word string) string {
+			return strings.Repeat("*", len([]rune(word)))
+		})
+		return nil
+	}
+}
+
+// Chat moderates the messages posted in game chats. It runs the filters,
+// limits how often each user can post and keeps track of muted users.
+type Chat struct {
+	mu      sync.Mutex
+	filters []ChatFilter
+	// Users can post at most rateLimit messages per ratePeriod
+	rateLimit  int
+	ratePeriod time.Duration
+	recent     map[string][]time.Time
+	// muted maps each game to its muted users and who muted them
+	muted map[string]map[string]string
+}
+
+func NewChat(rateLimit int, ratePeriod time.Duration, filters ...ChatFilter) *Chat {
+	return &Chat{
+		filters:    filters,
+		rateLimit:  rateLimit,
+		ratePeriod: ratePeriod,
+		recent:     map[string][]time.Time{},
+		muted:      map[string]map[string]string{},
+	}
+}
+
+// Moderate checks a message posted in a game and applies the filters to it.
+func (c *Chat) Moderate(gameID string, msg *ChatMessage) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.muted[gameID][msg.Username]; ok {
+		return ErrMuted
+	}
+
+	// Only keep the messages sent within the rate period
+	now := timeNow()
+	recent := c.recent[msg.Username][:0]
+	for _, sent := range c.recent[msg.Username] {
+		if now.Sub(sent) < c.ratePeriod {
+			recent = append(recent, sent)
+		}
+	}
+	c.recent[msg.Username] = recent
+	if len(recent) >= c.rateLimit {
+		return ErrRateLimited
+	}
+
+	for _, filter := range c.filters {
+		if err := filter(msg); err != nil {
+			return err
+		}
+	}
+
+	msg.Time = now
+	c.recent[msg.Username] = append(recent, now)
+	return nil
+}
+
+// Mute stops a user from posting in the chat of a game. Only the player who
+// muted a user can unmute them again.
+func (c *Chat) Mute(gameID string, mute ChatMute) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if mute.Username == mute.By {
+		return ErrMuteSelf
+	}
+	if _, ok := c.muted[gameID][mute.Username]; ok {
+		return errors.New(mute.Username + " is already muted")
+	}
+	if c.muted[gameID] == nil {
+		c.muted[gameID] = map[string]string{}
+	}
+	c.muted[gameID][mute.Username] = mute.By
+	return nil
+}
+
+// Unmute lets a muted user post in the chat of a game again.
+func (c *Chat) Unmute(gameID string, mute ChatMute) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if mute.Username == mute.By {
+		return ErrMuteSelf
+	}
+	by, ok := c.muted[gameID][mute.Username]
+	if !ok {
+		return errors.New(mute.Username + " is not muted")
+	}
+	if by != mute.By {
+		return ErrNotMuter
+	}
+	delete(c.muted[gameID], mute.Username)
+	return nil
+}
+
+// IsMuted reports whether a user is muted in the chat of a game.
+func (c *Chat) IsMuted(gameID, username string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.muted[gameID][username]
+	return ok
+}
+
+// RestoreMutes sets the mutes of a game from its events, so they survive a
+// restart of the server.
+func (c *Chat) RestoreMutes(gameID string, events []GameEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	muted := map[string]string{}
+	for _, event := range events {
+		if event.Mute == nil {
+			continue
+		}
+		switch event.Type {
+		case EventMuted:
+			muted[event.Mute.Username] = event.Mute.By
+		case EventUnmuted:
+			delete(muted, event.Mute.Username)
+		}
+	}
+	c.muted[gameID] = muted
+}
+
+// ChatMessages returns the messages of a room from the events of a game,
+// leaving out those posted after the cutoff if it is not zero.
+func ChatMessages(events []GameEvent, room ChatRoom, cutoff time.Time) []ChatMessage {
+	messages := []ChatMessage{}
+	for _, event := range events {
+		if event.Type != EventChat || event.Chat == nil || event.Chat.Room != room {
+			continue
+		}
+		if !cutoff.IsZero() && event.Chat.Time.After(cutoff) {
+			continue
+		}
+		messages = append(messages, *event.Chat)
+	}
+	return messages
+}
@@ -0,0 +1,115 @@
+package gochess
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChatFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  ChatFilter
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{name: "trims spaces", filter: MaxLengthFilter(10), text: "  hello  ", want: "hello"},
+		{name: "rejects empty", filter: MaxLengthFilter(10), text: "   ", wantErr: true},
+		{name: "rejects long", filter: MaxLengthFilter(10), text: "hello there world", wantErr: true},
+		{name: "masks words", filter: WordFilter("darn", "heck"), text: "Darn it, what the heck", want: "**** it, what the ****"},
+		{name: "only whole words", filter: WordFilter("heck"), text: "checkmate", want: "checkmate"},
+		{name: "no words", filter: WordFilter(), text: "hello", want: "hello"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &ChatMessage{Text: tt.text}
+			err := tt.filter(msg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("filter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && msg.Text != tt.want {
+				t.Errorf("filter() text = %q, want %q", msg.Text, tt.want)
+			}
+		})
+	}
+}
+
+func TestChatModerate(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	c := NewChat(2, 10*time.Second, MaxLengthFilter(10))
+
+	for i := 0; i < 2; i++ {
+		if err := c.Moderate("game", &ChatMessage{Username: "alice", Text: "hi"}); err != nil {
+			t.Fatalf("Moderate() error = %v", err)
+		}
+	}
+	if err := c.Moderate("game", &ChatMessage{Username: "alice", Text: "hi"}); err != ErrRateLimited {
+		t.Errorf("Expected %v, but got %v", ErrRateLimited, err)
+	}
+	if err := c.Moderate("game", &ChatMessage{Username: "bob", Text: "hi"}); err != nil {
+		t.Errorf("Expected other users to not be limited, but got %v", err)
+	}
+
+	now = now.Add(10 * time.Second)
+	msg := &ChatMessage{Username: "alice", Text: "hi again"}
+	if err := c.Moderate("game", msg); err != nil {
+		t.Errorf("Expected Alice to be able to post after the rate period, but got %v", err)
+	}
+	if !msg.Time.Equal(now) {
+		t.Errorf("Expected the message to be stamped with %v, but got %v", now, msg.Time)
+	}
+
+	if err := c.Mute("game", ChatMute{Username: "bob", By: "bob"}); err != ErrMuteSelf {
+		t.Errorf("Expected %v, but got %v", ErrMuteSelf, err)
+	}
+	if err := c.Mute("game", ChatMute{Username: "bob", By: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Moderate("game", &ChatMessage{Username: "bob", Text: "hi"}); err != ErrMuted {
+		t.Errorf("Expected %v, but got %v", ErrMuted, err)
+	}
+	if err := c.Moderate("other", &ChatMessage{Username: "bob", Text: "hi"}); err != nil {
+		t.Errorf("Expected Bob to only be muted in one game, but got %v", err)
+	}
+	if err := c.Unmute("game", ChatMute{Username: "bob", By: "bob"}); err != ErrMuteSelf {
+		t.Errorf("Expected %v, but got %v", ErrMuteSelf, err)
+	}
+	if err := c.Unmute("game", ChatMute{Username: "bob", By: "carol"}); err != ErrNotMuter {
+		t.Errorf("Expected %v, but got %v", ErrNotMuter, err)
+	}
+	if err := c.Unmute("game", ChatMute{Username: "bob", By: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if c.IsMuted("game", "bob") {
+		t.Errorf("Expected Bob to be unmuted")
+	}
+}
+
+func TestChatMessages(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	events := []GameEvent{
+		{Type: EventCreated},
+		{Type: EventChat, Chat: &ChatMessage{Room: PlayersRoom, Username: "alice", Text: "good luck", Time: start}},
+		{Type: EventChat, Chat: &ChatMessage{Room: SpectatorsRoom, Username: "eve", Text: "hello", Time: start}},
+		{Type: EventChat, Chat: &ChatMessage{Room: PlayersRoom, Username: "bob", Text: "you too", Time: start.Add(time.Minute)}},
+	}
+
+	if got := ChatMessages(events, PlayersRoom, time.Time{}); len(got) != 2 || got[1].Text != "you too" {
+		t.Errorf("Expected both player messages, but got %v", got)
+	}
+	if got := ChatMessages(events, PlayersRoom, start); len(got) != 1 || got[0].Text != "good luck" {
+		t.Errorf("Expected only the first player message, but got %v", got)
+	}
+	if got := ChatMessages(events, SpectatorsRoom, time.Time{}); len(got) != 1 || got[0].Username != "eve" {
+		t.Errorf("Expected the spectator message, but got %v", got)
+	}
+
+	// Chat messages do not get in the way of replaying the game
+	if _, err := GameLog(append([]GameEvent{CreatedEvent(NewGame("alice", "bob"))}, events[1:]...)).Replay(); err != nil {
+		t.Errorf("Replay() error = %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package gochess
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Chess960Positions is the number of starting positions of Chess960
+const Chess960Positions = 960
+
+// chess960Knights are the squares the knights take among the five left once
+// the bishops and the queen are placed, by the last digit of a position
+var chess960Knights = [10][2]int{{0, 1}, {0, 2}, {0, 3}, {0, 4}, {1, 2}, {1, 3}, {1, 4}, {2, 3}, {2, 4}, {3, 4}}
+
+// Chess960BackRank returns the back rank of a Chess960 starting position,
+// numbered from 0 to 959 like Scharnagl does, where 518 is the standard
+// starting position. The bishops stand on squares of opposite colours and
+// the king between the rooks.
+func Chess960BackRank(id int) ([8]PieceType, error) {
+	var rank [8]PieceType
+	if id < 0 || id >= Chess960Positions {
+		return rank, fmt.Errorf("Chess960 positions are numbered from 0 to %d, got %d", Chess960Positions-1, id)
+	}
+
+	// One bishop on the light squares b, d, f and h, the other on the dark
+	// squares a, c, e and g
+	rank[2*(id%4)+1] = Bishop
+	id /= 4
+	rank[2*(id%4)] = Bishop
+	id /= 4
+
+	// The others go on the squares left, counted from the a-file
+	place := func(piece PieceType, n int) {
+		for x := range rank {
+			if rank[x] != "" {
+				continue
+			}
+			if n == 0 {
+				rank[x] = piece
+				return
+			}
+			n--
+		}
+	}
+	place(Queen, id%6)
+	id /= 6
+	knights := chess960Knights[id]
+	place(Knight, knights[1])
+	place(Knight, knights[0])
+	place(Rook, 0)
+	place(King, 0)
+	place(Rook, 0)
+	return rank, nil
+}
+
+// NewGame960 sets up a Chess960 game from the numbered starting position.
+// Both players have the same back rank.
+func NewGame960(id int) (*Game, error) {
+	rank, err := Chess960BackRank(id)
+	if err != nil {
+		return nil, err
+	}
+	g := NewGame("", "")
+	rooks := []int{}
+	for x, pieceType := range rank {
+		g.Board[x][0] = &Piece{Type: pieceType, Color: White}
+		g.Board[x][7] = &Piece{Type: pieceType, Color: Black}
+		if pieceType == Rook {
+			rooks = append(rooks, x)
+		}
+	}
+	g.CastlingRooks = [4]int{rooks[1], rooks[0], rooks[1], rooks[0]}
+	g.Chess960 = true
+	return g, nil
+}
+
+// NewRandomGame960 sets up a Chess960 game from a starting position picked
+// at random.
+func NewRandomGame960() *Game {
+	g, _ := NewGame960(rand.Intn(Chess960Positions))
+	return g
+}
@@ -0,0 +1,213 @@
+package gochess
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChess960BackRank(t *testing.T) {
+	seen := map[[8]PieceType]int{}
+	for id := 0; id < Chess960Positions; id++ {
+		rank, err := Chess960BackRank(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if other, ok := seen[rank]; ok {
+			t.Fatalf("Expected positions %d and %d to differ, both are %v", other, id, rank)
+		}
+		seen[rank] = id
+
+		bishops, rooks, king := []int{}, []int{}, -1
+		for x, piece := range rank {
+			switch piece {
+			case Bishop:
+				bishops = append(bishops, x)
+			case Rook:
+				rooks = append(rooks, x)
+			case King:
+				king = x
+			}
+		}
+		if len(bishops) != 2 || bishops[0]%2 == bishops[1]%2 {
+			t.Errorf("Expected the bishops of position %d on opposite colours, got %v", id, rank)
+		}
+		if len(rooks) != 2 || king < rooks[0] || king > rooks[1] {
+			t.Errorf("Expected the king of position %d between the rooks, got %v", id, rank)
+		}
+	}
+
+	for id, want := range map[int]string{0: "BBQNNRKR", 518: "RNBQKBNR", 959: "RKRNNQBB"} {
+		rank, _ := Chess960BackRank(id)
+		got := ""
+		for _, piece := range rank {
+			got += strings.ToUpper(string(pieceLetters[piece]))
+		}
+		if got != want {
+			t.Errorf("Chess960BackRank(%d) = %s, want %s", id, got, want)
+		}
+	}
+
+	for _, id := range []int{-1, 960} {
+		if _, err := Chess960BackRank(id); err == nil {
+			t.Errorf("Expected no position %d", id)
+		}
+	}
+}
+
+func TestNewGame960(t *testing.T) {
+	tests := []struct {
+		id       int
+		fen      string
+		shredder string
+	}{
+		{id: 518, fen: StartFEN, shredder: "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w HAha - 0 1"},
+		{id: 0, fen: "bbqnnrkr/pppppppp/8/8/8/8/PPPPPPPP/BBQNNRKR w KQkq - 0 1", shredder: "bbqnnrkr/pppppppp/8/8/8/8/PPPPPPPP/BBQNNRKR w HFhf - 0 1"},
+	}
+	for _, test := range tests {
+		g, err := NewGame960(test.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !g.Chess960 || g.FEN() != test.fen || g.ShredderFEN() != test.shredder {
+			t.Errorf("Expected position %d to be %s and %s, got %s and %s", test.id, test.fen, test.shredder, g.FEN(), g.ShredderFEN())
+		}
+		parsed, err := ParseFEN(test.shredder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.Hash() != g.Hash() || parsed.CastlingRooks != g.CastlingRooks {
+			t.Errorf("Expected the Shredder-FEN of position %d to be read back, got %s", test.id, parsed.ShredderFEN())
+		}
+	}
+
+	if g := NewRandomGame960(); !g.Chess960 || len(g.LegalMoves()) == 0 {
+		t.Errorf("Expected a random Chess960 game, got %s", g.FEN())
+	}
+}
+
+func TestChess960FEN(t *testing.T) {
+	tests := []struct {
+		name     string
+		fen      string
+		chess960 bool
+		xfen     string
+		shredder string
+	}{
+		{name: "standard", fen: StartFEN, xfen: StartFEN, shredder: "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w HAha - 0 1"},
+		{name: "X-FEN", fen: "4k3/8/8/8/8/8/8/RK5R w KQ - 0 1", chess960: true, xfen: "4k3/8/8/8/8/8/8/RK5R w KQ - 0 1", shredder: "4k3/8/8/8/8/8/8/RK5R w HA - 0 1"},
+		{name: "Shredder-FEN", fen: "4k3/8/8/8/8/8/8/RK5R w HA - 0 1", chess960: true, xfen: "4k3/8/8/8/8/8/8/RK5R w KQ - 0 1", shredder: "4k3/8/8/8/8/8/8/RK5R w HA - 0 1"},
+		{name: "inner rook", fen: "4k3/8/8/8/8/8/8/1K1R3R w D - 0 1", chess960: true, xfen: "4k3/8/8/8/8/8/8/1K1R3R w D - 0 1", shredder: "4k3/8/8/8/8/8/8/1K1R3R w D - 0 1"},
+		{name: "outer rook", fen: "4k3/8/8/8/8/8/8/1K1R3R w K - 0 1", chess960: true, xfen: "4k3/8/8/8/8/8/8/1K1R3R w K - 0 1", shredder: "4k3/8/8/8/8/8/8/1K1R3R w H - 0 1"},
+		{name: "no rook", fen: "4k3/8/8/8/8/8/8/1K6 w KQ - 0 1", xfen: "4k3/8/8/8/8/8/8/1K6 w - - 0 1", shredder: "4k3/8/8/8/8/8/8/1K6 w - - 0 1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g, err := ParseFEN(test.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if g.Chess960 != test.chess960 || g.FEN() != test.xfen || g.ShredderFEN() != test.shredder {
+				t.Errorf("Expected Chess960 %v, %s and %s, got %v, %s and %s", test.chess960, test.xfen, test.shredder, g.Chess960, g.FEN(), g.ShredderFEN())
+			}
+		})
+	}
+}
+
+func TestChess960Castling(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		move string
+		// want is the position after castling, empty when it is illegal
+		want string
+		san  string
+	}{
+		{name: "king stays", fen: "4k3/8/8/8/8/8/8/6KR w K - 0 1", move: "g1h1", want: "4k3/8/8/8/8/8/8/5RK1 b - - 1 1", san: "O-O"},
+		{name: "queenside", fen: "4k3/8/8/8/8/8/8/RK6 w Q - 0 1", move: "b1a1", want: "4k3/8/8/8/8/8/8/2KR4 b - - 1 1", san: "O-O-O"},
+		{name: "king onto the rook's square", fen: "4k3/8/8/8/8/8/8/5KR1 w K - 0 1", move: "f1g1", want: "4k3/8/8/8/8/8/8/5RK1 b - - 1 1", san: "O-O"},
+		{name: "black", fen: "rk6/8/8/8/8/8/8/4K3 b q - 0 1", move: "b8a8", want: "2kr4/8/8/8/8/8/8/4K3 w - - 1 2", san: "O-O-O"},
+		{name: "rook's path blocked", fen: "4k3/8/8/8/8/8/8/RK1N4 w Q - 0 1", move: "b1a1"},
+		{name: "through check", fen: "4r1k1/8/8/8/8/8/8/1K5R w K - 0 1", move: "b1h1"},
+		{name: "out of check", fen: "k7/8/8/8/8/8/8/4r1KR w H - 0 1", move: "g1h1"},
+		{name: "into check once the rook moves", fen: "4k3/8/8/8/8/8/8/rRK5 w Q - 0 1", move: "c1b1"},
+		{name: "without the right", fen: "4k3/8/8/8/8/8/8/1K1R3R w K - 0 1", move: "b1d1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g, err := ParseFEN(test.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !g.Chess960 {
+				t.Fatalf("Expected %s to be read as Chess960", test.fen)
+			}
+			hash := g.Hash()
+			move, err := parseUCIMove(g, test.move)
+			if test.want == "" {
+				if err == nil {
+					t.Errorf("Expected %s to be illegal", test.move)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if san := g.SAN(move); san != test.san {
+				t.Errorf("SAN() = %s, want %s", san, test.san)
+			}
+			if parsed, err := g.ParseSAN(test.san); err != nil || uciMove(parsed) != test.move {
+				t.Errorf("ParseSAN(%s) = %s, %v, want %s", test.san, uciMove(parsed), err, test.move)
+			}
+
+			g.applyMove(move)
+			if got := g.FEN(); got != test.want {
+				t.Errorf("Expected %s after castling, got %s", test.want, got)
+			}
+			if g.Hash() != g.computeHash() {
+				t.Error("Expected the hash to be kept up to date")
+			}
+			g.takeBack()
+			if got := g.FEN(); got != test.fen || g.Hash() != hash {
+				t.Errorf("Expected %s after taking castling back, got %s", test.fen, got)
+			}
+		})
+	}
+
+	// The web form plays castling as the king taking its rook
+	g, _ := ParseFEN("4k3/8/8/8/8/8/8/RK6 w Q - 0 1")
+	if err := g.MovePiece(1, 0, 0, 0); err != nil || g.FEN() != "4k3/8/8/8/8/8/8/2KR4 b - - 1 1" {
+		t.Errorf("Expected MovePiece to castle, got %v and %s", err, g.FEN())
+	}
+}
+
+func TestChess960Perft(t *testing.T) {
+	// Positions of the Chess960 perft results on the Chess Programming Wiki
+	tests := []struct {
+		fen   string
+		nodes []int
+	}{
+		{"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9", []int{21, 528, 12189}},
+		{"2nnrbkr/p1qppppp/8/1ppb4/6PP/3PP3/PPP2P2/BQNNRBKR w HEhe - 1 9", []int{21, 807, 18002}},
+		{"b1q1rrkb/pppppppp/3nn3/8/P7/1PPP4/4PPPP/BQNNRKRB w GE - 1 9", []int{20, 479, 10471}},
+		{"qbbnnrkr/2pp2pp/p7/1p2pp2/8/P3PP2/1PPP1KPP/QBBNNR1R w hf - 0 9", []int{22, 593, 13440}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fen, func(t *testing.T) {
+			g, err := ParseFEN(tt.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, nodes := range tt.nodes {
+				if got := perft(g, i+1); got != nodes {
+					t.Errorf("Expected %d nodes at depth %d, but got %d", nodes, i+1, got)
+				}
+			}
+			if got := g.ShredderFEN(); got != tt.fen {
+				t.Errorf("Expected the position back after perft, but got %s", got)
+			}
+		})
+	}
+}
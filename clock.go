@@ -0,0 +1,86 @@
+package gochess
+
+import (
+	"errors"
+	"time"
+)
+
+// timeNow is the clock source, replaced in tests
+var timeNow = time.Now
+
+// Clock keeps track of the thinking time left for each player.
+type Clock struct {
+	NopGameListener
+
+	Initial    time.Duration
+	Increment  time.Duration
+	White      time.Duration
+	Black      time.Duration
+	LastMoveAt time.Time
+}
+
+func NewClock(initial, increment time.Duration) *Clock {
+	return &Clock{
+		Initial:   initial,
+		Increment: increment,
+		White:     initial,
+		Black:     initial,
+	}
+}
+
+// SetClock attaches a clock to the game, which is then updated after every move.
+func (g *Game) SetClock(c *Clock) {
+	if g.Clock != nil {
+		g.RemoveListener(g.Clock)
+	}
+	g.Clock = c
+	g.AddListener(c)
+}
+
+// Remaining returns the time left for the given color, counting the time
+// spent so far on the current move if it is that color's turn.
+func (c *Clock) Remaining(color, turn PieceColor, now time.Time) time.Duration {
+	remaining := c.White
+	if color == Black {
+		remaining = c.Black
+	}
+	if color == turn && !c.LastMoveAt.IsZero() {
+		remaining -= now.Sub(c.LastMoveAt)
+	}
+	return remaining
+}
+
+// OnMove charges the time spent on the move to the player who made it. The
+// clock starts running after White's first move.
+func (c *Clock) OnMove(g *Game, move Move) {
+	now := timeNow()
+
+	spent := time.Duration(0)
+	if !c.LastMoveAt.IsZero() {
+		spent = now.Sub(c.LastMoveAt)
+	}
+
+	if move.Color == White {
+		c.White += c.Increment - spent
+	} else {
+		c.Black += c.Increment - spent
+	}
+	c.LastMoveAt = now
+}
+
+// OutOfTime reports whether the player on turn has run out of time.
+func (g *Game) OutOfTime(now time.Time) bool {
+	if g.Clock == nil || g.IsOver() {
+		return false
+	}
+	turn := g.GetCurrentPlayerColor()
+	return g.Clock.Remaining(turn, turn, now) <= 0
+}
+
+// TimeOut ends the game in a loss for the player whose time has run out.
+func (g *Game) TimeOut(color PieceColor) error {
+	if g.Clock == nil {
+		return errors.New("only games with a clock can be lost on time")
+	}
+	return g.Resign(color)
+}
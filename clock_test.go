@@ -0,0 +1,68 @@
+package gochess
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClock(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	g := NewGame("Alice", "Bob")
+	c := NewClock(time.Minute, time.Second)
+	g.SetClock(c)
+
+	// The clock only starts after White's first move
+	now = now.Add(10 * time.Second)
+	playMoves(t, g, "e2e4")
+	if c.White != time.Minute+time.Second {
+		t.Errorf("Expected White to have %v, but got %v", time.Minute+time.Second, c.White)
+	}
+
+	now = now.Add(20 * time.Second)
+	if got := c.Remaining(Black, Black, now); got != 40*time.Second {
+		t.Errorf("Expected Black to have %v remaining, but got %v", 40*time.Second, got)
+	}
+	playMoves(t, g, "e7e5")
+	if c.Black != 41*time.Second {
+		t.Errorf("Expected Black to have %v, but got %v", 41*time.Second, c.Black)
+	}
+}
+
+func TestOutOfTime(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	g := NewGame("Alice", "Bob")
+	g.SetClock(NewClock(time.Minute, 0))
+	if g.OutOfTime(now.Add(time.Hour)) {
+		t.Error("Expected the clock to not run before White's first move")
+	}
+	playMoves(t, g, "e2e4")
+
+	tests := []struct {
+		spent time.Duration
+		want  bool
+	}{
+		{spent: 59 * time.Second, want: false},
+		{spent: time.Minute, want: true},
+	}
+	for _, tt := range tests {
+		if got := g.OutOfTime(now.Add(tt.spent)); got != tt.want {
+			t.Errorf("OutOfTime() after %v = %v, want %v", tt.spent, got, tt.want)
+		}
+	}
+
+	if err := g.TimeOut(Black); err != nil || g.State != WhiteWon {
+		t.Errorf("Expected Black to lose on time, but got %s, %v", g.State, err)
+	}
+	if g.OutOfTime(now.Add(time.Hour)) {
+		t.Error("Expected a finished game to not run out of time")
+	}
+	if err := NewGame("Alice", "Bob").TimeOut(White); err == nil {
+		t.Error("Expected a game without a clock to not be lost on time")
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/sbracegirdle/gochess"
+)
+
+// gochess-uci runs the engine over the Universal Chess Interface on stdin and
+// stdout, so chess GUIs can load it.
+func main() {
+	weights := flag.String("eval-weights", "", "JSON file with the evaluation weights of the engine, to tune them")
+	book := flag.String("book", "", "Polyglot .bin opening book for the engine to play from")
+	bookDepth := flag.Int("book-depth", 24, "number of plies to play from the opening book, zero for all")
+	flag.Parse()
+
+	if *weights != "" {
+		loaded, err := gochess.LoadEvalWeights(*weights)
+		if err != nil {
+			log.Fatal(err)
+		}
+		gochess.SetEvalWeights(loaded)
+	}
+
+	engine := gochess.NewEngine()
+	if *book != "" {
+		loaded, err := gochess.LoadBook(*book)
+		if err != nil {
+			log.Fatal(err)
+		}
+		engine.Book, engine.BookDepth = loaded, *bookDepth
+	}
+
+	if err := gochess.NewUCI(engine, os.Stdout).Run(os.Stdin); err != nil {
+		log.Fatal(err)
+	}
+}
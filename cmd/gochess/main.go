@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sbracegirdle/gochess"
+)
+
+// pathList is a flag that can be given several times.
+type pathList []string
+
+func (p *pathList) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *pathList) Set(path string) error {
+	*p = append(*p, path)
+	return nil
+}
+
+func main() {
+	dataDir := flag.String("data", "", "directory to save games and accounts in, they are kept in memory if empty")
+	eloK := flag.Float64("elo-k", 20, "K-factor of the Elo rating system")
+	glickoTau := flag.Float64("glicko-tau", 0.5, "volatility constraint of the Glicko-2 rating system")
+	ratingPeriod := flag.Duration("rating-period", 7*24*time.Hour, "length of a Glicko-2 rating period")
+	chatBlocklist := flag.String("chat-blocklist", "", "file with words to mask in chat messages, one per line")
+	weights := flag.String("eval-weights", "", "JSON file with the evaluation weights of the engine, to tune them")
+	book := flag.String("book", "", "Polyglot .bin opening book for the engine to play from")
+	bookDepth := flag.Int("book-depth", 24, "number of plies to play from the opening book, or to store with -make-book, zero for all")
+	makeBook := flag.String("make-book", "", "build a Polyglot book at this path from the PGN files given as arguments, instead of the server")
+	review := flag.Bool("review", false, "print the games of the PGN files given as arguments annotated with the engine's review, instead of the server")
+	reviewDepth := flag.Int("review-depth", gochess.DefaultSearchDepth, "depth the engine searches each position of a game with -review")
+	makePuzzles := flag.String("make-puzzles", "", "write the tactics puzzles found in the PGN files given as arguments to this CSV file, instead of the server")
+	puzzleSet := flag.String("puzzles", "", "CSV file of puzzles, as written by -make-puzzles, for users to solve on the server")
+	puzzleDepth := flag.Int("puzzle-depth", gochess.DefaultSearchDepth, "depth the engine searches each position for puzzles with -make-puzzles")
+	xboard := flag.Bool("xboard", false, "run the engine over the XBoard protocol (CECP) on stdin and stdout instead of the server")
+	var uciEngines pathList
+	flag.Var(&uciEngines, "uci-engine", "path of an external UCI engine to offer as an opponent, can be given several times")
+	flag.Parse()
+
+	if *weights != "" {
+		loaded, err := gochess.LoadEvalWeights(*weights)
+		if err != nil {
+			log.Fatal(err)
+		}
+		gochess.SetEvalWeights(loaded)
+	}
+
+	if *makeBook != "" {
+		if err := buildBook(*makeBook, *bookDepth, flag.Args()); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *makePuzzles != "" {
+		if err := findPuzzles(*makePuzzles, *puzzleDepth, flag.Args()); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *review {
+		if err := reviewGames(os.Stdout, *reviewDepth, flag.Args()); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	engine := gochess.NewEngine()
+	if *book != "" {
+		loaded, err := gochess.LoadBook(*book)
+		if err != nil {
+			log.Fatal(err)
+		}
+		engine.Book, engine.BookDepth = loaded, *bookDepth
+	}
+
+	if *xboard {
+		if err := gochess.NewXBoard(engine, os.Stdout).Run(os.Stdin); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	var blocked []string
+	if *chatBlocklist != "" {
+		data, err := os.ReadFile(*chatBlocklist)
+		if err != nil {
+			log.Fatal(err)
+		}
+		blocked = strings.Fields(string(data))
+	}
+
+	config := gochess.ServerConfig{
+		Games:        gochess.NewMemoryGameStore(),
+		Users:        gochess.NewMemoryUserStore(),
+		RatingPeriod: *ratingPeriod,
+		Engine:       engine,
+		Chat:         gochess.NewChat(5, 10*time.Second, gochess.MaxLengthFilter(500), gochess.WordFilter(blocked...)),
+	}
+	var ratingStore gochess.RatingStore = gochess.NewMemoryRatingStore()
+	var puzzleStore gochess.PuzzleRecordStore = gochess.NewMemoryPuzzleStore()
+	var tournamentStore gochess.TournamentStore = gochess.NewMemoryTournamentStore()
+	var arenaStore gochess.ArenaStore = gochess.NewMemoryArenaStore()
+	if *dataDir != "" {
+		fileStore, err := gochess.NewFileGameStore(*dataDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		config.Games = fileStore
+
+		fileUserStore, err := gochess.NewFileUserStore(filepath.Join(*dataDir, "users"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		config.Users = fileUserStore
+
+		fileRatingStore, err := gochess.NewFileRatingStore(filepath.Join(*dataDir, "ratings"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		ratingStore = fileRatingStore
+
+		filePuzzleStore, err := gochess.NewFilePuzzleStore(filepath.Join(*dataDir, "puzzles"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		puzzleStore = filePuzzleStore
+
+		fileTournamentStore, err := gochess.NewFileTournamentStore(filepath.Join(*dataDir, "tournaments"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		tournamentStore = fileTournamentStore
+
+		fileArenaStore, err := gochess.NewFileArenaStore(filepath.Join(*dataDir, "arenas"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		arenaStore = fileArenaStore
+	}
+	config.Rater = gochess.NewRater(ratingStore, gochess.Elo{K: *eloK}, gochess.Glicko2{Tau: *glickoTau})
+	config.Tournaments = gochess.NewTournamentDirector(tournamentStore)
+	config.Arenas = gochess.NewArenaDirector(arenaStore)
+
+	if *puzzleSet != "" {
+		puzzles, err := loadPuzzles(*puzzleSet)
+		if err != nil {
+			log.Fatal(err)
+		}
+		config.Puzzles = gochess.NewPuzzleTrainer(puzzles, puzzleStore, gochess.Elo{K: *eloK})
+	}
+
+	config.Engines = map[string]*gochess.UCIEngine{}
+	for _, path := range uciEngines {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		external, err := gochess.StartUCIEngine(ctx, path)
+		if err == nil {
+			err = external.NewGame(ctx)
+		}
+		cancel()
+		if err != nil {
+			log.Fatalf("Failed to start engine %s: %v", path, err)
+		}
+		config.Engines[external.Name] = external
+	}
+
+	gochess.StartServer(config)
+}
+
+// buildBook writes a Polyglot book of the first maxPly plies of the games in
+// the PGN files.
+func buildBook(path string, maxPly int, pgnFiles []string) error {
+	builder := gochess.NewBookBuilder(maxPly)
+	for _, pgnFile := range pgnFiles {
+		f, err := os.Open(pgnFile)
+		if err != nil {
+			return err
+		}
+		games, err := gochess.ReadPGN(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", pgnFile, err)
+		}
+		for _, game := range games {
+			builder.Add(game.Game, game.Result())
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := builder.Book().Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// reviewGames writes the games of the PGN files annotated with their review.
+func reviewGames(w io.Writer, depth int, pgnFiles []string) error {
+	reviewEngine := &gochess.Engine{Evaluate: gochess.Evaluate, TT: gochess.NewTranspositionTable(gochess.DefaultHashSize)}
+	for _, pgnFile := range pgnFiles {
+		f, err := os.Open(pgnFile)
+		if err != nil {
+			return err
+		}
+		games, err := gochess.ReadPGN(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", pgnFile, err)
+		}
+		for i, game := range games {
+			review, err := reviewEngine.Review(game.Game, gochess.SearchLimits{Depth: depth})
+			if err != nil {
+				return fmt.Errorf("%s: game %d: %v", pgnFile, i+1, err)
+			}
+			if err := gochess.WriteAnnotatedPGN(w, game.Tags, game.Game, review); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// findPuzzles writes the puzzles found in the games of the PGN files to a CSV
+// file, each position once.
+func findPuzzles(path string, depth int, pgnFiles []string) error {
+	puzzleEngine := &gochess.Engine{Evaluate: gochess.Evaluate, TT: gochess.NewTranspositionTable(gochess.DefaultHashSize)}
+	puzzles := []gochess.Puzzle{}
+	seen := map[string]bool{}
+	for _, pgnFile := range pgnFiles {
+		f, err := os.Open(pgnFile)
+		if err != nil {
+			return err
+		}
+		games, err := gochess.ReadPGN(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", pgnFile, err)
+		}
+		for i, game := range games {
+			found, err := puzzleEngine.FindPuzzles(game.Game, gochess.SearchLimits{Depth: depth})
+			if err != nil {
+				return fmt.Errorf("%s: game %d: %v", pgnFile, i+1, err)
+			}
+			for _, puzzle := range found {
+				if seen[puzzle.ID] {
+					continue
+				}
+				seen[puzzle.ID] = true
+				puzzle.Source = fmt.Sprintf("%s - %s, %s %s", game.Tags["White"], game.Tags["Black"], game.Tags["Event"], game.Tags["Date"])
+				puzzles = append(puzzles, puzzle)
+			}
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := gochess.WritePuzzles(f, puzzles); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// loadPuzzles reads the puzzles of a CSV file.
+func loadPuzzles(path string) ([]gochess.Puzzle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	puzzles, err := gochess.ReadPuzzles(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return puzzles, nil
+}
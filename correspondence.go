@@ -0,0 +1,157 @@
+package gochess
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+// Correspondence is a time control for slow games where each player has a
+// number of days for every move, and can take days off from a vacation
+// allowance.
+type Correspondence struct {
+	NopGameListener
+
+	DaysPerMove  int
+	VacationDays int
+	// VacationLeft is the number of vacation days each player can still take
+	VacationLeft map[PieceColor]int
+	// Vacation holds the vacation taken by each player for their current or
+	// next move
+	Vacation map[PieceColor]time.Duration `json:",omitempty"`
+	// LastMoveAt is when the player on turn started thinking
+	LastMoveAt time.Time
+	// Conditionals are the premoves of each player, as lines of moves in
+	// coordinate notation starting with the opponent's next move
+	Conditionals map[PieceColor][][]string `json:",omitempty"`
+}
+
+func NewCorrespondence(daysPerMove, vacationDays int) *Correspondence {
+	return &Correspondence{
+		DaysPerMove:  daysPerMove,
+		VacationDays: vacationDays,
+		VacationLeft: map[PieceColor]int{White: vacationDays, Black: vacationDays},
+		Vacation:     map[PieceColor]time.Duration{},
+		LastMoveAt:   timeNow(),
+		Conditionals: map[PieceColor][][]string{},
+	}
+}
+
+// SetCorrespondence attaches a correspondence time control to the game.
+func (g *Game) SetCorrespondence(c *Correspondence) {
+	if g.Correspondence != nil {
+		g.RemoveListener(g.Correspondence)
+	}
+	g.Correspondence = c
+	g.AddListener(c)
+}
+
+// Deadline returns the time by which the given color has to move when it is
+// their turn.
+func (c *Correspondence) Deadline(color PieceColor) time.Time {
+	return c.LastMoveAt.Add(time.Duration(c.DaysPerMove)*day + c.Vacation[color])
+}
+
+// Expired reports whether the given color, on turn, has missed its deadline.
+func (c *Correspondence) Expired(color PieceColor, now time.Time) bool {
+	return now.After(c.Deadline(color))
+}
+
+// TakeVacation spends vacation days of the given color, which extend the
+// deadline of their current move, or of their next one if it is not their turn.
+func (c *Correspondence) TakeVacation(color PieceColor, days int) error {
+	if days < 1 {
+		return errors.New("vacation must be at least one day")
+	}
+	if days > c.VacationLeft[color] {
+		return errors.New("not enough vacation days left")
+	}
+	if c.Vacation == nil {
+		c.Vacation = map[PieceColor]time.Duration{}
+	}
+	c.VacationLeft[color] -= days
+	c.Vacation[color] += time.Duration(days) * day
+	return nil
+}
+
+// SetConditionals replaces the premoves of the given color, who waits for
+// the opponent's move. Each line starts with a move of the opponent followed
+// by the reply, and may go on with more pairs of moves. Every line is played
+// out on a copy of the position to check its moves are legal.
+func (g *Game) SetConditionals(color PieceColor, lines [][]string) error {
+	if g.Correspondence == nil {
+		return errors.New("only correspondence games have conditional moves")
+	}
+	if g.GetCurrentPlayerColor() == color {
+		return errors.New("conditional moves wait for the opponent's move")
+	}
+	for _, line := range lines {
+		if len(line) < 2 || len(line)%2 != 0 {
+			return errors.New("conditional lines need pairs of moves: " + strings.Join(line, " "))
+		}
+		position := g.searchCopy()
+		for _, notation := range line {
+			move, err := parseUCIMove(position, notation)
+			if err != nil {
+				return errors.New("illegal move in conditional line: " + notation)
+			}
+			position.applyMove(move)
+		}
+	}
+
+	c := g.Correspondence
+	if c.Conditionals == nil {
+		c.Conditionals = map[PieceColor][][]string{}
+	}
+	c.Conditionals[color] = lines
+	return nil
+}
+
+// Respond returns the premove of the given color in reply to the move just
+// played by the opponent. Lines that do not follow the game are dropped.
+func (c *Correspondence) Respond(color PieceColor, played string) (string, bool) {
+	if len(c.Conditionals[color]) == 0 {
+		return "", false
+	}
+
+	reply := ""
+	remaining := [][]string{}
+	for _, line := range c.Conditionals[color] {
+		if line[0] != played || (reply != "" && line[1] != reply) {
+			continue
+		}
+		reply = line[1]
+		if len(line) > 2 {
+			remaining = append(remaining, line[2:])
+		}
+	}
+	c.Conditionals[color] = remaining
+	return reply, reply != ""
+}
+
+// OnMove restarts the time for the next move. Vacation taken by the player
+// who moved has been used up.
+func (c *Correspondence) OnMove(g *Game, move Move) {
+	c.LastMoveAt = timeNow()
+	delete(c.Vacation, move.Color)
+}
+
+// Deadline returns the time by which the player on turn has to move in a
+// correspondence game.
+func (g *Game) Deadline() time.Time {
+	if g.Correspondence == nil {
+		return time.Time{}
+	}
+	return g.Correspondence.Deadline(g.GetCurrentPlayerColor())
+}
+
+// Forfeit ends the game with a win for the other player because the given
+// color missed the deadline of their move.
+func (g *Game) Forfeit(color PieceColor) error {
+	if g.Correspondence == nil {
+		return errors.New("only correspondence games can be forfeited")
+	}
+	return g.Resign(color)
+}
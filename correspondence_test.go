@@ -0,0 +1,109 @@
+package gochess
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCorrespondenceDeadline(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	g := NewGame("Alice", "Bob")
+	c := NewCorrespondence(3, 5)
+	g.SetCorrespondence(c)
+
+	if got := g.Deadline(); !got.Equal(now.Add(3 * day)) {
+		t.Errorf("Expected White to move within 3 days, but got %v", got)
+	}
+
+	// Vacation taken by Black applies to their next move
+	if err := c.TakeVacation(Black, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.TakeVacation(Black, 4); err == nil {
+		t.Errorf("Expected vacation beyond the allowance to fail")
+	}
+
+	now = now.Add(2 * day)
+	playMoves(t, g, "e2e4")
+	if got := g.Deadline(); !got.Equal(now.Add(5 * day)) {
+		t.Errorf("Expected Black to move within 5 days, but got %v", got)
+	}
+	if c.Expired(Black, now.Add(4*day)) || !c.Expired(Black, now.Add(6*day)) {
+		t.Errorf("Expected Black to run out of time after 5 days")
+	}
+
+	// The vacation is used up by the move
+	playMoves(t, g, "e7e5", "g1f3")
+	if got := g.Deadline(); !got.Equal(now.Add(3 * day)) {
+		t.Errorf("Expected Black to be back to 3 days, but got %v", got)
+	}
+}
+
+func TestCorrespondenceRespond(t *testing.T) {
+	tests := []struct {
+		name      string
+		lines     [][]string
+		played    string
+		reply     string
+		remaining int
+	}{
+		{"no conditionals", nil, "e2e4", "", 0},
+		{"matching line", [][]string{{"e2e4", "e7e5"}}, "e2e4", "e7e5", 0},
+		{"other move", [][]string{{"d2d4", "d7d5"}}, "e2e4", "", 0},
+		{"line continues", [][]string{{"e2e4", "e7e5", "g1f3", "b8c6"}, {"e2e4", "e7e5", "f1c4", "g8f6"}}, "e2e4", "e7e5", 2},
+		{"first reply wins", [][]string{{"e2e4", "c7c5"}, {"e2e4", "e7e5", "g1f3", "b8c6"}}, "e2e4", "c7c5", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewGame("Alice", "Bob")
+			c := NewCorrespondence(3, 0)
+			g.SetCorrespondence(c)
+			if err := g.SetConditionals(Black, test.lines); err != nil {
+				t.Fatal(err)
+			}
+			reply, ok := c.Respond(Black, test.played)
+			if reply != test.reply || ok != (test.reply != "") {
+				t.Errorf("Expected reply %q, but got %q", test.reply, reply)
+			}
+			if len(c.Conditionals[Black]) != test.remaining {
+				t.Errorf("Expected %d lines left, but got %v", test.remaining, c.Conditionals[Black])
+			}
+		})
+	}
+}
+
+func TestSetConditionalsRejectsInvalidLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		color PieceColor
+		lines [][]string
+	}{
+		{"without a reply", Black, [][]string{{"e2e4"}}},
+		{"not a move", Black, [][]string{{"e2e4", "xx"}}},
+		{"off the board", Black, [][]string{{"z9z9", "e7e5"}}},
+		{"illegal reply", Black, [][]string{{"e2e4", "e7e4"}}},
+		{"illegal later in the line", Black, [][]string{{"e2e4", "e7e5", "g1f3", "e5e4", "f3e5", "e8e6"}}},
+		{"on own turn", White, [][]string{{"e7e5", "g1f3"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewGame("Alice", "Bob")
+			g.SetCorrespondence(NewCorrespondence(3, 0))
+			if err := g.SetConditionals(test.color, test.lines); err == nil {
+				t.Errorf("Expected %v to be rejected", test.lines)
+			}
+			if len(g.Correspondence.Conditionals[test.color]) != 0 {
+				t.Errorf("Expected no conditionals to be stored, but got %v", g.Correspondence.Conditionals)
+			}
+		})
+	}
+
+	if err := NewGame("Alice", "Bob").SetConditionals(Black, [][]string{{"e2e4", "e7e5"}}); err == nil {
+		t.Errorf("Expected a game without correspondence to have no conditionals")
+	}
+}
@@ -0,0 +1,395 @@
+package gochess
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// mateScore is the score of giving mate, less the number of plies it takes
+const mateScore = 1000000
+
+// DefaultSearchDepth is searched when no other limit is given
+const DefaultSearchDepth = 4
+
+// MaxSearchDepth is as deep as the engine searches without a depth limit
+const MaxSearchDepth = 64
+
+var pieceValues = map[PieceType]int{Pawn: 100, Knight: 320, Bishop: 330, Rook: 500, Queen: 900, King: 0}
+
+// computerName is the player name of the engine in games against the computer
+const computerName = "Computer"
+
+// engineSeatName is the player name of an external engine, like "Computer
+// (Stockfish 16)". The brackets and space keep it apart from every name an
+// account can be registered under, whatever the engine is called.
+func engineSeatName(engine string) string {
+	return computerName + " (" + engine + ")"
+}
+
+// ComputerOpponent is the engine playing one side of a game.
+type ComputerOpponent struct {
+	Color  PieceColor
+	Limits SearchLimits
+	// Engine is the name of the external engine playing, empty for gochess
+	Engine string `json:",omitempty"`
+}
+
+// SearchLimits bound how long the engine thinks. The search stops at the
+// first limit reached.
+type SearchLimits struct {
+	// Depth is the number of plies searched, zero for no limit
+	Depth int
+	// Time is how long the search may take, zero for no limit
+	Time time.Duration
+	// Nodes is the number of positions searched, zero for no limit
+	Nodes int
+	// Stop ends the search when closed
+	Stop <-chan struct{} `json:"-"`
+}
+
+// SearchResult is the best move found by the engine and its score in
+// centipawns, from the point of view of the player on turn.
+type SearchResult struct {
+	Move  Move
+	Score int
+	// Depth is the last depth searched to the end
+	Depth int
+	Nodes int
+	// Time is how long the search has taken
+	Time time.Duration
+	// PV is the line the engine expects to be played
+	PV []Move
+}
+
+// IsMate reports whether the score is a forced mate, for either side.
+func (r SearchResult) IsMate() bool {
+	return r.Score > mateScore-1000 || r.Score < -mateScore+1000
+}
+
+// MateIn is the number of moves to mate when the score is a forced mate,
+// negative when the player on turn is mated, and zero otherwise.
+func (r SearchResult) MateIn() int {
+	if !r.IsMate() {
+		return 0
+	}
+	moves := (mateScore - abs(r.Score) + 1) / 2
+	if r.Score < 0 {
+		return -moves
+	}
+	return moves
+}
+
+// Engine searches for the best move of a position with alpha-beta.
+type Engine struct {
+	// Evaluate scores a quiet position in centipawns from White's point of view
+	Evaluate func(g *Game) int
+	// TT is shared by the searches of the engine, nil to search without it
+	TT *TranspositionTable
+	// Book is played from instead of searching while it has moves, for the
+	// first BookDepth plies of the game or all of them if zero
+	Book      *Book
+	BookDepth int
+	// Tablebase, if set, gives the result of positions with few pieces
+	// instead of searching them
+	Tablebase Tablebase
+}
+
+func NewEngine() *Engine {
+	return &Engine{Evaluate: Evaluate, TT: NewTranspositionTable(DefaultHashSize)}
+}
+
+// searchCopy returns a copy of the position without listeners, for the
+// engine to play moves on.
+func (g *Game) searchCopy() *Game {
+	return &Game{
+		ID:            g.ID,
+		Board:         g.Board,
+		Players:       g.Players,
+		State:         g.State,
+		History:       append([]Move{}, g.History...),
+		Castling:      g.Castling,
+		CastlingRooks: g.CastlingRooks,
+		Chess960:      g.Chess960,
+		EnPassant:     g.EnPassant,
+		HalfmoveClock: g.HalfmoveClock,
+		StartColor:    g.StartColor,
+		StartMove:     g.StartMove,
+		hash:          g.Hash(),
+		hashed:        true,
+	}
+}
+
+// search holds the state of a single search.
+type search struct {
+	engine   *Engine
+	g        *Game
+	limits   SearchLimits
+	deadline time.Time
+	nodes    int
+	stopped  bool
+	// pvMove is the best move of the previous iteration, searched first
+	pvMove *Move
+	// excluded are root moves left out of the search, the lines already
+	// found when analysing several
+	excluded []Move
+}
+
+// Search looks for the best move of the player on turn, deepening the search
+// one ply at a time until a limit is reached.
+func (e *Engine) Search(g *Game, limits SearchLimits) (SearchResult, error) {
+	return e.SearchWithInfo(g, limits, nil)
+}
+
+// SearchWithInfo searches like Search and calls info with the result of each
+// depth searched to the end.
+func (e *Engine) SearchWithInfo(g *Game, limits SearchLimits, info func(SearchResult)) (SearchResult, error) {
+	if g.State != Ongoing {
+		return SearchResult{}, errors.New("Game is not ongoing, got state: " + string(g.State))
+	}
+	if e.Book != nil && (e.BookDepth == 0 || g.ply() < e.BookDepth) {
+		if move, ok := e.Book.Pick(g); ok {
+			return SearchResult{Move: move, PV: []Move{move}}, nil
+		}
+	}
+	if e.Tablebase != nil {
+		if result, ok := e.probeRoot(g); ok {
+			return result, nil
+		}
+	}
+	if limits.Depth == 0 && limits.Time == 0 && limits.Nodes == 0 && limits.Stop == nil {
+		limits.Depth = DefaultSearchDepth
+	}
+	if limits.Depth == 0 || limits.Depth > MaxSearchDepth {
+		limits.Depth = MaxSearchDepth
+	}
+
+	started := time.Now()
+	s := &search{engine: e, g: g.searchCopy(), limits: limits}
+	if e.TT != nil {
+		e.TT.NewSearch()
+	}
+	if limits.Time > 0 {
+		s.deadline = started.Add(limits.Time)
+	}
+
+	moves := s.g.LegalMoves()
+	if len(moves) == 0 {
+		return SearchResult{}, errors.New("no legal moves")
+	}
+
+	// A move is always returned, even if the first iteration runs out of time
+	result := SearchResult{Move: moves[0]}
+	for depth := 1; depth <= limits.Depth; depth++ {
+		score, pv := s.negamax(depth, 0, -mateScore-1, mateScore+1)
+		if s.stopped {
+			break
+		}
+		result = SearchResult{Move: pv[0], Score: score, Depth: depth, Nodes: s.nodes, Time: time.Now().Sub(started), PV: pv}
+		s.pvMove = &pv[0]
+		if info != nil {
+			info(result)
+		}
+
+		// Searching deeper cannot find a quicker mate
+		if result.IsMate() {
+			break
+		}
+	}
+	result.Nodes = s.nodes
+	result.Time = time.Now().Sub(started)
+	return result, nil
+}
+
+// timeUp checks the limits of the search, the clock and the stop channel
+// every so many nodes.
+func (s *search) timeUp() bool {
+	if s.stopped {
+		return true
+	}
+	if s.limits.Nodes > 0 && s.nodes > s.limits.Nodes {
+		s.stopped = true
+	}
+	if s.nodes%1024 == 0 {
+		if !s.deadline.IsZero() && time.Now().After(s.deadline) {
+			s.stopped = true
+		}
+		select {
+		case <-s.limits.Stop:
+			s.stopped = true
+		default:
+		}
+	}
+	return s.stopped
+}
+
+// score evaluates the position from the point of view of the player on turn.
+func (s *search) score() int {
+	score := s.engine.Evaluate(s.g)
+	if s.g.GetCurrentPlayerColor() == Black {
+		return -score
+	}
+	return score
+}
+
+func (s *search) negamax(depth, ply, alpha, beta int) (int, []Move) {
+	s.nodes++
+	if s.timeUp() {
+		return 0, nil
+	}
+	// Right after a capture or pawn move the tablebase knows the result of a
+	// position with few enough pieces
+	if tb := s.engine.Tablebase; tb != nil && ply > 0 && s.g.HalfmoveClock == 0 && inTablebase(tb, s.g) {
+		if wdl, err := tb.ProbeWDL(s.g); err == nil {
+			return wdlScore(wdl, ply), nil
+		}
+	}
+
+	if depth == 0 {
+		return s.quiesce(alpha, beta), nil
+	}
+
+	// A position searched before as deep is not searched again, and its best
+	// move is tried first otherwise
+	hash := s.g.Hash()
+	var first *Move
+	if ply == 0 {
+		first = s.pvMove
+	}
+	if s.engine.TT != nil {
+		if entry, ok := s.engine.TT.Probe(hash); ok {
+			if ply > 0 && entry.Depth >= depth {
+				score := scoreFromTT(entry.Score, ply)
+				if entry.Bound == ExactBound || (entry.Bound == LowerBound && score >= beta) || (entry.Bound == UpperBound && score <= alpha) {
+					return score, nil
+				}
+			}
+			if entry.HasMove() && first == nil {
+				first = &Move{From: entry.From, To: entry.To, Promotion: entry.Promotion}
+			}
+		}
+	}
+
+	color := s.g.GetCurrentPlayerColor()
+	moves := s.g.legalMoves(color)
+	if len(moves) == 0 {
+		if s.g.IsCheck(color) {
+			return -mateScore + ply, nil
+		}
+		return 0, nil
+	}
+	s.orderMoves(moves, first)
+	if ply == 0 && len(s.excluded) > 0 {
+		moves = s.withoutExcluded(moves)
+	}
+
+	alphaBefore := alpha
+	var best []Move
+	for _, move := range moves {
+		s.g.applyMove(move)
+		score, pv := s.negamax(depth-1, ply+1, -beta, -alpha)
+		score = -score
+		s.g.takeBack()
+		if s.stopped {
+			return 0, nil
+		}
+
+		if score > alpha || best == nil {
+			best = append([]Move{move}, pv...)
+		}
+		if score > alpha {
+			alpha = score
+		}
+		if alpha >= beta {
+			break
+		}
+	}
+
+	// With root moves left out the best move found is not the position's
+	if s.engine.TT != nil && (ply > 0 || len(s.excluded) == 0) {
+		bound := ExactBound
+		if alpha <= alphaBefore {
+			bound = UpperBound
+		} else if alpha >= beta {
+			bound = LowerBound
+		}
+		s.engine.TT.Store(TTEntry{Hash: hash, Depth: depth, Score: scoreToTT(alpha, ply), Bound: bound, From: best[0].From, To: best[0].To, Promotion: best[0].Promotion})
+	}
+	return alpha, best
+}
+
+// withoutExcluded returns the moves that are not excluded from the search.
+func (s *search) withoutExcluded(moves []Move) []Move {
+	kept := []Move{}
+	for _, move := range moves {
+		excluded := false
+		for _, other := range s.excluded {
+			if move.From == other.From && move.To == other.To && move.Promotion == other.Promotion {
+				excluded = true
+			}
+		}
+		if !excluded {
+			kept = append(kept, move)
+		}
+	}
+	return kept
+}
+
+// quiesce searches captures and promotions until the position is quiet, so
+// the evaluation does not stop in the middle of an exchange.
+func (s *search) quiesce(alpha, beta int) int {
+	s.nodes++
+	if s.timeUp() {
+		return 0
+	}
+
+	standPat := s.score()
+	if standPat >= beta {
+		return beta
+	}
+	if standPat > alpha {
+		alpha = standPat
+	}
+
+	moves := []Move{}
+	for _, move := range s.g.legalMoves(s.g.GetCurrentPlayerColor()) {
+		if move.PieceTaken != nil || move.Promotion == Queen {
+			moves = append(moves, move)
+		}
+	}
+	s.orderMoves(moves, nil)
+
+	for _, move := range moves {
+		s.g.applyMove(move)
+		score := -s.quiesce(-beta, -alpha)
+		s.g.takeBack()
+		if s.stopped {
+			return 0
+		}
+
+		if score >= beta {
+			return beta
+		}
+		if score > alpha {
+			alpha = score
+		}
+	}
+	return alpha
+}
+
+// orderMoves sorts the moves so the best are likely searched first: the
+// given move, usually the best of an earlier search, then captures of the most
+// valuable piece by the least valuable attacker, then promotions.
+func (s *search) orderMoves(moves []Move, first *Move) {
+	rank := func(m Move) int {
+		if first != nil && m.From == first.From && m.To == first.To && m.Promotion == first.Promotion {
+			return 1 << 20
+		}
+		rank := pieceValues[m.Promotion]
+		if m.PieceTaken != nil {
+			rank += 10*pieceValues[m.PieceTaken.Type] - pieceValues[s.g.Board[m.From.X][m.From.Y].Type]
+		}
+		return rank
+	}
+	sort.SliceStable(moves, func(i, j int) bool { return rank(moves[i]) > rank(moves[j]) })
+}
@@ -0,0 +1,187 @@
+package gochess
+
+import (
+	"testing"
+	"time"
+)
+
+// perft counts the leaf nodes of the legal move tree to the given depth.
+func perft(g *Game, depth int) int {
+	if depth == 0 {
+		return 1
+	}
+	nodes := 0
+	for _, move := range g.LegalMoves() {
+		g.applyMove(move)
+		nodes += perft(g, depth-1)
+		g.takeBack()
+	}
+	return nodes
+}
+
+// positionGame sets up a game with the given pieces and player on turn.
+func positionGame(pieces map[[2]int]*Piece, turn PieceColor) *Game {
+	g := &Game{Board: createBoardWithPieces(pieces), State: Ongoing, History: []Move{}}
+	if turn == Black {
+		// Black is on turn after a move of White
+		g.History = append(g.History, Move{Color: White})
+	}
+	return g
+}
+
+func TestPerft(t *testing.T) {
+	expected := []int{1, 20, 400, 8902}
+	for depth, nodes := range expected {
+		g := NewGame("alice", "bob")
+		if got := perft(g, depth); got != nodes {
+			t.Errorf("Expected %d nodes at depth %d, but got %d", nodes, depth, got)
+		}
+	}
+}
+
+func TestPerftPositions(t *testing.T) {
+	// Positions known for catching castling, en passant and promotion bugs
+	tests := []struct {
+		name  string
+		fen   string
+		nodes []int
+	}{
+		{"Kiwipete", "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", []int{48, 2039}},
+		{"En passant pins", "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", []int{14, 191, 2812}},
+		{"Promotions", "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", []int{6, 264}},
+		{"Promotion with check", "rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8", []int{44, 1486}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := ParseFEN(tt.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, nodes := range tt.nodes {
+				if got := perft(g, i+1); got != nodes {
+					t.Errorf("Expected %d nodes at depth %d, but got %d", nodes, i+1, got)
+				}
+			}
+			if got := g.FEN(); got != tt.fen {
+				t.Errorf("Expected the position back after perft, but got %s", got)
+			}
+		})
+	}
+}
+
+func TestEngineSearch(t *testing.T) {
+	tests := []struct {
+		name   string
+		pieces map[[2]int]*Piece
+		turn   PieceColor
+		move   string
+		mate   bool
+	}{
+		{
+			name: "back rank mate",
+			pieces: map[[2]int]*Piece{
+				{6, 0}: {Color: White, Type: King},
+				{0, 0}: {Color: White, Type: Rook},
+				{6, 7}: {Color: Black, Type: King},
+				{5, 6}: {Color: Black, Type: Pawn},
+				{6, 6}: {Color: Black, Type: Pawn},
+				{7, 6}: {Color: Black, Type: Pawn},
+			},
+			turn: White,
+			move: "a1a8",
+			mate: true,
+		},
+		{
+			name: "takes the hanging queen",
+			pieces: map[[2]int]*Piece{
+				{4, 0}: {Color: White, Type: King},
+				{3, 3}: {Color: White, Type: Knight},
+				{4, 5}: {Color: Black, Type: Queen},
+				{4, 7}: {Color: Black, Type: King},
+			},
+			turn: White,
+			move: "d4e6",
+		},
+		{
+			name: "promotes as Black",
+			pieces: map[[2]int]*Piece{
+				{7, 7}: {Color: White, Type: King},
+				{0, 1}: {Color: Black, Type: Pawn},
+				{4, 4}: {Color: Black, Type: King},
+			},
+			turn: Black,
+			move: "a2a1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := positionGame(test.pieces, test.turn)
+			result, err := NewEngine().Search(g, SearchLimits{Depth: 3})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := coordinatesToNotation(result.Move.From, result.Move.To); got != test.move {
+				t.Errorf("Expected %s, but got %s with score %d", test.move, got, result.Score)
+			}
+			if result.IsMate() != test.mate {
+				t.Errorf("Expected mate to be %v, but got score %d", test.mate, result.Score)
+			}
+			if len(result.PV) == 0 || result.PV[0] != result.Move {
+				t.Errorf("Expected the line to start with the best move, but got %v", result.PV)
+			}
+		})
+	}
+}
+
+func TestEngineTimeLimit(t *testing.T) {
+	g := NewGame("alice", "bob")
+	start := time.Now()
+	result, err := NewEngine().Search(g, SearchLimits{Time: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the search to stop in time, but took %v", elapsed)
+	}
+	if result.Depth < 1 || g.IsValidMove(White, result.Move.From.X, result.Move.From.Y, result.Move.To.X, result.Move.To.Y) != nil {
+		t.Errorf("Expected a legal move, but got %+v", result)
+	}
+	if len(g.History) != 0 {
+		t.Errorf("Expected the search to leave the game alone")
+	}
+}
+
+func TestIsCheckmateWithBlockers(t *testing.T) {
+	// The king cannot move, but the rook can block the check
+	g := positionGame(map[[2]int]*Piece{
+		{7, 0}: {Color: White, Type: King},
+		{6, 1}: {Color: White, Type: Pawn},
+		{7, 1}: {Color: White, Type: Pawn},
+		{5, 2}: {Color: White, Type: Rook},
+		{3, 0}: {Color: Black, Type: Rook},
+		{4, 7}: {Color: Black, Type: King},
+	}, White)
+	if g.IsCheckmate(White) {
+		t.Errorf("Expected the check to be blocked")
+	}
+	g.Board[5][2] = nil
+	if !g.IsCheckmate(White) {
+		t.Errorf("Expected checkmate without a blocker")
+	}
+}
+
+func TestStalemateIsDraw(t *testing.T) {
+	g := positionGame(map[[2]int]*Piece{
+		{0, 7}: {Color: Black, Type: King},
+		{2, 5}: {Color: White, Type: Queen},
+		{7, 0}: {Color: White, Type: King},
+	}, White)
+	if err := g.MovePiece(2, 5, 1, 5); err != nil {
+		t.Fatal(err)
+	}
+	if g.State != Draw {
+		t.Errorf("Expected stalemate to draw the game, but got %s", g.State)
+	}
+}
@@ -0,0 +1,360 @@
+package gochess
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// Phased is a weight with a middlegame and an endgame value. The evaluation
+// blends the two by how much material is left on the board.
+type Phased struct {
+	Middlegame int
+	Endgame    int
+}
+
+// PieceSquareTable holds a bonus for each square, from White's side of the
+// board with the 8th rank first. Black uses the mirrored table.
+type PieceSquareTable struct {
+	Middlegame [64]int
+	Endgame    [64]int
+}
+
+// EvalWeights are the weights of the evaluation in centipawns. They can be
+// tuned with a JSON file that overrides the defaults, see LoadEvalWeights.
+type EvalWeights struct {
+	Material    map[PieceType]Phased
+	PieceSquare map[PieceType]PieceSquareTable
+	// Mobility is the bonus per square a piece can move to
+	Mobility map[PieceType]Phased
+
+	// DoubledPawn and IsolatedPawn are penalties per pawn
+	DoubledPawn  Phased
+	IsolatedPawn Phased
+	// PassedPawn is the bonus of a passed pawn by its rank, from the player's side
+	PassedPawn [8]Phased
+
+	// KingShield is the bonus per pawn on the three files in front of the
+	// king, and KingOpenFile the penalty for no pawn on the king's file
+	KingShield   Phased
+	KingOpenFile Phased
+}
+
+// phaseWeights tell how much each piece counts towards the middlegame. With
+// all pieces on the board the phase is totalPhase.
+var phaseWeights = map[PieceType]int{Knight: 1, Bishop: 1, Rook: 2, Queen: 4}
+
+const totalPhase = 24
+
+// evalWeights are the weights used by Evaluate
+var evalWeights = DefaultEvalWeights()
+
+// SetEvalWeights replaces the weights used by Evaluate, to tune them.
+func SetEvalWeights(weights *EvalWeights) {
+	evalWeights = weights
+}
+
+// Evaluate scores the position in centipawns from White's point of view.
+func Evaluate(g *Game) int {
+	return evalWeights.Evaluate(g)
+}
+
+// pieceWeights are the weights of each piece in a weights file, read apart
+// so that they can be merged onto the defaults
+type pieceWeights struct {
+	Material    map[PieceType]json.RawMessage
+	PieceSquare map[PieceType]json.RawMessage
+	Mobility    map[PieceType]json.RawMessage
+}
+
+// LoadEvalWeights reads weights from a JSON file. Weights missing from the
+// file keep their default value, down to the middlegame or endgame value of
+// a piece.
+func LoadEvalWeights(path string) (*EvalWeights, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	weights := DefaultEvalWeights()
+	if err := json.Unmarshal(data, weights); err != nil {
+		return nil, err
+	}
+
+	// Unmarshal replaces the weights of a piece whole, so they are read
+	// again onto the defaults
+	var pieces pieceWeights
+	if err := json.Unmarshal(data, &pieces); err != nil {
+		return nil, err
+	}
+	defaults := DefaultEvalWeights()
+	for piece, raw := range pieces.Material {
+		weight := defaults.Material[piece]
+		if err := json.Unmarshal(raw, &weight); err != nil {
+			return nil, err
+		}
+		weights.Material[piece] = weight
+	}
+	for piece, raw := range pieces.PieceSquare {
+		table := defaults.PieceSquare[piece]
+		if err := json.Unmarshal(raw, &table); err != nil {
+			return nil, err
+		}
+		weights.PieceSquare[piece] = table
+	}
+	for piece, raw := range pieces.Mobility {
+		weight := defaults.Mobility[piece]
+		if err := json.Unmarshal(raw, &weight); err != nil {
+			return nil, err
+		}
+		weights.Mobility[piece] = weight
+	}
+	return weights, nil
+}
+
+// Evaluate scores the position in centipawns from White's point of view,
+// tapered between the middlegame and endgame weights.
+func (w *EvalWeights) Evaluate(g *Game) int {
+	var score Phased
+	add := func(color PieceColor, weight Phased, times int) {
+		if color == Black {
+			times = -times
+		}
+		score.Middlegame += weight.Middlegame * times
+		score.Endgame += weight.Endgame * times
+	}
+
+	phase := 0
+	var pawns [2][8][]int
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 8; y++ {
+			piece := g.Board[x][y]
+			if piece == nil {
+				continue
+			}
+
+			add(piece.Color, w.Material[piece.Type], 1)
+			table := w.PieceSquare[piece.Type]
+			square := pieceSquare(piece.Color, x, y)
+			add(piece.Color, Phased{table.Middlegame[square], table.Endgame[square]}, 1)
+			phase += phaseWeights[piece.Type]
+
+			switch piece.Type {
+			case Pawn:
+				pawns[colorIndex(piece.Color)][x] = append(pawns[colorIndex(piece.Color)][x], y)
+			case Knight, Bishop, Rook, Queen:
+				mobility := 0
+				for _, to := range g.candidateSquares(x, y) {
+					if g.Board[to.X][to.Y] == nil || g.Board[to.X][to.Y].Color != piece.Color {
+						mobility++
+					}
+				}
+				add(piece.Color, w.Mobility[piece.Type], mobility)
+			}
+		}
+	}
+
+	for _, color := range []PieceColor{White, Black} {
+		own, enemy := pawns[colorIndex(color)], pawns[1-colorIndex(color)]
+		for file := 0; file < 8; file++ {
+			if len(own[file]) > 1 {
+				add(color, w.DoubledPawn, -(len(own[file]) - 1))
+			}
+			isolated := (file == 0 || len(own[file-1]) == 0) && (file == 7 || len(own[file+1]) == 0)
+			if isolated {
+				add(color, w.IsolatedPawn, -len(own[file]))
+			}
+			for _, rank := range own[file] {
+				if isPassed(color, file, rank, enemy) {
+					add(color, w.PassedPawn[relativeRank(color, rank)], 1)
+				}
+			}
+		}
+
+		kingX, kingY := g.FindKing(color)
+		forward := 1
+		if color == Black {
+			forward = -1
+		}
+		for file := kingX - 1; file <= kingX+1; file++ {
+			if file < 0 || file > 7 {
+				continue
+			}
+			for _, rank := range own[file] {
+				if rank == kingY+forward || rank == kingY+2*forward {
+					add(color, w.KingShield, 1)
+				}
+			}
+		}
+		if len(own[kingX]) == 0 {
+			add(color, w.KingOpenFile, -1)
+		}
+	}
+
+	if phase > totalPhase {
+		phase = totalPhase
+	}
+	return (score.Middlegame*phase + score.Endgame*(totalPhase-phase)) / totalPhase
+}
+
+func colorIndex(color PieceColor) int {
+	if color == Black {
+		return 1
+	}
+	return 0
+}
+
+// relativeRank counts the ranks from the player's side of the board, from 0.
+func relativeRank(color PieceColor, rank int) int {
+	if color == Black {
+		return 7 - rank
+	}
+	return rank
+}
+
+// pieceSquare returns the index of the square in a piece-square table.
+func pieceSquare(color PieceColor, x, y int) int {
+	return (7-relativeRank(color, y))*8 + x
+}
+
+// isPassed reports whether no enemy pawn can stop the pawn on its way to
+// promotion, on its own file or the files next to it.
+func isPassed(color PieceColor, file, rank int, enemy [8][]int) bool {
+	for f := file - 1; f <= file+1; f++ {
+		if f < 0 || f > 7 {
+			continue
+		}
+		for _, r := range enemy[f] {
+			if (color == White && r > rank) || (color == Black && r < rank) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// DefaultEvalWeights returns the weights the engine plays with out of the box.
+func DefaultEvalWeights() *EvalWeights {
+	return &EvalWeights{
+		Material: map[PieceType]Phased{
+			Pawn:   {82, 94},
+			Knight: {337, 281},
+			Bishop: {365, 297},
+			Rook:   {477, 512},
+			Queen:  {1025, 936},
+			King:   {0, 0},
+		},
+		PieceSquare: map[PieceType]PieceSquareTable{
+			Pawn: {
+				Middlegame: [64]int{
+					0, 0, 0, 0, 0, 0, 0, 0,
+					50, 50, 50, 50, 50, 50, 50, 50,
+					10, 10, 20, 30, 30, 20, 10, 10,
+					5, 5, 10, 25, 25, 10, 5, 5,
+					0, 0, 0, 20, 20, 0, 0, 0,
+					5, -5, -10, 0, 0, -10, -5, 5,
+					5, 10, 10, -20, -20, 10, 10, 5,
+					0, 0, 0, 0, 0, 0, 0, 0,
+				},
+				Endgame: [64]int{
+					0, 0, 0, 0, 0, 0, 0, 0,
+					80, 80, 80, 80, 80, 80, 80, 80,
+					50, 50, 50, 50, 50, 50, 50, 50,
+					30, 30, 30, 30, 30, 30, 30, 30,
+					20, 20, 20, 20, 20, 20, 20, 20,
+					10, 10, 10, 10, 10, 10, 10, 10,
+					5, 5, 5, 5, 5, 5, 5, 5,
+					0, 0, 0, 0, 0, 0, 0, 0,
+				},
+			},
+			Knight: {
+				Middlegame: knightTable,
+				Endgame:    knightTable,
+			},
+			Bishop: {
+				Middlegame: bishopTable,
+				Endgame:    bishopTable,
+			},
+			Rook: {
+				Middlegame: [64]int{
+					0, 0, 0, 0, 0, 0, 0, 0,
+					5, 10, 10, 10, 10, 10, 10, 5,
+					-5, 0, 0, 0, 0, 0, 0, -5,
+					-5, 0, 0, 0, 0, 0, 0, -5,
+					-5, 0, 0, 0, 0, 0, 0, -5,
+					-5, 0, 0, 0, 0, 0, 0, -5,
+					-5, 0, 0, 0, 0, 0, 0, -5,
+					0, 0, 0, 5, 5, 0, 0, 0,
+				},
+			},
+			Queen: {
+				Middlegame: [64]int{
+					-20, -10, -10, -5, -5, -10, -10, -20,
+					-10, 0, 0, 0, 0, 0, 0, -10,
+					-10, 0, 5, 5, 5, 5, 0, -10,
+					-5, 0, 5, 5, 5, 5, 0, -5,
+					0, 0, 5, 5, 5, 5, 0, -5,
+					-10, 5, 5, 5, 5, 5, 0, -10,
+					-10, 0, 5, 0, 0, 0, 0, -10,
+					-20, -10, -10, -5, -5, -10, -10, -20,
+				},
+			},
+			King: {
+				Middlegame: [64]int{
+					-30, -40, -40, -50, -50, -40, -40, -30,
+					-30, -40, -40, -50, -50, -40, -40, -30,
+					-30, -40, -40, -50, -50, -40, -40, -30,
+					-30, -40, -40, -50, -50, -40, -40, -30,
+					-20, -30, -30, -40, -40, -30, -30, -20,
+					-10, -20, -20, -20, -20, -20, -20, -10,
+					20, 20, 0, 0, 0, 0, 20, 20,
+					20, 30, 10, 0, 0, 10, 30, 20,
+				},
+				// The king belongs in the centre once the queens are off
+				Endgame: [64]int{
+					-50, -40, -30, -20, -20, -30, -40, -50,
+					-30, -20, -10, 0, 0, -10, -20, -30,
+					-30, -10, 20, 30, 30, 20, -10, -30,
+					-30, -10, 30, 40, 40, 30, -10, -30,
+					-30, -10, 30, 40, 40, 30, -10, -30,
+					-30, -10, 20, 30, 30, 20, -10, -30,
+					-30, -30, 0, 0, 0, 0, -30, -30,
+					-50, -30, -30, -30, -30, -30, -30, -50,
+				},
+			},
+		},
+		Mobility: map[PieceType]Phased{
+			Knight: {4, 4},
+			Bishop: {3, 5},
+			Rook:   {2, 4},
+			Queen:  {1, 2},
+		},
+		DoubledPawn:  Phased{10, 20},
+		IsolatedPawn: Phased{10, 15},
+		PassedPawn: [8]Phased{
+			{0, 0}, {5, 10}, {10, 20}, {15, 35}, {25, 60}, {40, 100}, {60, 150}, {0, 0},
+		},
+		KingShield:   Phased{10, 0},
+		KingOpenFile: Phased{25, 0},
+	}
+}
+
+var knightTable = [64]int{
+	-50, -40, -30, -30, -30, -30, -40, -50,
+	-40, -20, 0, 0, 0, 0, -20, -40,
+	-30, 0, 10, 15, 15, 10, 0, -30,
+	-30, 5, 15, 20, 20, 15, 5, -30,
+	-30, 0, 15, 20, 20, 15, 0, -30,
+	-30, 5, 10, 15, 15, 10, 5, -30,
+	-40, -20, 0, 5, 5, 0, -20, -40,
+	-50, -40, -30, -30, -30, -30, -40, -50,
+}
+
+var bishopTable = [64]int{
+	-20, -10, -10, -10, -10, -10, -10, -20,
+	-10, 0, 0, 0, 0, 0, 0, -10,
+	-10, 0, 5, 10, 10, 5, 0, -10,
+	-10, 5, 5, 10, 10, 5, 5, -10,
+	-10, 0, 10, 10, 10, 10, 0, -10,
+	-10, 10, 10, 10, 10, 10, 10, -10,
+	-10, 5, 0, 0, 0, 0, 5, -10,
+	-20, -10, -10, -10, -10, -10, -10, -20,
+}
@@ -0,0 +1,218 @@
+package gochess
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// mirror flips the board so White's pieces become Black's and the other way round.
+func mirror(g *Game) *Game {
+	mirrored := &Game{State: Ongoing, History: []Move{}}
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 8; y++ {
+			if piece := g.Board[x][y]; piece != nil {
+				color := White
+				if piece.Color == White {
+					color = Black
+				}
+				mirrored.Board[x][7-y] = &Piece{Type: piece.Type, Color: color}
+			}
+		}
+	}
+	return mirrored
+}
+
+func TestEvaluateTerms(t *testing.T) {
+	kings := func(pieces map[[2]int]*Piece) map[[2]int]*Piece {
+		if _, ok := pieces[[2]int{4, 0}]; !ok {
+			pieces[[2]int{4, 0}] = &Piece{Type: King, Color: White}
+		}
+		if _, ok := pieces[[2]int{4, 7}]; !ok {
+			pieces[[2]int{4, 7}] = &Piece{Type: King, Color: Black}
+		}
+		return pieces
+	}
+
+	tests := []struct {
+		name    string
+		weights *EvalWeights
+		pieces  map[[2]int]*Piece
+		want    int
+	}{
+		{
+			name:    "Material tapers to the endgame value",
+			weights: &EvalWeights{Material: map[PieceType]Phased{Queen: {1000, 900}}},
+			pieces: kings(map[[2]int]*Piece{
+				{3, 0}: {Type: Queen, Color: White},
+			}),
+			// A queen is 4 of the 24 points of the middlegame
+			want: (1000*4 + 900*20) / 24,
+		},
+		{
+			name: "Centralised king in the endgame",
+			weights: &EvalWeights{PieceSquare: map[PieceType]PieceSquareTable{
+				King: DefaultEvalWeights().PieceSquare[King],
+			}},
+			pieces: map[[2]int]*Piece{
+				{4, 3}: {Type: King, Color: White},
+				{0, 7}: {Type: King, Color: Black},
+			},
+			want: 40 - -50,
+		},
+		{
+			name:    "Doubled pawns",
+			weights: &EvalWeights{DoubledPawn: Phased{10, 20}},
+			pieces: kings(map[[2]int]*Piece{
+				{0, 1}: {Type: Pawn, Color: White},
+				{0, 2}: {Type: Pawn, Color: White},
+				{1, 1}: {Type: Pawn, Color: White},
+				{0, 6}: {Type: Pawn, Color: Black},
+				{1, 6}: {Type: Pawn, Color: Black},
+			}),
+			want: -20,
+		},
+		{
+			name:    "Isolated pawn",
+			weights: &EvalWeights{IsolatedPawn: Phased{10, 15}},
+			pieces: kings(map[[2]int]*Piece{
+				{0, 1}: {Type: Pawn, Color: White},
+				{2, 1}: {Type: Pawn, Color: White},
+				{3, 1}: {Type: Pawn, Color: White},
+				{2, 6}: {Type: Pawn, Color: Black},
+				{3, 6}: {Type: Pawn, Color: Black},
+			}),
+			want: -15,
+		},
+		{
+			name:    "Passed pawns by rank",
+			weights: &EvalWeights{PassedPawn: DefaultEvalWeights().PassedPawn},
+			pieces: kings(map[[2]int]*Piece{
+				{4, 5}: {Type: Pawn, Color: White},
+				{0, 6}: {Type: Pawn, Color: Black},
+			}),
+			want: 100 - 10,
+		},
+		{
+			name:    "Pawn stopped by a pawn on the next file",
+			weights: &EvalWeights{PassedPawn: DefaultEvalWeights().PassedPawn},
+			pieces: kings(map[[2]int]*Piece{
+				{4, 4}: {Type: Pawn, Color: White},
+				{3, 6}: {Type: Pawn, Color: Black},
+			}),
+			want: 0,
+		},
+		{
+			name:    "King behind a pawn shield against a king on an open file",
+			weights: &EvalWeights{KingShield: Phased{10, 0}, KingOpenFile: Phased{25, 0}},
+			pieces: map[[2]int]*Piece{
+				{6, 0}: {Type: King, Color: White},
+				{5, 1}: {Type: Pawn, Color: White},
+				{6, 1}: {Type: Pawn, Color: White},
+				{7, 2}: {Type: Pawn, Color: White},
+				{0, 3}: {Type: Queen, Color: White},
+				{4, 7}: {Type: King, Color: Black},
+				{7, 4}: {Type: Queen, Color: Black},
+			},
+			// The queens are 8 of the 24 points of the middlegame
+			want: (3*10 + 25) * 8 / 24,
+		},
+		{
+			name:    "Knight in the corner against a centralised knight",
+			weights: &EvalWeights{Mobility: map[PieceType]Phased{Knight: {4, 4}}},
+			pieces: kings(map[[2]int]*Piece{
+				{0, 0}: {Type: Knight, Color: White},
+				{4, 3}: {Type: Knight, Color: Black},
+			}),
+			want: 2*4 - 8*4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := positionGame(tt.pieces, White)
+			if got := tt.weights.Evaluate(g); got != tt.want {
+				t.Errorf("Evaluate() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateKnownPositions(t *testing.T) {
+	start := NewGame("alice", "bob")
+	if got := Evaluate(start); got != 0 {
+		t.Errorf("Evaluate() of the starting position = %d, want 0", got)
+	}
+
+	tests := []struct {
+		name   string
+		moves  []string
+		better bool
+	}{
+		{"1. e4 takes the centre", []string{"e2e4"}, true},
+		{"1. a4 weakens the queenside", []string{"a2a4", "e7e5"}, false},
+		{"Black wins a pawn", []string{"e2e4", "d7d5", "d2d3", "d5e4", "a2a3"}, false},
+		{"White wins a knight", []string{"e2e4", "g8f6", "e4e5", "f6d5", "c2c4", "d5b6", "c4c5", "e7e6", "c5b6"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGame("alice", "bob")
+			playMoves(t, g, tt.moves...)
+			got := Evaluate(g)
+			if tt.better && got <= 0 {
+				t.Errorf("Evaluate() = %d, want White better", got)
+			}
+			if !tt.better && got >= 0 {
+				t.Errorf("Evaluate() = %d, want Black better", got)
+			}
+			if mirrored := Evaluate(mirror(g)); mirrored != -got {
+				t.Errorf("Evaluate() of the mirrored position = %d, want %d", mirrored, -got)
+			}
+		})
+	}
+}
+
+func TestLoadEvalWeights(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "weights.json")
+	config := `{"Material": {"Pawn": {"Middlegame": 150, "Endgame": 200}, "Knight": {"Middlegame": 290}}, "Mobility": {"Rook": {"Endgame": 7}}, "PieceSquare": {"King": {"Endgame": []}}, "DoubledPawn": {"Middlegame": 0, "Endgame": 50}}`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	weights, err := LoadEvalWeights(path)
+	if err != nil {
+		t.Fatalf("LoadEvalWeights() error = %v", err)
+	}
+	defaults := DefaultEvalWeights()
+	if got := weights.Material[Pawn]; got != (Phased{150, 200}) {
+		t.Errorf("Material[Pawn] = %v, want {150 200}", got)
+	}
+	if got := weights.Material[Queen]; got != defaults.Material[Queen] {
+		t.Errorf("Material[Queen] = %v, want the default %v", got, defaults.Material[Queen])
+	}
+	if got := weights.DoubledPawn; got != (Phased{0, 50}) {
+		t.Errorf("DoubledPawn = %v, want {0 50}", got)
+	}
+	if weights.PieceSquare[Queen] != defaults.PieceSquare[Queen] {
+		t.Error("PieceSquare[Queen] was not kept at its default")
+	}
+
+	// A piece's weights given in part keep their other defaults
+	if got, want := weights.Material[Knight], (Phased{290, defaults.Material[Knight].Endgame}); got != want {
+		t.Errorf("Material[Knight] = %v, want %v", got, want)
+	}
+	if got, want := weights.Mobility[Rook], (Phased{defaults.Mobility[Rook].Middlegame, 7}); got != want {
+		t.Errorf("Mobility[Rook] = %v, want %v", got, want)
+	}
+	if king := weights.PieceSquare[King]; king.Middlegame != defaults.PieceSquare[King].Middlegame || king.Endgame != [64]int{} {
+		t.Errorf("PieceSquare[King] = %v, want the default middlegame table and an empty endgame table", king)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadEvalWeights(path); err == nil {
+		t.Error("LoadEvalWeights() of invalid JSON should fail")
+	}
+}
@@ -0,0 +1,261 @@
+package gochess
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type GameEventType string
+
+const (
+	EventCreated    GameEventType = "Created"
+	EventJoined     GameEventType = "Joined"
+	EventMoved      GameEventType = "Moved"
+	EventUndone     GameEventType = "Undone"
+	EventResigned   GameEventType = "Resigned"
+	EventDrawAgreed GameEventType = "DrawAgreed"
+	EventPromoted   GameEventType = "Promoted"
+	EventClockTick  GameEventType = "ClockTick"
+	EventChat       GameEventType = "Chat"
+	EventDeadline   GameEventType = "Deadline"
+	EventForfeited  GameEventType = "Forfeited"
+	EventTimedOut   GameEventType = "TimedOut"
+	EventRematched  GameEventType = "Rematched"
+	EventMuted      GameEventType = "Muted"
+	EventUnmuted    GameEventType = "Unmuted"
+)
+
+// GameEvent is a single entry in the append-only log of a game. Only the
+// fields relevant to the event type are set.
+type GameEvent struct {
+	Type GameEventType
+	Time time.Time
+
+	// Created, and Rematched for the game started as the rematch
+	GameID         string            `json:",omitempty"`
+	Casual         bool              `json:",omitempty"`
+	SpectatorDelay time.Duration     `json:",omitempty"`
+	Series         *Series           `json:",omitempty"`
+	Computer       *ComputerOpponent `json:",omitempty"`
+	// FEN is the starting position of a game created from a position other
+	// than the standard one
+	FEN      string `json:",omitempty"`
+	Chess960 bool   `json:",omitempty"`
+	// Created and Joined
+	Players [2]Player
+	// Created and ClockTick
+	Clock *Clock `json:",omitempty"`
+	// Moved
+	From Position
+	To   Position
+	// Moved and Promoted
+	Promotion PieceType `json:",omitempty"`
+	// Created and Deadline
+	Correspondence *Correspondence `json:",omitempty"`
+	// Resigned, Forfeited and TimedOut
+	Color PieceColor `json:",omitempty"`
+	// Chat
+	Chat *ChatMessage `json:",omitempty"`
+	// Muted and Unmuted
+	Mute *ChatMute `json:",omitempty"`
+}
+
+// EventLog stores the events of each game in the order they happened.
+type EventLog interface {
+	AppendEvents(gameID string, events ...GameEvent) error
+	Events(gameID string) ([]GameEvent, error)
+}
+
+// CreatedEvent records the initial setup of a game.
+func CreatedEvent(g *Game) GameEvent {
+	event := GameEvent{Type: EventCreated, Time: timeNow(), GameID: g.ID, Players: g.Players, Casual: g.Casual, SpectatorDelay: g.SpectatorDelay, Series: g.Series, Computer: g.Computer, Chess960: g.Chess960}
+	if g.Clock != nil {
+		event.Clock = NewClock(g.Clock.Initial, g.Clock.Increment)
+	}
+	if g.Correspondence != nil {
+		event.Correspondence = copyCorrespondence(g.Correspondence)
+	}
+	if fen := g.FEN(); fen != StartFEN {
+		event.FEN = fen
+	}
+	return event
+}
+
+// MovedEvent records the last move of the game, including its promotion if
+// one has been chosen.
+func MovedEvent(g *Game) GameEvent {
+	last := g.History[len(g.History)-1]
+	return GameEvent{Type: EventMoved, Time: timeNow(), From: last.From, To: last.To, Promotion: last.Promotion}
+}
+
+// PromotedEvent records the piece chosen for a pawn that reached the last rank.
+func PromotedEvent(g *Game) GameEvent {
+	return GameEvent{Type: EventPromoted, Time: timeNow(), Promotion: g.History[len(g.History)-1].Promotion}
+}
+
+// ClockTickEvent records the time left on the clock of the game.
+func ClockTickEvent(g *Game) GameEvent {
+	clock := *g.Clock
+	return GameEvent{Type: EventClockTick, Time: timeNow(), Clock: &clock}
+}
+
+// DeadlineEvent records the deadlines, vacation and premoves of a
+// correspondence game.
+func DeadlineEvent(g *Game) GameEvent {
+	return GameEvent{Type: EventDeadline, Time: timeNow(), Correspondence: copyCorrespondence(g.Correspondence)}
+}
+
+func copyCorrespondence(c *Correspondence) *Correspondence {
+	copied := &Correspondence{
+		DaysPerMove:  c.DaysPerMove,
+		VacationDays: c.VacationDays,
+		VacationLeft: map[PieceColor]int{},
+		Vacation:     map[PieceColor]time.Duration{},
+		LastMoveAt:   c.LastMoveAt,
+		Conditionals: map[PieceColor][][]string{},
+	}
+	for color, days := range c.VacationLeft {
+		copied.VacationLeft[color] = days
+	}
+	for color, vacation := range c.Vacation {
+		copied.Vacation[color] = vacation
+	}
+	for color, lines := range c.Conditionals {
+		copied.Conditionals[color] = append([][]string{}, lines...)
+	}
+	return copied
+}
+
+// GameLog is the full list of events of a single game.
+type GameLog []GameEvent
+
+// Replay rebuilds the game by applying every event in the log.
+func (log GameLog) Replay() (*Game, error) {
+	if len(log) == 0 || log[0].Type != EventCreated {
+		return nil, errors.New("game log must start with a created event")
+	}
+
+	created := log[0]
+	g, err := created.startPosition()
+	if err != nil {
+		return nil, err
+	}
+	g.Casual = created.Casual
+	g.SpectatorDelay = created.SpectatorDelay
+	g.Series = created.Series
+	g.Computer = created.Computer
+	if created.Clock != nil {
+		// The clock is restored from the ticks rather than run while replaying
+		clock := *created.Clock
+		g.Clock = &clock
+	}
+	if created.Correspondence != nil {
+		g.Correspondence = copyCorrespondence(created.Correspondence)
+	}
+
+	for i, event := range log[1:] {
+		if err := g.apply(event); err != nil {
+			return nil, fmt.Errorf("replaying event %d (%s): %w", i+1, event.Type, err)
+		}
+	}
+
+	if g.Clock != nil {
+		g.SetClock(g.Clock)
+	}
+	if g.Correspondence != nil {
+		g.SetCorrespondence(g.Correspondence)
+	}
+	return g, nil
+}
+
+// ReplayTo rebuilds the position after the given number of half-moves of the
+// game as it was finally played, so takebacks do not count.
+func (log GameLog) ReplayTo(ply int) (*Game, error) {
+	final, err := log.Replay()
+	if err != nil {
+		return nil, err
+	}
+	if ply < 0 || ply > len(final.History) {
+		return nil, fmt.Errorf("ply %d out of range, game has %d moves", ply, len(final.History))
+	}
+
+	g, err := log[0].startPosition()
+	if err != nil {
+		return nil, err
+	}
+	for _, move := range final.History[:ply] {
+		err := g.apply(GameEvent{Type: EventMoved, From: move.From, To: move.To, Promotion: move.Promotion})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// startPosition sets up the game of a created event before any move.
+func (created GameEvent) startPosition() (*Game, error) {
+	g := NewGame(created.Players[0].Name, created.Players[1].Name)
+	if created.FEN != "" {
+		var err error
+		if g, err = ParseFEN(created.FEN); err != nil {
+			return nil, err
+		}
+		g.Players = created.Players
+	}
+	// Chess960 games castle by the king taking its rook, even from the
+	// standard starting position
+	g.Chess960 = created.Chess960
+	g.ID = created.GameID
+	return g, nil
+}
+
+func (g *Game) apply(event GameEvent) error {
+	switch event.Type {
+	case EventJoined:
+		g.Players = event.Players
+		return nil
+	case EventMoved:
+		if err := g.MovePiece(event.From.X, event.From.Y, event.To.X, event.To.Y); err != nil {
+			return err
+		}
+		if event.Promotion != "" {
+			return g.PromotePawn(event.Promotion)
+		}
+		return nil
+	case EventPromoted:
+		return g.PromotePawn(event.Promotion)
+	case EventUndone:
+		return g.Undo()
+	case EventResigned:
+		return g.Resign(event.Color)
+	case EventDrawAgreed:
+		return g.AgreeDraw()
+	case EventClockTick:
+		if g.Clock == nil || event.Clock == nil {
+			return errors.New("clock tick for a game without a clock")
+		}
+		g.Clock.White = event.Clock.White
+		g.Clock.Black = event.Clock.Black
+		g.Clock.LastMoveAt = event.Clock.LastMoveAt
+		return nil
+	case EventDeadline:
+		if g.Correspondence == nil || event.Correspondence == nil {
+			return errors.New("deadline for a game without correspondence time control")
+		}
+		g.Correspondence = copyCorrespondence(event.Correspondence)
+		return nil
+	case EventForfeited:
+		return g.Forfeit(event.Color)
+	case EventTimedOut:
+		return g.TimeOut(event.Color)
+	case EventRematched:
+		g.Rematch = event.GameID
+		return nil
+	case EventChat, EventMuted, EventUnmuted:
+		// Chat messages and mutes do not change the game
+		return nil
+	default:
+		return errors.New("unknown event type: " + string(event.Type))
+	}
+}
@@ -0,0 +1,168 @@
+package gochess
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGameLogReplay(t *testing.T) {
+	g := NewGame("Alice", "Bob")
+	g.SetClock(NewClock(time.Minute, 0))
+	log := GameLog{CreatedEvent(g)}
+
+	for _, move := range []string{"e2e4", "e7e5", "d1h5", "b8c6"} {
+		playMoves(t, g, move)
+		log = append(log, MovedEvent(g), ClockTickEvent(g))
+	}
+	if err := g.Undo(); err != nil {
+		t.Fatalf("Undo() error = %v", err)
+	}
+	log = append(log, GameEvent{Type: EventUndone})
+	playMoves(t, g, "g8f6")
+	log = append(log, MovedEvent(g), ClockTickEvent(g))
+	if err := g.Resign(White); err != nil {
+		t.Fatalf("Resign() error = %v", err)
+	}
+	log = append(log, GameEvent{Type: EventResigned, Color: White})
+
+	replayed, err := log.Replay()
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	if replayed.ID != g.ID || replayed.Players != g.Players {
+		t.Errorf("Expected game %v with players %v, but got %v with %v", g.ID, g.Players, replayed.ID, replayed.Players)
+	}
+	if !sameBoard(replayed.Board, g.Board) {
+		t.Errorf("Expected replayed board to match the played board")
+	}
+	if replayed.State != BlackWon {
+		t.Errorf("Expected game state to be %v, but got %v", BlackWon, replayed.State)
+	}
+	if replayed.Clock == nil || replayed.Clock.White != g.Clock.White || replayed.Clock.Black != g.Clock.Black {
+		t.Errorf("Expected clock %+v, but got %+v", g.Clock, replayed.Clock)
+	}
+
+	tests := []struct {
+		name  string
+		ply   int
+		x, y  int
+		piece *Piece
+	}{
+		{name: "start position", ply: 0, x: 4, y: 1, piece: &Piece{Type: Pawn, Color: White}},
+		{name: "after the queen move", ply: 3, x: 7, y: 4, piece: &Piece{Type: Queen, Color: White}},
+		{name: "undone move is not replayed", ply: 4, x: 2, y: 5, piece: nil},
+		{name: "move played after the undo", ply: 4, x: 5, y: 5, piece: &Piece{Type: Knight, Color: Black}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			position, err := log.ReplayTo(tt.ply)
+			if err != nil {
+				t.Fatalf("ReplayTo() error = %v", err)
+			}
+			if len(position.History) != tt.ply {
+				t.Errorf("Expected %d moves, but got %d", tt.ply, len(position.History))
+			}
+			got := position.Board[tt.x][tt.y]
+			if (got == nil) != (tt.piece == nil) || (got != nil && *got != *tt.piece) {
+				t.Errorf("Expected %v at (%d, %d), but got %v", tt.piece, tt.x, tt.y, got)
+			}
+		})
+	}
+
+	if _, err := log.ReplayTo(6); err == nil {
+		t.Errorf("Expected an error replaying past the end of the game")
+	}
+}
+
+func TestReplayFromPosition(t *testing.T) {
+	fen := "4k3/8/8/8/8/8/4P3/4K3 b - - 3 40"
+	g, err := ParseFEN(fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Players = [2]Player{{Name: "Alice", Color: White}, {Name: "Bob", Color: Black}}
+	log := GameLog{CreatedEvent(g)}
+	for _, move := range []string{"e8d7", "e2e4"} {
+		playMoves(t, g, move)
+		log = append(log, MovedEvent(g))
+	}
+
+	replayed, err := log.Replay()
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	if replayed.FEN() != g.FEN() || replayed.Players != g.Players || replayed.ID != g.ID {
+		t.Errorf("Expected %s between %v, but got %s between %v", g.FEN(), g.Players, replayed.FEN(), replayed.Players)
+	}
+	if start, err := log.ReplayTo(0); err != nil || start.FEN() != fen {
+		t.Errorf("Expected the game to start from %s, but got %v %v", fen, start, err)
+	}
+}
+
+func TestReplayChess960(t *testing.T) {
+	// Position 518 is the standard starting position, but castles as Chess960
+	g, err := NewGame960(518)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := GameLog{CreatedEvent(g)}
+	for _, move := range []string{"g1f3", "a7a6", "e2e3", "a6a5", "f1e2", "a5a4", "e1h1"} {
+		playMoves(t, g, move)
+		log = append(log, MovedEvent(g))
+	}
+
+	replayed, err := log.Replay()
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	if !replayed.Chess960 || replayed.FEN() != g.FEN() {
+		t.Errorf("Expected the Chess960 game %s, but got %s", g.FEN(), replayed.FEN())
+	}
+
+	g, _ = NewGame960(0)
+	replayed, err = GameLog{CreatedEvent(g)}.Replay()
+	if err != nil || !replayed.Chess960 || replayed.ShredderFEN() != g.ShredderFEN() {
+		t.Errorf("Expected the Chess960 position %s, but got %v %v", g.ShredderFEN(), replayed, err)
+	}
+}
+
+func TestUndoPromotion(t *testing.T) {
+	g := &Game{
+		Board: createBoardWithPieces(map[[2]int]*Piece{
+			{0, 6}: {Color: White, Type: Pawn},
+			{1, 7}: {Color: Black, Type: Rook},
+			{4, 0}: {Color: White, Type: King},
+			{7, 5}: {Color: Black, Type: King},
+		}),
+		State: Ongoing,
+	}
+	playMoves(t, g, "a7b8")
+	if err := g.PromotePawn(Knight); err != nil {
+		t.Fatalf("PromotePawn() error = %v", err)
+	}
+	if err := g.Undo(); err != nil {
+		t.Fatalf("Undo() error = %v", err)
+	}
+
+	if piece := g.Board[0][6]; piece == nil || *piece != (Piece{Type: Pawn, Color: White}) {
+		t.Errorf("Expected a white pawn back on a7, but got %v", piece)
+	}
+	if piece := g.Board[1][7]; piece == nil || *piece != (Piece{Type: Rook, Color: Black}) {
+		t.Errorf("Expected the black rook back on b8, but got %v", piece)
+	}
+	if len(g.History) != 0 {
+		t.Errorf("Expected an empty history, but got %v", g.History)
+	}
+}
+
+func sameBoard(a, b Board) bool {
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 8; y++ {
+			if (a[x][y] == nil) != (b[x][y] == nil) || (a[x][y] != nil && *a[x][y] != *b[x][y]) {
+				return false
+			}
+		}
+	}
+	return true
+}
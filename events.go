@@ -0,0 +1,63 @@
+package gochess
+
+// GameListener is notified about things that happen inside a Game, so other
+// parts of the program can react to moves without touching the rules code.
+type GameListener interface {
+	// OnMove is called after a piece has been moved and recorded in the history.
+	OnMove(g *Game, move Move)
+	// OnCheck is called when the player about to move is in check.
+	OnCheck(g *Game, color PieceColor)
+	// OnGameOver is called once the game has reached a final state.
+	OnGameOver(g *Game, state GameState)
+	// OnPromotionPending is called when a pawn reaches the last rank and the
+	// player has to choose a piece with PromotePawn.
+	OnPromotionPending(g *Game, color PieceColor)
+}
+
+// NopGameListener implements GameListener with empty methods. Embed it to
+// only handle the events you care about.
+type NopGameListener struct{}
+
+func (NopGameListener) OnMove(g *Game, move Move)                    {}
+func (NopGameListener) OnCheck(g *Game, color PieceColor)            {}
+func (NopGameListener) OnGameOver(g *Game, state GameState)          {}
+func (NopGameListener) OnPromotionPending(g *Game, color PieceColor) {}
+
+// AddListener registers a listener to be notified of game events.
+func (g *Game) AddListener(l GameListener) {
+	g.listeners = append(g.listeners, l)
+}
+
+// RemoveListener unregisters a previously added listener.
+func (g *Game) RemoveListener(l GameListener) {
+	for i, listener := range g.listeners {
+		if listener == l {
+			g.listeners = append(g.listeners[:i], g.listeners[i+1:]...)
+			return
+		}
+	}
+}
+
+func (g *Game) notifyMove(move Move) {
+	for _, l := range g.listeners {
+		l.OnMove(g, move)
+	}
+}
+
+func (g *Game) notifyCheck(color PieceColor) {
+	for _, l := range g.listeners {
+		l.OnCheck(g, color)
+	}
+}
+
+func (g *Game) notifyGameOver(state GameState) {
+	for _, l := range g.listeners {
+		l.OnGameOver(g, state)
+	}
+}
+
+func (g *Game) notifyPromotionPending(color PieceColor) {
+	for _, l := range g.listeners {
+		l.OnPromotionPending(g, color)
+	}
+}
@@ -0,0 +1,109 @@
+package gochess
+
+import "testing"
+
+type recordingListener struct {
+	moves      []Move
+	checks     []PieceColor
+	gameOvers  []GameState
+	promotions []PieceColor
+}
+
+func (l *recordingListener) OnMove(g *Game, move Move) {
+	l.moves = append(l.moves, move)
+}
+
+func (l *recordingListener) OnCheck(g *Game, color PieceColor) {
+	l.checks = append(l.checks, color)
+}
+
+func (l *recordingListener) OnGameOver(g *Game, state GameState) {
+	l.gameOvers = append(l.gameOvers, state)
+}
+
+func (l *recordingListener) OnPromotionPending(g *Game, color PieceColor) {
+	l.promotions = append(l.promotions, color)
+}
+
+func playMoves(t *testing.T, g *Game, moves ...string) {
+	t.Helper()
+	for _, move := range moves {
+		source, target, err := notationToCoordinates(move)
+		if err != nil {
+			t.Fatalf("notationToCoordinates(%q) error = %v", move, err)
+		}
+		if err := g.MovePiece(source[0], source[1], target[0], target[1]); err != nil {
+			t.Fatalf("MovePiece(%q) error = %v", move, err)
+		}
+	}
+}
+
+func TestListenerFoolsMate(t *testing.T) {
+	g := NewGame("Alice", "Bob")
+	l := &recordingListener{}
+	g.AddListener(l)
+
+	playMoves(t, g, "f2f3", "e7e5", "g2g4", "d8h4")
+
+	if len(l.moves) != 4 {
+		t.Errorf("Expected 4 moves, but got %d", len(l.moves))
+	}
+	if len(l.checks) != 1 || l.checks[0] != White {
+		t.Errorf("Expected White to be in check once, but got %v", l.checks)
+	}
+	if len(l.gameOvers) != 1 || l.gameOvers[0] != BlackWon {
+		t.Errorf("Expected game over with %v, but got %v", BlackWon, l.gameOvers)
+	}
+}
+
+func TestListenerPromotion(t *testing.T) {
+	g := &Game{
+		Board: createBoardWithPieces(map[[2]int]*Piece{
+			{0, 6}: {Color: White, Type: Pawn},
+			{4, 0}: {Color: White, Type: King},
+			{7, 7}: {Color: Black, Type: King},
+		}),
+		State: Ongoing,
+	}
+	l := &recordingListener{}
+	g.AddListener(l)
+
+	playMoves(t, g, "a7a8")
+
+	if g.State != PromoteWhite {
+		t.Fatalf("Expected game state to be %v, but got %v", PromoteWhite, g.State)
+	}
+	if len(l.promotions) != 1 || l.promotions[0] != White {
+		t.Errorf("Expected a pending white promotion, but got %v", l.promotions)
+	}
+
+	if err := g.PromotePawn(King); err == nil {
+		t.Errorf("Expected an error promoting to a king")
+	}
+	if err := g.PromotePawn(Queen); err != nil {
+		t.Fatalf("PromotePawn() error = %v", err)
+	}
+
+	if g.State != Ongoing {
+		t.Errorf("Expected game state to be %v, but got %v", Ongoing, g.State)
+	}
+	if piece := g.Board[0][7]; piece == nil || piece.Type != Queen || piece.Color != White {
+		t.Errorf("Expected a white queen at a8, but got %v", piece)
+	}
+	if len(l.checks) != 1 || l.checks[0] != Black {
+		t.Errorf("Expected Black to be in check once, but got %v", l.checks)
+	}
+}
+
+func TestRemoveListener(t *testing.T) {
+	g := NewGame("Alice", "Bob")
+	l := &recordingListener{}
+	g.AddListener(l)
+	g.RemoveListener(l)
+
+	playMoves(t, g, "e2e4")
+
+	if len(l.moves) != 0 {
+		t.Errorf("Expected no moves after removing the listener, but got %v", l.moves)
+	}
+}
@@ -0,0 +1,336 @@
+package gochess
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StartFEN is the starting position in Forsyth-Edwards Notation
+const StartFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+var pieceLetters = map[PieceType]byte{Pawn: 'p', Knight: 'n', Bishop: 'b', Rook: 'r', Queen: 'q', King: 'k'}
+
+var castlingLetters = []struct {
+	right  CastlingRights
+	letter byte
+}{{WhiteKingside, 'K'}, {WhiteQueenside, 'Q'}, {BlackKingside, 'k'}, {BlackQueenside, 'q'}}
+
+// standardCastlingRooks are the files the rooks of the castling rights start
+// on in standard chess
+var standardCastlingRooks = [4]int{7, 0, 7, 0}
+
+// pieceFromLetter reads a piece in FEN, upper case for White.
+func pieceFromLetter(letter byte) (*Piece, bool) {
+	for pieceType, l := range pieceLetters {
+		if letter == l {
+			return &Piece{Type: pieceType, Color: Black}, true
+		}
+		if letter == l-('a'-'A') {
+			return &Piece{Type: pieceType, Color: White}, true
+		}
+	}
+	return nil, false
+}
+
+// squareName writes a square like e4.
+func squareName(p Position) string {
+	return string([]byte{byte('a' + p.X), byte('1' + p.Y)})
+}
+
+// ParseFEN sets up a game from a position in Forsyth-Edwards Notation. The
+// move counters can be left out.
+func ParseFEN(fen string) (*Game, error) {
+	fields := strings.Fields(fen)
+	if len(fields) != 4 && len(fields) != 6 {
+		return nil, fmt.Errorf("FEN needs 4 or 6 fields, got %d", len(fields))
+	}
+
+	g := &Game{
+		ID:      newGameID(),
+		Players: [2]Player{{Color: White}, {Color: Black}},
+		State:   Ongoing,
+		History: []Move{},
+	}
+
+	ranks := strings.Split(fields[0], "/")
+	if len(ranks) != 8 {
+		return nil, fmt.Errorf("FEN board needs 8 ranks, got %d", len(ranks))
+	}
+	kings := map[PieceColor]int{}
+	for i, rank := range ranks {
+		y, x := 7-i, 0
+		for j := 0; j < len(rank); j++ {
+			if rank[j] >= '1' && rank[j] <= '8' {
+				x += int(rank[j] - '0')
+				continue
+			}
+			piece, ok := pieceFromLetter(rank[j])
+			if !ok {
+				return nil, fmt.Errorf("invalid piece %q in FEN", rank[j])
+			}
+			if x > 7 {
+				return nil, fmt.Errorf("rank %d of the FEN has more than 8 squares", y+1)
+			}
+			if piece.Type == King {
+				kings[piece.Color]++
+			}
+			g.Board[x][y] = piece
+			x++
+		}
+		if x != 8 {
+			return nil, fmt.Errorf("rank %d of the FEN does not have 8 squares", y+1)
+		}
+	}
+	if kings[White] != 1 || kings[Black] != 1 {
+		return nil, errors.New("FEN needs one king of each color")
+	}
+
+	switch fields[1] {
+	case "w":
+		g.StartColor = White
+	case "b":
+		g.StartColor = Black
+	default:
+		return nil, fmt.Errorf("invalid color %q in FEN", fields[1])
+	}
+
+	g.CastlingRooks = standardCastlingRooks
+	if fields[2] != "-" {
+		for i := 0; i < len(fields[2]); i++ {
+			if !g.parseCastling(fields[2][i]) {
+				return nil, fmt.Errorf("invalid castling rights %q in FEN", fields[2])
+			}
+		}
+	}
+	// Rights without the king and rook on their squares cannot be used
+	for i, c := range castlingLetters {
+		if g.Castling&c.right == 0 {
+			continue
+		}
+		if !g.hasCastlingPieces(c.right) {
+			g.Castling &^= c.right
+			continue
+		}
+		// Anywhere else than in the corners, the king and rook castle by the
+		// rules of Chess960
+		if g.CastlingRooks[i] != standardCastlingRooks[i] || g.Board[4][homeRank(c.right)] == nil || g.Board[4][homeRank(c.right)].Type != King {
+			g.Chess960 = true
+		}
+	}
+
+	if fields[3] != "-" {
+		square := fields[3]
+		if len(square) != 2 || square[0] < 'a' || square[0] > 'h' || (square[1] != '3' && square[1] != '6') {
+			return nil, fmt.Errorf("invalid en passant square %q in FEN", square)
+		}
+		// Only kept when a pawn can take, like after a move
+		passed := Position{X: int(square[0] - 'a'), Y: 3}
+		color := White
+		if square[1] == '6' {
+			passed.Y, color = 4, Black
+		}
+		if g.pawnBeside(passed, color) {
+			g.EnPassant = &Position{X: passed.X, Y: int(square[1] - '1')}
+		}
+	}
+
+	if len(fields) == 6 {
+		halfmoves, err := strconv.Atoi(fields[4])
+		if err != nil || halfmoves < 0 {
+			return nil, fmt.Errorf("invalid halfmove clock %q in FEN", fields[4])
+		}
+		move, err := strconv.Atoi(fields[5])
+		if err != nil || move < 1 {
+			return nil, fmt.Errorf("invalid move number %q in FEN", fields[5])
+		}
+		g.HalfmoveClock = halfmoves
+		g.StartMove = move
+	}
+	return g, nil
+}
+
+// parseCastling reads a castling right of a FEN: K, Q, k or q for the
+// outermost rook on that side of the king, like X-FEN does for Chess960, or
+// the file of the rook, like Shredder-FEN. It reports whether the letter is
+// one of them.
+func (g *Game) parseCastling(letter byte) bool {
+	color, y, kingside, queenside, fileA := White, 0, WhiteKingside, WhiteQueenside, byte('A')
+	if letter >= 'a' && letter <= 'z' {
+		color, y, kingside, queenside, fileA = Black, 7, BlackKingside, BlackQueenside, 'a'
+	}
+	kingX := g.kingFile(color, y)
+	isRook := func(x int) bool {
+		piece := g.Board[x][y]
+		return piece != nil && piece.Type == Rook && piece.Color == color
+	}
+
+	right, rookX := kingside, -1
+	switch letter - fileA + 'A' {
+	case 'K':
+		for x := 7; x > kingX && rookX < 0; x-- {
+			if isRook(x) {
+				rookX = x
+			}
+		}
+	case 'Q':
+		right = queenside
+		for x := 0; x < kingX && rookX < 0; x++ {
+			if isRook(x) {
+				rookX = x
+			}
+		}
+	case 'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H':
+		rookX = int(letter - fileA)
+		if rookX < kingX {
+			right = queenside
+		}
+	default:
+		return false
+	}
+	// Rights without the king and the rook are dropped
+	if kingX >= 0 && rookX >= 0 {
+		g.Castling |= right
+		g.CastlingRooks[castlingIndex(right)] = rookX
+	}
+	return true
+}
+
+// outermostRook reports whether the rook of a castling right is the one
+// furthest from the king on its side.
+func (g *Game) outermostRook(right CastlingRights) bool {
+	y, rookX, step := homeRank(right), g.rookFile(right), 1
+	if right&(WhiteQueenside|BlackQueenside) != 0 {
+		step = -1
+	}
+	rook := g.Board[rookX][y]
+	for x := rookX + step; x >= 0 && x < 8; x += step {
+		if piece := g.Board[x][y]; piece != nil && piece.Type == Rook && piece.Color == rook.Color {
+			return false
+		}
+	}
+	return true
+}
+
+// kingFile returns the file of the player's king on the rank, or -1 if it is
+// not there.
+func (g *Game) kingFile(color PieceColor, y int) int {
+	for x := 0; x < 8; x++ {
+		if piece := g.Board[x][y]; piece != nil && piece.Type == King && piece.Color == color {
+			return x
+		}
+	}
+	return -1
+}
+
+// hasCastlingPieces reports whether the king and rook of a castling right are
+// on their starting squares.
+func (g *Game) hasCastlingPieces(right CastlingRights) bool {
+	color, y := White, homeRank(right)
+	if y == 7 {
+		color = Black
+	}
+	kingX, rookX := g.kingFile(color, y), g.CastlingRooks[castlingIndex(right)]
+	if kingX < 0 || (rookX > kingX) != (right&(WhiteKingside|BlackKingside) != 0) {
+		return false
+	}
+	rook := g.Board[rookX][y]
+	return rook != nil && rook.Type == Rook && rook.Color == color
+}
+
+// castlingIndex returns the index of a castling right in castlingLetters.
+func castlingIndex(right CastlingRights) int {
+	for i, c := range castlingLetters {
+		if c.right == right {
+			return i
+		}
+	}
+	return -1
+}
+
+// FEN writes the position in Forsyth-Edwards Notation. The castling rights
+// of a Chess960 game are written like X-FEN does, with the file of the rook
+// instead of K, Q, k or q when another rook stands further out.
+func (g *Game) FEN() string {
+	return g.fen(false)
+}
+
+// ShredderFEN writes the position in Forsyth-Edwards Notation with the
+// castling rights as the files of the rooks, like HAha for the starting
+// position.
+func (g *Game) ShredderFEN() string {
+	return g.fen(true)
+}
+
+func (g *Game) fen(shredder bool) string {
+	var fen strings.Builder
+	for y := 7; y >= 0; y-- {
+		empty := 0
+		for x := 0; x < 8; x++ {
+			piece := g.Board[x][y]
+			if piece == nil {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				fen.WriteByte(byte('0' + empty))
+				empty = 0
+			}
+			letter := pieceLetters[piece.Type]
+			if piece.Color == White {
+				letter -= 'a' - 'A'
+			}
+			fen.WriteByte(letter)
+		}
+		if empty > 0 {
+			fen.WriteByte(byte('0' + empty))
+		}
+		if y > 0 {
+			fen.WriteByte('/')
+		}
+	}
+
+	side := "w"
+	if g.GetCurrentPlayerColor() == Black {
+		side = "b"
+	}
+	castling := ""
+	for _, c := range castlingLetters {
+		if g.Castling&c.right == 0 {
+			continue
+		}
+		if !shredder && g.outermostRook(c.right) {
+			castling += string(c.letter)
+			continue
+		}
+		file := byte('A' + g.rookFile(c.right))
+		if homeRank(c.right) == 7 {
+			file += 'a' - 'A'
+		}
+		castling += string(file)
+	}
+	if castling == "" {
+		castling = "-"
+	}
+	enPassant := "-"
+	if g.EnPassant != nil {
+		enPassant = squareName(*g.EnPassant)
+	}
+
+	return fmt.Sprintf("%s %s %s %s %d %d", fen.String(), side, castling, enPassant, g.HalfmoveClock, g.ply()/2+1)
+}
+
+// ply counts the half-moves of the game, including those before the FEN
+// position it was set up from.
+func (g *Game) ply() int {
+	move := g.StartMove
+	if move == 0 {
+		move = 1
+	}
+	ply := 2*(move-1) + len(g.History)
+	if g.StartColor == Black {
+		ply++
+	}
+	return ply
+}
@@ -0,0 +1,76 @@
+package gochess
+
+import "testing"
+
+func TestFENRoundTrip(t *testing.T) {
+	fens := []string{
+		StartFEN,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3",
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+		"4k3/8/8/8/8/8/8/4K2R b K - 12 60",
+	}
+
+	for _, fen := range fens {
+		t.Run(fen, func(t *testing.T) {
+			g, err := ParseFEN(fen)
+			if err != nil {
+				t.Fatalf("ParseFEN() error = %v", err)
+			}
+			if got := g.FEN(); got != fen {
+				t.Errorf("FEN() = %q, want %q", got, fen)
+			}
+		})
+	}
+}
+
+func TestParseFEN(t *testing.T) {
+	g, err := ParseFEN("rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3")
+	if err != nil {
+		t.Fatalf("ParseFEN() error = %v", err)
+	}
+	if g.GetCurrentPlayerColor() != Black {
+		t.Errorf("Expected Black to move")
+	}
+	// No black pawn can take on e3
+	if g.EnPassant != nil {
+		t.Errorf("EnPassant = %v, want none", g.EnPassant)
+	}
+	start := NewGame("alice", "bob")
+	playMoves(t, start, "e2e4")
+	if g.Hash() != start.Hash() {
+		t.Errorf("Expected the same hash as after 1. e4")
+	}
+	if got, want := g.FEN(), "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1"; got != want {
+		t.Errorf("FEN() = %q, want %q", got, want)
+	}
+
+	// Castling rights are dropped when the rook is gone
+	g, err = ParseFEN("4k3/8/8/8/8/8/8/4K2R w KQ - 0 1")
+	if err != nil {
+		t.Fatalf("ParseFEN() error = %v", err)
+	}
+	if g.Castling != WhiteKingside {
+		t.Errorf("Castling = %b, want only White kingside", g.Castling)
+	}
+
+	invalid := []string{
+		"",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP w KQkq - 0 1",
+		"rnbqkbnr/pppppppp/9/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"rnbqkbnr/ppppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQXBNR w KQkq - 0 1",
+		"rnbq1bnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR x KQkq - 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQxq - 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq e5 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - x 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 0",
+	}
+	for _, fen := range invalid {
+		if _, err := ParseFEN(fen); err == nil {
+			t.Errorf("ParseFEN(%q) should fail", fen)
+		}
+	}
+}
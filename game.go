@@ -1,8 +1,13 @@
-package main
+// Package gochess implements the rules of chess, an engine that plays them and
+// a server to play games against people and computers. The gochess command in
+// cmd/gochess starts the server and cmd/gochess-uci runs the engine for chess
+// GUIs.
+package gochess
 
 import (
 	"errors"
 	"fmt"
+	"time"
 )
 
 type PieceType string
@@ -45,8 +50,31 @@ type Move struct {
 	From       Position
 	To         Position
 	PieceTaken *Piece
+	Promotion  PieceType
+	// EnPassant is set when a pawn takes a pawn that has just passed it
+	EnPassant bool `json:",omitempty"`
+	// Castles is set when the king castles, which in Chess960 is written as
+	// the king taking its own rook
+	Castles bool `json:",omitempty"`
+	// PreviousCastling and PreviousEnPassant are the state before the move,
+	// to take it back
+	PreviousCastling      CastlingRights `json:",omitempty"`
+	PreviousEnPassant     *Position      `json:",omitempty"`
+	PreviousHalfmoveClock int            `json:",omitempty"`
 }
 
+// CastlingRights tell which sides each player can still castle to.
+type CastlingRights uint8
+
+const (
+	WhiteKingside CastlingRights = 1 << iota
+	WhiteQueenside
+	BlackKingside
+	BlackQueenside
+
+	AllCastlingRights = WhiteKingside | WhiteQueenside | BlackKingside | BlackQueenside
+)
+
 type GameState string
 
 const (
@@ -59,75 +87,95 @@ const (
 )
 
 type Game struct {
+	ID         string
 	Board      Board
 	Players    [2]Player
 	State      GameState
 	PlayerTurn PieceColor
 	History    []Move
+	Clock      *Clock
+	Casual     bool
+	// SpectatorDelay holds back what spectators see, for tournament broadcasts
+	SpectatorDelay time.Duration
+	Series         *Series
+	// Rematch is the game started as the rematch of this one
+	Rematch        string            `json:",omitempty"`
+	Correspondence *Correspondence   `json:",omitempty"`
+	Computer       *ComputerOpponent `json:",omitempty"`
+	Castling       CastlingRights
+	// CastlingRooks are the files the rooks of the castling rights start on,
+	// in the order of castlingLetters
+	CastlingRooks [4]int
+	// Chess960 is set for Fischer Random games, where the king castles with
+	// the rooks wherever they start
+	Chess960 bool `json:",omitempty"`
+	// EnPassant is the square a pawn that has just moved two squares can be
+	// taken on, if a pawn of the other player is next to it
+	EnPassant *Position `json:",omitempty"`
+	// HalfmoveClock counts the moves since the last capture or pawn move
+	HalfmoveClock int `json:",omitempty"`
+	// StartColor and StartMove are the player on turn and the move number of
+	// a game set up from a FEN position, empty for the starting position
+	StartColor PieceColor `json:",omitempty"`
+	StartMove  int        `json:",omitempty"`
+
+	listeners []GameListener
+	// hash is the Zobrist hash of the position, kept up to date by every
+	// move once hashed is set
+	hash   uint64
+	hashed bool
 }
 
 func NewGame(player1Name, player2Name string) *Game {
 	// Initialize an empty board
 	var board Board
 
-	// Set up pawns
+	// The board is indexed as [file][rank], with a1 at [0][0] and h8 at [7][7]
+	backRank := [8]PieceType{Rook, Knight, Bishop, Queen, King, Bishop, Knight, Rook}
 	for i := 0; i < 8; i++ {
-		board[1][i] = &Piece{Type: Pawn, Color: Black}
-		board[6][i] = &Piece{Type: Pawn, Color: White}
+		board[i][0] = &Piece{Type: backRank[i], Color: White}
+		board[i][1] = &Piece{Type: Pawn, Color: White}
+		board[i][6] = &Piece{Type: Pawn, Color: Black}
+		board[i][7] = &Piece{Type: backRank[i], Color: Black}
 	}
 
-	// Set up rooks
-	board[0][0] = &Piece{Type: Rook, Color: Black}
-	board[0][7] = &Piece{Type: Rook, Color: Black}
-	board[7][0] = &Piece{Type: Rook, Color: White}
-	board[7][7] = &Piece{Type: Rook, Color: White}
-
-	// Set up knights
-	board[0][1] = &Piece{Type: Knight, Color: Black}
-	board[0][6] = &Piece{Type: Knight, Color: Black}
-	board[7][1] = &Piece{Type: Knight, Color: White}
-	board[7][6] = &Piece{Type: Knight, Color: White}
-
-	// Set up bishops
-	board[0][2] = &Piece{Type: Bishop, Color: Black}
-	board[0][5] = &Piece{Type: Bishop, Color: Black}
-	board[7][2] = &Piece{Type: Bishop, Color: White}
-	board[7][5] = &Piece{Type: Bishop, Color: White}
-
-	// Set up queens
-	board[0][3] = &Piece{Type: Queen, Color: Black}
-	board[7][3] = &Piece{Type: Queen, Color: White}
-
-	// Set up kings
-	board[0][4] = &Piece{Type: King, Color: Black}
-	board[7][4] = &Piece{Type: King, Color: White}
-
 	// Create the players
 	player1 := Player{Name: player1Name, Color: White}
 	player2 := Player{Name: player2Name, Color: Black}
 
 	// Create the game
 	game := Game{
-		Board:   board,
-		Players: [2]Player{player1, player2},
-		State:   Ongoing,
-		History: []Move{},
+		ID:            newGameID(),
+		Board:         board,
+		Players:       [2]Player{player1, player2},
+		State:         Ongoing,
+		History:       []Move{},
+		Castling:      AllCastlingRights,
+		CastlingRooks: standardCastlingRooks,
 	}
 
 	return &game
 }
 
+// PlayerColor returns the color played by the named player.
+func (g *Game) PlayerColor(name string) (PieceColor, bool) {
+	for _, player := range g.Players {
+		if player.Name != "" && player.Name == name {
+			return player.Color, true
+		}
+	}
+	return "", false
+}
+
 func (g *Game) GetCurrentPlayerColor() PieceColor {
 	currentPlayerColor := White
 	if len(g.History) > 0 {
 		if g.History[len(g.History)-1].Color == White {
 			currentPlayerColor = Black
 		}
+	} else if g.StartColor == Black {
+		currentPlayerColor = Black
 	}
-	// Print log
-	fmt.Println("Current player color: ", currentPlayerColor)
-	// Print history
-	fmt.Println("History: ", g.History)
 	return currentPlayerColor
 }
 
@@ -150,19 +198,68 @@ func (g *Game) MovePiece(currentX, currentY, newX, newY int) error {
 		return err
 	}
 
-	// Move the piece
-	// See if piece is being taken
-	pieceTaken := g.Board[newX][newY]
-	g.Board[newX][newY] = g.Board[currentX][currentY]
-	g.Board[currentX][currentY] = nil
-	g.History = append(g.History, Move{
-		Color:      currentPlayerColor,
-		From:       Position{X: currentX, Y: currentY},
-		To:         Position{X: newX, Y: newY},
-		PieceTaken: pieceTaken,
+	piece := g.Board[currentX][currentY]
+	move := g.applyMove(Move{
+		Color: currentPlayerColor,
+		From:  Position{X: currentX, Y: currentY},
+		To:    Position{X: newX, Y: newY},
 	})
+	g.notifyMove(move)
+
+	// A pawn reaching the last rank waits for the player to pick a piece
+	if piece.Type == Pawn && (newY == 0 || newY == 7) {
+		g.State = PromoteWhite
+		if currentPlayerColor == Black {
+			g.State = PromoteBlack
+		}
+		g.notifyPromotionPending(currentPlayerColor)
+		return nil
+	}
+
+	g.finishTurn(otherPlayerColor)
+
+	return nil
+}
+
+// PromotePawn replaces the pawn that has just reached the last rank with a
+// piece of the given type and hands the turn over to the other player.
+func (g *Game) PromotePawn(pieceType PieceType) error {
+	if g.State != PromoteWhite && g.State != PromoteBlack {
+		return errors.New("No promotion pending, got state: " + string(g.State))
+	}
+
+	switch pieceType {
+	case Queen, Rook, Bishop, Knight:
+	default:
+		return errors.New("cannot promote to " + string(pieceType))
+	}
+
+	last := &g.History[len(g.History)-1]
+	g.setSquare(last.To.X, last.To.Y, &Piece{Type: pieceType, Color: last.Color})
+	last.Promotion = pieceType
+	g.State = Ongoing
+
+	otherPlayerColor := Black
+	if last.Color == Black {
+		otherPlayerColor = White
+	}
+	g.finishTurn(otherPlayerColor)
 
-	// TODO Special moves like castling, en passant, pawn promotion, etc.
+	return nil
+}
+
+// finishTurn updates the game state once a move is complete and notifies
+// listeners if the player about to move is in check or the game has ended.
+func (g *Game) finishTurn(otherPlayerColor PieceColor) {
+	if !g.IsCheck(otherPlayerColor) {
+		// A player without a legal move who is not in check is stalemated
+		if len(g.legalMoves(otherPlayerColor)) == 0 {
+			g.State = Draw
+			g.notifyGameOver(g.State)
+		}
+		return
+	}
+	g.notifyCheck(otherPlayerColor)
 
 	// Check if the game is over
 	if g.IsCheckmate(otherPlayerColor) {
@@ -170,34 +267,239 @@ func (g *Game) MovePiece(currentX, currentY, newX, newY int) error {
 		if otherPlayerColor == White {
 			g.State = BlackWon
 		}
+		g.notifyGameOver(g.State)
+	}
+}
+
+// IsOver reports whether the game has reached a final state.
+func (g *Game) IsOver() bool {
+	return g.State == WhiteWon || g.State == BlackWon || g.State == Draw
+}
+
+// Undo takes back the last move, including a pending or completed promotion.
+func (g *Game) Undo() error {
+	if g.IsOver() {
+		return errors.New("Cannot undo a finished game, got state: " + string(g.State))
+	}
+	if len(g.History) == 0 {
+		return errors.New("no move to undo")
 	}
-	// TODO Check for draw
+
+	g.takeBack()
+	g.State = Ongoing
 
 	return nil
 }
 
-func (g *Game) IsCheckmate(color PieceColor) bool {
-	// Check if the king is in check
-	if !g.IsCheck(color) {
+// applyMove plays a move on the board and records it in the history. It takes
+// the pawn passed by an en passant capture, moves the rook along when the king
+// castles and updates the castling rights, the en passant square and the hash.
+func (g *Game) applyMove(m Move) Move {
+	piece := g.Board[m.From.X][m.From.Y]
+	m.PreviousCastling = g.Castling
+	m.PreviousEnPassant = g.EnPassant
+	m.PreviousHalfmoveClock = g.HalfmoveClock
+	m.PieceTaken = nil
+	m.EnPassant = false
+	m.Castles = g.isCastling(m)
+
+	if m.Castles {
+		// The king and the rook can land on each other's squares in Chess960
+		kingTo, rookFrom, rookTo := g.castlingFiles(m)
+		rook := g.Board[rookFrom][m.From.Y]
+		g.setSquare(m.From.X, m.From.Y, nil)
+		g.setSquare(rookFrom, m.From.Y, nil)
+		g.setSquare(rookTo, m.From.Y, rook)
+		g.setSquare(kingTo, m.From.Y, piece)
+	} else {
+		m.PieceTaken = g.Board[m.To.X][m.To.Y]
+		if piece.Type == Pawn && m.From.X != m.To.X && m.PieceTaken == nil {
+			m.EnPassant = true
+			m.PieceTaken = g.Board[m.To.X][m.From.Y]
+			g.setSquare(m.To.X, m.From.Y, nil)
+		}
+
+		placed := piece
+		if m.Promotion != "" {
+			placed = &Piece{Type: m.Promotion, Color: piece.Color}
+		}
+		g.setSquare(m.From.X, m.From.Y, nil)
+		g.setSquare(m.To.X, m.To.Y, placed)
+	}
+
+	lost := g.castlingRightsLost(m.From) | g.castlingRightsLost(m.To)
+	if piece.Type == King {
+		lost |= colorCastlingRights(piece.Color)
+	}
+	g.setCastling(g.Castling &^ lost)
+	var enPassant *Position
+	if piece.Type == Pawn && abs(m.To.Y-m.From.Y) == 2 && g.pawnBeside(m.To, piece.Color) {
+		enPassant = &Position{X: m.To.X, Y: (m.From.Y + m.To.Y) / 2}
+	}
+	g.setEnPassant(enPassant)
+
+	g.HalfmoveClock++
+	if piece.Type == Pawn || m.PieceTaken != nil {
+		g.HalfmoveClock = 0
+	}
+	g.hash ^= zobrist.blackToMove
+	g.History = append(g.History, m)
+	return m
+}
+
+// takeBack undoes the last move on the board and removes it from the history.
+func (g *Game) takeBack() Move {
+	last := g.History[len(g.History)-1]
+	piece := g.Board[last.To.X][last.To.Y]
+	// Castling moves saved before they were marked are two squares of the king
+	if last.Castles || (!g.Chess960 && piece != nil && piece.Type == King && abs(last.To.X-last.From.X) == 2) {
+		kingTo, rookFrom, rookTo := g.castlingFiles(last)
+		y := last.From.Y
+		king, rook := g.Board[kingTo][y], g.Board[rookTo][y]
+		g.setSquare(kingTo, y, nil)
+		g.setSquare(rookTo, y, nil)
+		g.setSquare(rookFrom, y, rook)
+		g.setSquare(last.From.X, y, king)
+	} else {
+		if last.Promotion != "" {
+			piece = &Piece{Type: Pawn, Color: last.Color}
+		}
+		g.setSquare(last.From.X, last.From.Y, piece)
+		if last.EnPassant {
+			g.setSquare(last.To.X, last.To.Y, nil)
+			g.setSquare(last.To.X, last.From.Y, last.PieceTaken)
+		} else {
+			g.setSquare(last.To.X, last.To.Y, last.PieceTaken)
+		}
+	}
+
+	g.setCastling(last.PreviousCastling)
+	g.setEnPassant(last.PreviousEnPassant)
+	g.HalfmoveClock = last.PreviousHalfmoveClock
+	g.hash ^= zobrist.blackToMove
+	g.History = g.History[:len(g.History)-1]
+	return last
+}
+
+// isCastling reports whether a move of the position castles: the king going
+// two squares along its rank, or in Chess960 onto its own rook.
+func (g *Game) isCastling(m Move) bool {
+	piece := g.Board[m.From.X][m.From.Y]
+	if piece == nil || piece.Type != King || m.From.Y != m.To.Y {
 		return false
 	}
+	if g.Chess960 {
+		target := g.Board[m.To.X][m.To.Y]
+		return target != nil && target.Type == Rook && target.Color == piece.Color
+	}
+	return abs(m.To.X-m.From.X) == 2
+}
 
-	// Find the king
-	kingX, kingY := g.FindKing(color)
+// castlingRight returns the right a castling move of the king uses.
+func castlingRight(m Move) CastlingRights {
+	kingside := m.To.X > m.From.X
+	switch {
+	case m.Color == White && kingside:
+		return WhiteKingside
+	case m.Color == White:
+		return WhiteQueenside
+	case kingside:
+		return BlackKingside
+	}
+	return BlackQueenside
+}
 
-	// Check if the king has any valid moves
-	for dx := -1; dx <= 1; dx++ {
-		for dy := -1; dy <= 1; dy++ {
-			if dx != 0 || dy != 0 {
-				newX, newY := kingX+dx, kingY+dy
-				if newX >= 0 && newX < 8 && newY >= 0 && newY < 8 && g.IsValidMove(color, kingX, kingY, newX, newY) == nil {
-					return false
-				}
-			}
+// castlingFiles returns the file the king lands on and the files the rook
+// goes from and to when the king castles. Wherever they start, they end up
+// on g and f kingside and on c and d queenside.
+func (g *Game) castlingFiles(m Move) (kingTo, rookFrom, rookTo int) {
+	right := castlingRight(m)
+	if right&(WhiteKingside|BlackKingside) != 0 {
+		return 6, g.rookFile(right), 5
+	}
+	return 2, g.rookFile(right), 3
+}
+
+// rookFile returns the file the rook of a castling right starts on.
+func (g *Game) rookFile(right CastlingRights) int {
+	if g.Chess960 {
+		return g.CastlingRooks[castlingIndex(right)]
+	}
+	return standardCastlingRooks[castlingIndex(right)]
+}
+
+// castlingRightsLost returns the rights lost when a rook moves from or is
+// taken on the square.
+func (g *Game) castlingRightsLost(square Position) CastlingRights {
+	lost := CastlingRights(0)
+	for _, c := range castlingLetters {
+		if square.X == g.rookFile(c.right) && square.Y == homeRank(c.right) {
+			lost |= c.right
 		}
 	}
+	return lost
+}
 
-	return true
+// colorCastlingRights returns both castling rights of a player.
+func colorCastlingRights(color PieceColor) CastlingRights {
+	if color == Black {
+		return BlackKingside | BlackQueenside
+	}
+	return WhiteKingside | WhiteQueenside
+}
+
+// homeRank returns the rank the king and rook of a castling right start on.
+func homeRank(right CastlingRights) int {
+	if right&(BlackKingside|BlackQueenside) != 0 {
+		return 7
+	}
+	return 0
+}
+
+// pawnBeside reports whether a pawn of the other color stands next to the square.
+func (g *Game) pawnBeside(square Position, color PieceColor) bool {
+	for _, x := range []int{square.X - 1, square.X + 1} {
+		if x < 0 || x > 7 {
+			continue
+		}
+		if piece := g.Board[x][square.Y]; piece != nil && piece.Type == Pawn && piece.Color != color {
+			return true
+		}
+	}
+	return false
+}
+
+// Resign ends the game with a win for the other player.
+func (g *Game) Resign(color PieceColor) error {
+	if g.IsOver() {
+		return errors.New("Game is already over, got state: " + string(g.State))
+	}
+
+	g.State = BlackWon
+	if color == Black {
+		g.State = WhiteWon
+	}
+	g.notifyGameOver(g.State)
+
+	return nil
+}
+
+// AgreeDraw ends the game in a draw agreed by both players.
+func (g *Game) AgreeDraw() error {
+	if g.IsOver() {
+		return errors.New("Game is already over, got state: " + string(g.State))
+	}
+
+	g.State = Draw
+	g.notifyGameOver(g.State)
+
+	return nil
+}
+
+// IsCheckmate reports whether the given color is in check without a legal
+// move left.
+func (g *Game) IsCheckmate(color PieceColor) bool {
+	return g.IsCheck(color) && len(g.legalMoves(color)) == 0
 }
 
 func notationToCoordinates(move string) (source, target [2]int, err error) {
@@ -211,5 +513,34 @@ func notationToCoordinates(move string) (source, target [2]int, err error) {
 	target[0] = int(move[2] - 'a')
 	target[1] = int(move[3] - '1')
 
+	for _, square := range [][2]int{source, target} {
+		if square[0] < 0 || square[0] > 7 || square[1] < 0 || square[1] > 7 {
+			return source, target, fmt.Errorf("invalid move notation")
+		}
+	}
+
 	return source, target, nil
 }
+
+// splitPromotion splits the piece a pawn is promoted to off a move, like the q
+// of e7e8q, leaving the move in the notation read by notationToCoordinates.
+func splitPromotion(move string) (string, PieceType, error) {
+	if len(move) != 5 {
+		return move, "", nil
+	}
+	piece, ok := pieceFromLetter(move[4])
+	if !ok {
+		return move, "", fmt.Errorf("invalid promotion %q", move[4:])
+	}
+	switch piece.Type {
+	case Queen, Rook, Bishop, Knight:
+		return move[:4], piece.Type, nil
+	default:
+		return move, "", errors.New("cannot promote to " + string(piece.Type))
+	}
+}
+
+// coordinatesToNotation writes a move in the notation read by notationToCoordinates.
+func coordinatesToNotation(from, to Position) string {
+	return string([]byte{byte('a' + from.X), byte('1' + from.Y), byte('a' + to.X), byte('1' + to.Y)})
+}
@@ -1,4 +1,4 @@
-package main
+package gochess
 
 import "testing"
 
@@ -18,23 +18,23 @@ func TestNewGame(t *testing.T) {
 		t.Errorf("Expected second player to be Bob (Black), but got %v (%v)", game.Players[1].Name, game.Players[1].Color)
 	}
 
-	// Check that all pieces are set up correctly
+	// Check that all pieces are set up correctly, the board is indexed as [file][rank]
 	for i := 0; i < 8; i++ {
-		if game.Board[1][i] == nil || game.Board[1][i].Type != Pawn || game.Board[1][i].Color != Black {
-			t.Errorf("Expected a black pawn at position (1, %d), but got %v", i, game.Board[1][i])
+		if game.Board[i][6] == nil || game.Board[i][6].Type != Pawn || game.Board[i][6].Color != Black {
+			t.Errorf("Expected a black pawn at position (%d, 6), but got %v", i, game.Board[i][6])
 		}
-		if game.Board[6][i] == nil || game.Board[6][i].Type != Pawn || game.Board[6][i].Color != White {
-			t.Errorf("Expected a white pawn at position (6, %d), but got %v", i, game.Board[6][i])
+		if game.Board[i][1] == nil || game.Board[i][1].Type != Pawn || game.Board[i][1].Color != White {
+			t.Errorf("Expected a white pawn at position (%d, 1), but got %v", i, game.Board[i][1])
 		}
 	}
 
 	pieceTypes := []PieceType{Rook, Knight, Bishop, Queen, King, Bishop, Knight, Rook}
 	for i, pieceType := range pieceTypes {
-		if game.Board[0][i] == nil || game.Board[0][i].Type != pieceType || game.Board[0][i].Color != Black {
-			t.Errorf("Expected a black %v at position (0, %d), but got %v", pieceType, i, game.Board[0][i])
+		if game.Board[i][7] == nil || game.Board[i][7].Type != pieceType || game.Board[i][7].Color != Black {
+			t.Errorf("Expected a black %v at position (%d, 7), but got %v", pieceType, i, game.Board[i][7])
 		}
-		if game.Board[7][i] == nil || game.Board[7][i].Type != pieceType || game.Board[7][i].Color != White {
-			t.Errorf("Expected a white %v at position (7, %d), but got %v", pieceType, i, game.Board[7][i])
+		if game.Board[i][0] == nil || game.Board[i][0].Type != pieceType || game.Board[i][0].Color != White {
+			t.Errorf("Expected a white %v at position (%d, 0), but got %v", pieceType, i, game.Board[i][0])
 		}
 	}
 
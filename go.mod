@@ -2,4 +2,7 @@ module github.com/sbracegirdle/gochess
 
 go 1.19
 
-require github.com/gorilla/mux v1.8.1
+require (
+	github.com/gorilla/mux v1.8.1
+	golang.org/x/crypto v0.24.0
+)
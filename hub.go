@@ -0,0 +1,122 @@
+package gochess
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// hubEvent is a server-sent event pushed to the browsers following a game.
+type hubEvent struct {
+	Name string
+	Data string
+}
+
+type subscriber struct {
+	events    chan hubEvent
+	spectator bool
+}
+
+// GameHub keeps track of everyone following a game live, players and
+// spectators, and pushes updates to them. Spectators can be shown the game
+// with a delay, so updates reach them later.
+type GameHub struct {
+	mu          sync.Mutex
+	subscribers map[string]map[*subscriber]bool
+}
+
+func NewGameHub() *GameHub {
+	return &GameHub{subscribers: map[string]map[*subscriber]bool{}}
+}
+
+// Subscribe starts following a game. The caller must Unsubscribe when done.
+func (h *GameHub) Subscribe(gameID string, spectator bool) *subscriber {
+	s := &subscriber{events: make(chan hubEvent, 16), spectator: spectator}
+
+	h.mu.Lock()
+	if h.subscribers[gameID] == nil {
+		h.subscribers[gameID] = map[*subscriber]bool{}
+	}
+	h.subscribers[gameID][s] = true
+	h.mu.Unlock()
+
+	if spectator {
+		h.publishSpectatorCount(gameID)
+	}
+	return s
+}
+
+func (h *GameHub) Unsubscribe(gameID string, s *subscriber) {
+	h.mu.Lock()
+	delete(h.subscribers[gameID], s)
+	if len(h.subscribers[gameID]) == 0 {
+		delete(h.subscribers, gameID)
+	}
+	h.mu.Unlock()
+
+	if s.spectator {
+		h.publishSpectatorCount(gameID)
+	}
+}
+
+// SpectatorCount returns how many spectators are watching a game.
+func (h *GameHub) SpectatorCount(gameID string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	count := 0
+	for s := range h.subscribers[gameID] {
+		if s.spectator {
+			count++
+		}
+	}
+	return count
+}
+
+// send pushes an event to the matching subscribers of a game, dropping it for
+// subscribers that are too slow to keep up.
+func (h *GameHub) send(gameID string, event hubEvent, to func(*subscriber) bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for s := range h.subscribers[gameID] {
+		if !to(s) {
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+		}
+	}
+}
+
+func (h *GameHub) publishSpectatorCount(gameID string) {
+	count := strconv.Itoa(h.SpectatorCount(gameID))
+	h.send(gameID, hubEvent{Name: "spectators", Data: count}, func(*subscriber) bool { return true })
+}
+
+// Publish pushes an event to the players and/or the spectators of a game.
+// Spectators get it after the spectator delay of the game.
+func (h *GameHub) Publish(g *Game, event hubEvent, toPlayers, toSpectators bool) {
+	if toPlayers {
+		h.send(g.ID, event, func(s *subscriber) bool { return !s.spectator })
+	}
+	if !toSpectators {
+		return
+	}
+
+	spectators := func(s *subscriber) bool { return s.spectator }
+	if g.SpectatorDelay == 0 {
+		h.send(g.ID, event, spectators)
+		return
+	}
+	gameID := g.ID
+	time.AfterFunc(g.SpectatorDelay, func() {
+		h.send(gameID, event, spectators)
+	})
+}
+
+// GameChanged tells everyone following the game that its position changed.
+func (h *GameHub) GameChanged(g *Game) {
+	h.Publish(g, hubEvent{Name: "move", Data: strconv.Itoa(len(g.History))}, true, true)
+}
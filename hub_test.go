@@ -0,0 +1,50 @@
+package gochess
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGameHub(t *testing.T) {
+	h := NewGameHub()
+	g := NewGame("alice", "bob")
+	g.SpectatorDelay = 50 * time.Millisecond
+
+	player := h.Subscribe(g.ID, false)
+	defer h.Unsubscribe(g.ID, player)
+	spectator := h.Subscribe(g.ID, true)
+
+	if got := h.SpectatorCount(g.ID); got != 1 {
+		t.Errorf("Expected 1 spectator, but got %d", got)
+	}
+	if event := <-player.events; event != (hubEvent{Name: "spectators", Data: "1"}) {
+		t.Errorf("Expected the player to be told about the spectator, but got %v", event)
+	}
+	<-spectator.events
+
+	playMoves(t, g, "e2e4")
+	h.GameChanged(g)
+
+	if event := <-player.events; event != (hubEvent{Name: "move", Data: "1"}) {
+		t.Errorf("Expected the player to get the move, but got %v", event)
+	}
+	select {
+	case event := <-spectator.events:
+		t.Errorf("Expected the spectator to get the move after the delay, but got %v", event)
+	default:
+	}
+
+	select {
+	case event := <-spectator.events:
+		if event != (hubEvent{Name: "move", Data: "1"}) {
+			t.Errorf("Expected the spectator to get the move, but got %v", event)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expected the spectator to get the move")
+	}
+
+	h.Unsubscribe(g.ID, spectator)
+	if got := h.SpectatorCount(g.ID); got != 0 {
+		t.Errorf("Expected no spectators, but got %d", got)
+	}
+}
@@ -0,0 +1,298 @@
+package gochess
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrSeekNotFound      = errors.New("seek not found")
+	ErrChallengeNotFound = errors.New("challenge not found")
+)
+
+// TimeControl is the time each player starts with and the time added after
+// every move. The zero value means the game is played without a clock.
+type TimeControl struct {
+	Initial   time.Duration
+	Increment time.Duration
+}
+
+func (tc TimeControl) String() string {
+	if tc == (TimeControl{}) {
+		return "Unlimited"
+	}
+	return fmt.Sprintf("%g+%g", tc.Initial.Minutes(), tc.Increment.Seconds())
+}
+
+// Seek is an open invitation to play anyone who matches it.
+type Seek struct {
+	ID          string
+	Username    string
+	Rating      float64
+	TimeControl TimeControl
+	Rated       bool
+	// Color is the color the player wants to play, empty for either
+	Color PieceColor
+	// MinRating and MaxRating limit the opponent's rating, zero for no limit
+	MinRating float64
+	MaxRating float64
+	CreatedAt time.Time
+}
+
+func (s *Seek) accepts(rating float64) bool {
+	return (s.MinRating == 0 || rating >= s.MinRating) && (s.MaxRating == 0 || rating <= s.MaxRating)
+}
+
+// compatible reports whether two seeks can be paired into a game.
+func (s *Seek) compatible(other *Seek) bool {
+	return s.Username != other.Username &&
+		s.TimeControl == other.TimeControl &&
+		s.Rated == other.Rated &&
+		(s.Color == "" || s.Color != other.Color) &&
+		s.accepts(other.Rating) &&
+		other.accepts(s.Rating)
+}
+
+// Challenge is an invitation to play a specific user.
+type Challenge struct {
+	ID          string
+	From        string
+	To          string
+	TimeControl TimeControl
+	Rated       bool
+	// Color is the color the challenger wants to play, empty for either
+	Color     PieceColor
+	CreatedAt time.Time
+}
+
+// PairFunc creates the game for two players that have been matched.
+type PairFunc func(white, black string, tc TimeControl, rated bool) (*Game, error)
+
+// Lobby is where players look for opponents, either by posting seeks that
+// are paired with compatible seeks of other players, or by challenging a
+// named user.
+type Lobby struct {
+	mu         sync.Mutex
+	seeks      []*Seek
+	challenges []*Challenge
+	// matched holds the games created for players who are still waiting in
+	// the lobby, until they pick them up
+	matched map[string][]string
+	pair    PairFunc
+}
+
+func NewLobby(pair PairFunc) *Lobby {
+	return &Lobby{matched: map[string][]string{}, pair: pair}
+}
+
+func validateSeek(username string, rated bool, color PieceColor) error {
+	if rated && strings.HasPrefix(username, guestPrefix) {
+		return errors.New("guests can only play casual games")
+	}
+	if color != "" && color != White && color != Black {
+		return errors.New("invalid color: " + string(color))
+	}
+	return nil
+}
+
+// PostSeek pairs the seek with the oldest compatible seek in the lobby and
+// returns the new game, or adds it to the lobby if there is none.
+func (l *Lobby) PostSeek(seek *Seek) (*Game, error) {
+	if err := validateSeek(seek.Username, seek.Rated, seek.Color); err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, other := range l.seeks {
+		if !seek.compatible(other) {
+			continue
+		}
+
+		white, black := pickColors(other.Username, other.Color, seek.Username, seek.Color)
+		g, err := l.pair(white, black, seek.TimeControl, seek.Rated)
+		if err != nil {
+			return nil, err
+		}
+		l.removeSeeksOf(seek.Username)
+		l.removeSeeksOf(other.Username)
+		l.matched[other.Username] = append(l.matched[other.Username], g.ID)
+		return g, nil
+	}
+
+	seek.ID = newGameID()
+	seek.CreatedAt = timeNow()
+	l.seeks = append(l.seeks, seek)
+	return nil, nil
+}
+
+// AcceptSeek pairs the user with an open seek of another player.
+func (l *Lobby) AcceptSeek(username string, rating float64, id string) (*Game, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, seek := range l.seeks {
+		if seek.ID != id {
+			continue
+		}
+		if seek.Username == username {
+			return nil, errors.New("you cannot accept your own seek")
+		}
+		if !seek.accepts(rating) {
+			return nil, errors.New("your rating is outside the range of this seek")
+		}
+		if err := validateSeek(username, seek.Rated, ""); err != nil {
+			return nil, err
+		}
+
+		white, black := pickColors(seek.Username, seek.Color, username, "")
+		g, err := l.pair(white, black, seek.TimeControl, seek.Rated)
+		if err != nil {
+			return nil, err
+		}
+		l.removeSeeksOf(seek.Username)
+		l.removeSeeksOf(username)
+		l.matched[seek.Username] = append(l.matched[seek.Username], g.ID)
+		return g, nil
+	}
+	return nil, ErrSeekNotFound
+}
+
+// pickColors gives each player the color they asked for, or picks at random
+// if neither of them minds.
+func pickColors(a string, aColor PieceColor, b string, bColor PieceColor) (white, black string) {
+	if aColor == Black || bColor == White || (aColor == "" && bColor == "" && rand.Intn(2) == 0) {
+		return b, a
+	}
+	return a, b
+}
+
+// removeSeeksOf drops the other seeks of a player once they are in a game.
+// The caller must hold l.mu.
+func (l *Lobby) removeSeeksOf(username string) {
+	seeks := l.seeks[:0]
+	for _, seek := range l.seeks {
+		if seek.Username != username {
+			seeks = append(seeks, seek)
+		}
+	}
+	l.seeks = seeks
+}
+
+// CancelSeek removes a seek posted by the user.
+func (l *Lobby) CancelSeek(username, id string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i, seek := range l.seeks {
+		if seek.ID == id && seek.Username == username {
+			l.seeks = append(l.seeks[:i], l.seeks[i+1:]...)
+			return nil
+		}
+	}
+	return ErrSeekNotFound
+}
+
+// Seeks returns the open seeks, oldest first.
+func (l *Lobby) Seeks() []Seek {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	seeks := make([]Seek, 0, len(l.seeks))
+	for _, seek := range l.seeks {
+		seeks = append(seeks, *seek)
+	}
+	return seeks
+}
+
+// Matched returns the games that were created for the user while they were
+// waiting, and forgets about them.
+func (l *Lobby) Matched(username string) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ids := l.matched[username]
+	delete(l.matched, username)
+	return ids
+}
+
+// Challenge invites a named user to a game.
+func (l *Lobby) Challenge(challenge *Challenge) error {
+	if challenge.From == challenge.To {
+		return errors.New("you cannot challenge yourself")
+	}
+	if err := validateSeek(challenge.From, challenge.Rated, challenge.Color); err != nil {
+		return err
+	}
+	if err := validateSeek(challenge.To, challenge.Rated, ""); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	challenge.ID = newGameID()
+	challenge.CreatedAt = timeNow()
+	l.challenges = append(l.challenges, challenge)
+	return nil
+}
+
+// Challenges returns the challenges sent to or by the user.
+func (l *Lobby) Challenges(username string) []Challenge {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	challenges := []Challenge{}
+	for _, challenge := range l.challenges {
+		if challenge.From == username || challenge.To == username {
+			challenges = append(challenges, *challenge)
+		}
+	}
+	return challenges
+}
+
+// takeChallenge removes a challenge the user is allowed to act on.
+// The caller must hold l.mu.
+func (l *Lobby) takeChallenge(id string, allowed func(*Challenge) bool) (*Challenge, error) {
+	for i, challenge := range l.challenges {
+		if challenge.ID == id && allowed(challenge) {
+			l.challenges = append(l.challenges[:i], l.challenges[i+1:]...)
+			return challenge, nil
+		}
+	}
+	return nil, ErrChallengeNotFound
+}
+
+// AcceptChallenge starts the game of a challenge sent to the user.
+func (l *Lobby) AcceptChallenge(username, id string) (*Game, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	challenge, err := l.takeChallenge(id, func(c *Challenge) bool { return c.To == username })
+	if err != nil {
+		return nil, err
+	}
+
+	white, black := pickColors(challenge.From, challenge.Color, challenge.To, "")
+	g, err := l.pair(white, black, challenge.TimeControl, challenge.Rated)
+	if err != nil {
+		return nil, err
+	}
+	l.matched[challenge.From] = append(l.matched[challenge.From], g.ID)
+	return g, nil
+}
+
+// DeclineChallenge removes a challenge, either declined by the challenged
+// user or withdrawn by the challenger.
+func (l *Lobby) DeclineChallenge(username, id string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, err := l.takeChallenge(id, func(c *Challenge) bool { return c.To == username || c.From == username })
+	return err
+}
@@ -0,0 +1,188 @@
+package gochess
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestLobby returns a lobby that records the games it pairs.
+func newTestLobby(paired *[]*Game) *Lobby {
+	return NewLobby(func(white, black string, tc TimeControl, rated bool) (*Game, error) {
+		g := NewGame(white, black)
+		g.Casual = !rated
+		*paired = append(*paired, g)
+		return g, nil
+	})
+}
+
+func TestLobbyPairsCompatibleSeeks(t *testing.T) {
+	blitz := TimeControl{Initial: 5 * time.Minute, Increment: 3 * time.Second}
+	rapid := TimeControl{Initial: 15 * time.Minute, Increment: 10 * time.Second}
+
+	tests := []struct {
+		name      string
+		first     Seek
+		second    Seek
+		wantWhite string
+	}{
+		{
+			name:   "different time controls",
+			first:  Seek{Username: "alice", Rating: 1500, TimeControl: blitz, Rated: true},
+			second: Seek{Username: "bob", Rating: 1500, TimeControl: rapid, Rated: true},
+		},
+		{
+			name:   "rated and casual",
+			first:  Seek{Username: "alice", Rating: 1500, TimeControl: blitz, Rated: true},
+			second: Seek{Username: "bob", Rating: 1500, TimeControl: blitz},
+		},
+		{
+			name:   "both want white",
+			first:  Seek{Username: "alice", Rating: 1500, TimeControl: blitz, Color: White},
+			second: Seek{Username: "bob", Rating: 1500, TimeControl: blitz, Color: White},
+		},
+		{
+			name:   "outside rating range",
+			first:  Seek{Username: "alice", Rating: 1500, TimeControl: blitz, MinRating: 1600},
+			second: Seek{Username: "bob", Rating: 1550, TimeControl: blitz},
+		},
+		{
+			name:   "same player",
+			first:  Seek{Username: "alice", Rating: 1500, TimeControl: blitz},
+			second: Seek{Username: "alice", Rating: 1500, TimeControl: blitz},
+		},
+		{
+			name:      "first wants black",
+			first:     Seek{Username: "alice", Rating: 1500, TimeControl: blitz, Color: Black},
+			second:    Seek{Username: "bob", Rating: 1700, TimeControl: blitz, MaxRating: 1600},
+			wantWhite: "bob",
+		},
+		{
+			name:      "second wants black",
+			first:     Seek{Username: "alice", Rating: 1500, TimeControl: blitz, Rated: true, MinRating: 1400, MaxRating: 1800},
+			second:    Seek{Username: "bob", Rating: 1700, TimeControl: blitz, Rated: true, Color: Black},
+			wantWhite: "alice",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var paired []*Game
+			l := newTestLobby(&paired)
+
+			if g, err := l.PostSeek(&tt.first); err != nil || g != nil {
+				t.Fatalf("PostSeek() = %v, %v, want no game", g, err)
+			}
+			g, err := l.PostSeek(&tt.second)
+			if err != nil {
+				t.Fatalf("PostSeek() error = %v", err)
+			}
+
+			if tt.wantWhite == "" {
+				if g != nil {
+					t.Errorf("Expected the seeks to not be paired, but got %v", g.Players)
+				}
+				if len(l.Seeks()) != 2 {
+					t.Errorf("Expected both seeks to stay open, but got %v", l.Seeks())
+				}
+				return
+			}
+
+			if g == nil {
+				t.Fatalf("Expected the seeks to be paired")
+			}
+			if g.Players[0].Name != tt.wantWhite {
+				t.Errorf("Expected %v to play White, but got %v", tt.wantWhite, g.Players[0].Name)
+			}
+			if g.Casual == tt.first.Rated {
+				t.Errorf("Expected the game to be rated %v", tt.first.Rated)
+			}
+			if len(l.Seeks()) != 0 {
+				t.Errorf("Expected no open seeks, but got %v", l.Seeks())
+			}
+			if matched := l.Matched("alice"); len(matched) != 1 || matched[0] != g.ID {
+				t.Errorf("Expected Alice to be told about game %v, but got %v", g.ID, matched)
+			}
+		})
+	}
+}
+
+func TestLobbyGuestsCannotSeekRatedGames(t *testing.T) {
+	var paired []*Game
+	l := newTestLobby(&paired)
+
+	if _, err := l.PostSeek(&Seek{Username: guestPrefix + "12345678", Rated: true}); err == nil {
+		t.Errorf("Expected an error posting a rated seek as a guest")
+	}
+	if err := l.Challenge(&Challenge{From: "alice", To: guestPrefix + "12345678", Rated: true}); err == nil {
+		t.Errorf("Expected an error challenging a guest to a rated game")
+	}
+}
+
+func TestLobbyChallenges(t *testing.T) {
+	var paired []*Game
+	l := newTestLobby(&paired)
+
+	if err := l.Challenge(&Challenge{From: "alice", To: "alice"}); err == nil {
+		t.Errorf("Expected an error challenging yourself")
+	}
+
+	challenge := &Challenge{From: "alice", To: "bob", Color: White, Rated: true}
+	if err := l.Challenge(challenge); err != nil {
+		t.Fatalf("Challenge() error = %v", err)
+	}
+	if got := l.Challenges("bob"); len(got) != 1 || got[0].From != "alice" {
+		t.Errorf("Expected Bob to see the challenge, but got %v", got)
+	}
+	if got := l.Challenges("carol"); len(got) != 0 {
+		t.Errorf("Expected Carol to not see the challenge, but got %v", got)
+	}
+
+	if _, err := l.AcceptChallenge("alice", challenge.ID); err != ErrChallengeNotFound {
+		t.Errorf("Expected the challenger to not be able to accept, but got %v", err)
+	}
+	g, err := l.AcceptChallenge("bob", challenge.ID)
+	if err != nil {
+		t.Fatalf("AcceptChallenge() error = %v", err)
+	}
+	if g.Players[0].Name != "alice" || g.Players[1].Name != "bob" {
+		t.Errorf("Expected Alice as White against Bob, but got %v", g.Players)
+	}
+	if len(l.Challenges("bob")) != 0 {
+		t.Errorf("Expected the challenge to be gone")
+	}
+
+	declined := &Challenge{From: "alice", To: "bob"}
+	if err := l.Challenge(declined); err != nil {
+		t.Fatalf("Challenge() error = %v", err)
+	}
+	if err := l.DeclineChallenge("carol", declined.ID); err != ErrChallengeNotFound {
+		t.Errorf("Expected others to not be able to decline, but got %v", err)
+	}
+	if err := l.DeclineChallenge("bob", declined.ID); err != nil {
+		t.Errorf("DeclineChallenge() error = %v", err)
+	}
+}
+
+func TestLobbyAcceptSeek(t *testing.T) {
+	var paired []*Game
+	l := newTestLobby(&paired)
+
+	seek := &Seek{Username: "alice", Rating: 1500, Color: Black, MaxRating: 1600}
+	if _, err := l.PostSeek(seek); err != nil {
+		t.Fatalf("PostSeek() error = %v", err)
+	}
+
+	if _, err := l.AcceptSeek("alice", 1500, seek.ID); err == nil {
+		t.Errorf("Expected an error accepting your own seek")
+	}
+	if _, err := l.AcceptSeek("bob", 1700, seek.ID); err == nil {
+		t.Errorf("Expected an error accepting a seek outside its rating range")
+	}
+	g, err := l.AcceptSeek("carol", 1550, seek.ID)
+	if err != nil {
+		t.Fatalf("AcceptSeek() error = %v", err)
+	}
+	if g.Players[0].Name != "carol" || g.Players[1].Name != "alice" {
+		t.Errorf("Expected Carol as White against Alice, but got %v", g.Players)
+	}
+}
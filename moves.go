@@ -1,4 +1,4 @@
-package main
+package gochess
 
 import (
 	"errors"
@@ -20,16 +20,27 @@ func (g *Game) IsValidMove(color PieceColor, currentX, currentY, newX, newY int)
 		return errors.New("new position is out of bounds")
 	}
 
-	if g.Board[newX][newY] != nil {
+	castles := g.isCastling(Move{Color: color, From: Position{X: currentX, Y: currentY}, To: Position{X: newX, Y: newY}})
+	if g.Board[newX][newY] != nil && !castles {
 		if g.Board[newX][newY].Color == g.Board[currentX][currentY].Color {
 			return errors.New("cannot capture your own piece")
 		}
 	}
 
+	if err := g.isValidPieceMove(currentX, currentY, newX, newY); err != nil {
+		return err
+	}
+
 	if g.WouldBeCheck(color, currentX, currentY, newX, newY) {
 		return errors.New("move would result in check")
 	}
 
+	return nil
+}
+
+// isValidPieceMove checks the move against the movement rules of the piece
+// only, without considering whether it leaves the player's own king in check.
+func (g *Game) isValidPieceMove(currentX, currentY, newX, newY int) error {
 	piece := g.Board[currentX][currentY]
 
 	switch piece.Type {
@@ -69,9 +80,12 @@ func (g *Game) IsValidPawnMove(currentX, currentY, newX, newY int) error {
 	} else if isRoadClear && newX == currentX && currentY == startingRow && newY == currentY+2*positiveYDirection && g.Board[newX][newY-positiveYDirection] == nil {
 		// Can move two steps forward if it's the pawn's first move (in starting row) and the new position is empty and the position in between is empty
 		return nil
-	} else if (newX == currentX-1 || newX == currentX+1) && (newY == currentY+positiveYDirection) && g.Board[newX][newY].Color == oppositeColor {
+	} else if (newX == currentX-1 || newX == currentX+1) && (newY == currentY+positiveYDirection) && g.Board[newX][newY] != nil && g.Board[newX][newY].Color == oppositeColor {
 		// Can capture a piece if it's one step diagonally forward and the new position has a piece of the opposite color
 		return nil
+	} else if (newX == currentX-1 || newX == currentX+1) && (newY == currentY+positiveYDirection) && g.EnPassant != nil && *g.EnPassant == (Position{X: newX, Y: newY}) {
+		// Can capture a pawn that has just moved two squares past it, en passant
+		return nil
 	} else {
 		return errors.New("invalid move for white pawn")
 	}
@@ -156,8 +170,14 @@ func (g *Game) IsValidQueenMove(currentX, currentY, newX, newY int) error {
 }
 
 func (g *Game) IsValidKingMove(currentX, currentY, newX, newY int) error {
+	king := g.Board[currentX][currentY]
+	move := Move{Color: king.Color, From: Position{X: currentX, Y: currentY}, To: Position{X: newX, Y: newY}}
+	if g.isCastling(move) {
+		return g.canCastle(move)
+	}
+
 	// If the target position is occupied by a piece of the same color
-	if g.Board[newX][newY] != nil && g.Board[newX][newY].Color == g.Board[currentX][currentY].Color {
+	if g.Board[newX][newY] != nil && g.Board[newX][newY].Color == king.Color {
 		return errors.New("invalid move for king: cannot capture own piece")
 	}
 
@@ -168,6 +188,63 @@ func (g *Game) IsValidKingMove(currentX, currentY, newX, newY int) error {
 	return nil
 }
 
+// canCastle checks that the king can castle with the rook: neither has
+// moved, the squares they cross and land on are empty but for each other and
+// the king is not in check and does not pass through check.
+func (g *Game) canCastle(m Move) error {
+	right := castlingRight(m)
+	y := homeRank(right)
+	if m.From.Y != y || (!g.Chess960 && m.From.X != 4) {
+		return errors.New("invalid move for king: can only castle from its starting square")
+	}
+	if g.Castling&right == 0 {
+		return errors.New("cannot castle: the king or the rook has moved")
+	}
+
+	kingTo, rookFrom, rookTo := g.castlingFiles(m)
+	rook := g.Board[rookFrom][y]
+	if rook == nil || rook.Type != Rook || rook.Color != m.Color {
+		return errors.New("cannot castle: no rook")
+	}
+	from, to := m.From.X, m.From.X
+	for _, x := range []int{kingTo, rookFrom, rookTo} {
+		if x < from {
+			from = x
+		}
+		if x > to {
+			to = x
+		}
+	}
+	for x := from; x <= to; x++ {
+		if x != m.From.X && x != rookFrom && g.Board[x][y] != nil {
+			return errors.New("cannot castle: pieces in the way")
+		}
+	}
+
+	// The king cannot castle out of check, even when it is already on the
+	// square it castles to, nor through or into check
+	if g.IsCheck(m.Color) {
+		return errors.New("cannot castle out of or through check")
+	}
+	opponent := Black
+	if m.Color == Black {
+		opponent = White
+	}
+	step := 1
+	if kingTo < m.From.X {
+		step = -1
+	}
+	for x := m.From.X; ; x += step {
+		if g.isAttacked(x, y, opponent) {
+			return errors.New("cannot castle out of or through check")
+		}
+		if x == kingTo {
+			break
+		}
+	}
+	return nil
+}
+
 func (g *Game) IsPathClear(startX, startY, endX, endY int, includeEnd bool) bool {
 	dx := endX - startX
 	dy := endY - startY
@@ -213,27 +290,73 @@ func (g *Game) IsCheck(color PieceColor) bool {
 	// Find the king
 	kingX, kingY := g.FindKing(color)
 
-	// Check if any piece of the opposite color can move to the king's position
+	opponent := White
+	if color == White {
+		opponent = Black
+	}
+	return g.isAttacked(kingX, kingY, opponent)
+}
+
+// isAttacked reports whether a piece of the given color could take on the
+// square. A pinned piece still attacks, so only the movement rules matter.
+func (g *Game) isAttacked(x, y int, by PieceColor) bool {
 	for i := 0; i < 8; i++ {
 		for j := 0; j < 8; j++ {
-			if g.Board[i][j] != nil && g.Board[i][j].Color != color {
-				if g.IsValidMove(g.Board[i][j].Color, i, j, kingX, kingY) == nil {
-					return true
-				}
+			piece := g.Board[i][j]
+			if piece == nil || piece.Color != by || (i == x && j == y) {
+				continue
+			}
+			if g.attacks(Position{X: i, Y: j}, Position{X: x, Y: y}) {
+				return true
 			}
 		}
 	}
-
 	return false
 }
 
+// attacks reports whether the piece on from could take on the square to,
+// ignoring pins.
+func (g *Game) attacks(from, to Position) bool {
+	piece := g.Board[from.X][from.Y]
+	switch piece.Type {
+	case Pawn:
+		// Pawns attack the squares diagonally in front, empty or not
+		dy := 1
+		if piece.Color == Black {
+			dy = -1
+		}
+		return to.Y == from.Y+dy && abs(to.X-from.X) == 1
+	case King:
+		return abs(to.X-from.X) <= 1 && abs(to.Y-from.Y) <= 1
+	default:
+		return g.isValidPieceMove(from.X, from.Y, to.X, to.Y) == nil
+	}
+}
+
 func (g *Game) WouldBeCheck(color PieceColor, currentX, currentY, newX, newY int) bool {
 	// Save the current state of the board and the game history
 	savedBoard := g.Board
 	savedHistory := g.History
 
-	// Perform the move
-	g.Board[newX][newY] = g.Board[currentX][currentY]
+	// Perform the move, taking the passed pawn of an en passant capture and
+	// moving the rook along when castling
+	piece := g.Board[currentX][currentY]
+	move := Move{Color: color, From: Position{X: currentX, Y: currentY}, To: Position{X: newX, Y: newY}}
+	if g.isCastling(move) {
+		kingTo, rookFrom, rookTo := g.castlingFiles(move)
+		rook := g.Board[rookFrom][currentY]
+		g.Board[currentX][currentY] = nil
+		g.Board[rookFrom][currentY] = nil
+		g.Board[rookTo][currentY] = rook
+		g.Board[kingTo][currentY] = piece
+		isCheck := g.IsCheck(color)
+		g.Board = savedBoard
+		return isCheck
+	}
+	if piece.Type == Pawn && newX != currentX && g.Board[newX][newY] == nil {
+		g.Board[newX][currentY] = nil
+	}
+	g.Board[newX][newY] = piece
 	g.Board[currentX][currentY] = nil
 
 	// Check if the move results in a check
@@ -247,6 +370,116 @@ func (g *Game) WouldBeCheck(color PieceColor, currentX, currentY, newX, newY int
 	return isCheck
 }
 
+var (
+	knightSteps  = [][2]int{{1, 2}, {2, 1}, {2, -1}, {1, -2}, {-1, -2}, {-2, -1}, {-2, 1}, {-1, 2}}
+	kingSteps    = [][2]int{{1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}, {0, -1}, {1, -1}}
+	rookSteps    = [][2]int{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+	bishopSteps  = [][2]int{{1, 1}, {-1, 1}, {-1, -1}, {1, -1}}
+	promotionTos = []PieceType{Queen, Knight, Rook, Bishop}
+)
+
+// LegalMoves returns every legal move of the player on turn.
+func (g *Game) LegalMoves() []Move {
+	return g.legalMoves(g.GetCurrentPlayerColor())
+}
+
+// legalMoves returns every legal move of the given color. A pawn reaching the
+// last rank gives one move for each piece it can be promoted to.
+func (g *Game) legalMoves(color PieceColor) []Move {
+	moves := []Move{}
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 8; y++ {
+			piece := g.Board[x][y]
+			if piece == nil || piece.Color != color {
+				continue
+			}
+			for _, to := range g.candidateSquares(x, y) {
+				if g.IsValidMove(color, x, y, to.X, to.Y) != nil {
+					continue
+				}
+				move := Move{Color: color, From: Position{X: x, Y: y}, To: to, PieceTaken: g.Board[to.X][to.Y]}
+				if g.isCastling(move) {
+					move.PieceTaken = nil
+				}
+				if piece.Type == Pawn && to.X != x && move.PieceTaken == nil {
+					move.EnPassant = true
+					move.PieceTaken = g.Board[to.X][y]
+				}
+				if piece.Type == Pawn && (to.Y == 0 || to.Y == 7) {
+					for _, promotion := range promotionTos {
+						move.Promotion = promotion
+						moves = append(moves, move)
+					}
+					continue
+				}
+				moves = append(moves, move)
+			}
+		}
+	}
+	return moves
+}
+
+// candidateSquares lists the squares the piece could move to on an empty
+// board, stopping sliding pieces at the first piece in the way.
+func (g *Game) candidateSquares(x, y int) []Position {
+	piece := g.Board[x][y]
+	squares := []Position{}
+	onBoard := func(x, y int) bool { return x >= 0 && x < 8 && y >= 0 && y < 8 }
+
+	jump := func(steps [][2]int) {
+		for _, step := range steps {
+			if onBoard(x+step[0], y+step[1]) {
+				squares = append(squares, Position{X: x + step[0], Y: y + step[1]})
+			}
+		}
+	}
+	slide := func(steps [][2]int) {
+		for _, step := range steps {
+			for nx, ny := x+step[0], y+step[1]; onBoard(nx, ny); nx, ny = nx+step[0], ny+step[1] {
+				squares = append(squares, Position{X: nx, Y: ny})
+				if g.Board[nx][ny] != nil {
+					break
+				}
+			}
+		}
+	}
+
+	switch piece.Type {
+	case Pawn:
+		dy := 1
+		if piece.Color == Black {
+			dy = -1
+		}
+		jump([][2]int{{0, dy}, {0, 2 * dy}, {-1, dy}, {1, dy}})
+	case Knight:
+		jump(knightSteps)
+	case King:
+		jump(kingSteps)
+		if !g.Chess960 {
+			jump([][2]int{{2, 0}, {-2, 0}})
+			break
+		}
+		// The king castles onto its own rooks in Chess960, unless the rook
+		// is next to it and the square is already a step away
+		for _, c := range castlingLetters {
+			if g.Castling&c.right == 0 || homeRank(c.right) != y || colorCastlingRights(piece.Color)&c.right == 0 {
+				continue
+			}
+			if rookX := g.rookFile(c.right); rookX < x-1 || rookX > x+1 {
+				squares = append(squares, Position{X: rookX, Y: y})
+			}
+		}
+	case Rook:
+		slide(rookSteps)
+	case Bishop:
+		slide(bishopSteps)
+	case Queen:
+		slide(rookSteps)
+		slide(bishopSteps)
+	}
+	return squares
+}
+
 func abs(x int) int {
 	if x < 0 {
 		return -x
@@ -1,4 +1,4 @@
-package main
+package gochess
 
 import (
 	"errors"
@@ -751,3 +751,119 @@ func TestIsCheck(t *testing.T) {
 		})
 	}
 }
+
+func TestCastling(t *testing.T) {
+	whiteKing := map[[2]int]*Piece{
+		{4, 0}: {Color: White, Type: King},
+		{0, 0}: {Color: White, Type: Rook},
+		{7, 0}: {Color: White, Type: Rook},
+		{4, 7}: {Color: Black, Type: King},
+	}
+	with := func(pieces map[[2]int]*Piece, extra map[[2]int]*Piece) map[[2]int]*Piece {
+		all := map[[2]int]*Piece{}
+		for square, piece := range pieces {
+			all[square] = piece
+		}
+		for square, piece := range extra {
+			all[square] = piece
+		}
+		return all
+	}
+
+	tests := []struct {
+		name     string
+		pieces   map[[2]int]*Piece
+		castling CastlingRights
+		toX      int
+		wantErr  bool
+	}{
+		{"Kingside", whiteKing, AllCastlingRights, 6, false},
+		{"Queenside", whiteKing, AllCastlingRights, 2, false},
+		{"Kingside right lost", whiteKing, WhiteQueenside, 6, true},
+		{"Queenside right lost", whiteKing, WhiteKingside, 2, true},
+		{"Piece in the way", with(whiteKing, map[[2]int]*Piece{{1, 0}: {Color: White, Type: Knight}}), AllCastlingRights, 2, true},
+		{"Out of check", with(whiteKing, map[[2]int]*Piece{{4, 4}: {Color: Black, Type: Rook}}), AllCastlingRights, 6, true},
+		{"Through check", with(whiteKing, map[[2]int]*Piece{{5, 4}: {Color: Black, Type: Rook}}), AllCastlingRights, 6, true},
+		{"Into check", with(whiteKing, map[[2]int]*Piece{{6, 4}: {Color: Black, Type: Rook}}), AllCastlingRights, 6, true},
+		{"Through a pawn's attack", with(whiteKing, map[[2]int]*Piece{{4, 1}: {Color: Black, Type: Pawn}}), AllCastlingRights, 6, true},
+		{"Rook attacked", with(whiteKing, map[[2]int]*Piece{{1, 4}: {Color: Black, Type: Rook}}), AllCastlingRights, 2, false},
+		{"Rook taken", with(whiteKing, map[[2]int]*Piece{{7, 0}: nil}), AllCastlingRights, 6, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := positionGame(tt.pieces, White)
+			g.Castling = tt.castling
+			if err := g.IsValidMove(White, 4, 0, tt.toX, 0); (err != nil) != tt.wantErr {
+				t.Errorf("IsValidMove() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCastlingMovesTheRook(t *testing.T) {
+	g := NewGame("alice", "bob")
+	playMoves(t, g, "e2e4", "e7e5", "g1f3", "b8c6", "f1c4", "f8c5", "e1g1")
+
+	if king := g.Board[6][0]; king == nil || king.Type != King {
+		t.Errorf("Expected the king on g1, got %v", king)
+	}
+	if rook := g.Board[5][0]; rook == nil || rook.Type != Rook {
+		t.Errorf("Expected the rook on f1, got %v", rook)
+	}
+	if g.Board[7][0] != nil {
+		t.Errorf("Expected h1 to be empty, got %v", g.Board[7][0])
+	}
+	if g.Castling != BlackKingside|BlackQueenside {
+		t.Errorf("Castling = %b, want only Black's rights", g.Castling)
+	}
+
+	if err := g.Undo(); err != nil {
+		t.Fatalf("Undo() error = %v", err)
+	}
+	if rook := g.Board[7][0]; rook == nil || rook.Type != Rook || g.Board[5][0] != nil {
+		t.Errorf("Expected the rook back on h1, got %v", rook)
+	}
+	if g.Castling != AllCastlingRights {
+		t.Errorf("Castling = %b, want all rights back", g.Castling)
+	}
+}
+
+func TestEnPassant(t *testing.T) {
+	g := NewGame("alice", "bob")
+	playMoves(t, g, "e2e4", "a7a6", "e4e5", "d7d5", "e5d6")
+
+	if g.Board[3][4] != nil {
+		t.Errorf("Expected the pawn on d5 to be taken, got %v", g.Board[3][4])
+	}
+	last := g.History[len(g.History)-1]
+	if !last.EnPassant || last.PieceTaken == nil || last.PieceTaken.Color != Black {
+		t.Errorf("Expected an en passant capture of a black pawn, got %+v", last)
+	}
+
+	if err := g.Undo(); err != nil {
+		t.Fatalf("Undo() error = %v", err)
+	}
+	if pawn := g.Board[3][4]; pawn == nil || pawn.Color != Black || g.Board[3][5] != nil {
+		t.Errorf("Expected the black pawn back on d5, got %v", pawn)
+	}
+
+	// The capture is only possible right after the pawn moved
+	playMoves(t, g, "h2h3", "h7h6")
+	if err := g.MovePiece(4, 4, 3, 5); err == nil {
+		t.Error("Expected en passant to be invalid a move later")
+	}
+
+	// Taking en passant cannot expose the king along the rank
+	pinned := positionGame(map[[2]int]*Piece{
+		{0, 4}: {Color: White, Type: King},
+		{1, 4}: {Color: White, Type: Pawn},
+		{2, 4}: {Color: Black, Type: Pawn},
+		{7, 4}: {Color: Black, Type: Rook},
+		{4, 7}: {Color: Black, Type: King},
+	}, White)
+	pinned.EnPassant = &Position{X: 2, Y: 5}
+	if err := pinned.IsValidMove(White, 1, 4, 2, 5); err == nil {
+		t.Error("Expected en passant to be invalid when it leaves the king in check")
+	}
+}
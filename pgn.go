@@ -0,0 +1,186 @@
+package gochess
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PGNGame is a game read from Portable Game Notation: its tag pairs and the
+// game its moves were played on.
+type PGNGame struct {
+	Tags map[string]string
+	Game *Game
+}
+
+// Result is the result of the game from its tags, like 1-0 or 1/2-1/2, or *
+// when it is unknown.
+func (p PGNGame) Result() string {
+	if result, ok := p.Tags["Result"]; ok {
+		return result
+	}
+	return "*"
+}
+
+var pgnResults = map[string]bool{"1-0": true, "0-1": true, "1/2-1/2": true, "*": true}
+
+// ReadPGN reads every game of a PGN file, playing the moves on a Game set up
+// from the FEN tag if there is one. Comments, variations and numeric
+// annotation glyphs are skipped.
+func ReadPGN(r io.Reader) ([]PGNGame, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	text := string(data)
+
+	games := []PGNGame{}
+	var current *PGNGame
+	finish := func() {
+		if current == nil {
+			return
+		}
+		if current.Game == nil {
+			current.Game = NewGame(current.Tags["White"], current.Tags["Black"])
+		}
+		current.Game.finishTurn(current.Game.GetCurrentPlayerColor())
+		games = append(games, *current)
+		current = nil
+	}
+	start := func() *PGNGame {
+		if current == nil {
+			current = &PGNGame{Tags: map[string]string{}}
+		}
+		return current
+	}
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+		case c == '[':
+			end := strings.IndexByte(text[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("game %d: unterminated tag", len(games)+1)
+			}
+			// Tags after moves start the next game, when the result is missing
+			if current != nil && current.Game != nil {
+				finish()
+			}
+			name, value, err := parsePGNTag(text[i+1 : i+end])
+			if err != nil {
+				return nil, fmt.Errorf("game %d: %v", len(games)+1, err)
+			}
+			start().Tags[name] = value
+			i += end
+		case c == '{':
+			end := strings.IndexByte(text[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("game %d: unterminated comment", len(games)+1)
+			}
+			i += end
+		case c == ';' || (c == '%' && (i == 0 || text[i-1] == '\n')):
+			end := strings.IndexByte(text[i:], '\n')
+			if end < 0 {
+				end = len(text) - i
+			}
+			i += end
+		case c == '(':
+			end, err := skipPGNVariation(text[i:])
+			if err != nil {
+				return nil, fmt.Errorf("game %d: %v", len(games)+1, err)
+			}
+			i += end
+		default:
+			end := strings.IndexAny(text[i:], " \t\r\n{}()[];")
+			if end < 0 {
+				end = len(text) - i
+			}
+			token := text[i : i+end]
+			i += end - 1
+
+			if pgnResults[token] {
+				game := start()
+				if _, ok := game.Tags["Result"]; !ok {
+					game.Tags["Result"] = token
+				}
+				finish()
+				continue
+			}
+			if err := start().playPGNToken(token); err != nil {
+				return nil, fmt.Errorf("game %d: %v", len(games)+1, err)
+			}
+		}
+	}
+	finish()
+	return games, nil
+}
+
+// parsePGNTag reads a tag pair like White "Carlsen, Magnus".
+func parsePGNTag(tag string) (string, string, error) {
+	name, rest, _ := strings.Cut(strings.TrimSpace(tag), " ")
+	rest = strings.TrimSpace(rest)
+	if name == "" || len(rest) < 2 || rest[0] != '"' || rest[len(rest)-1] != '"' {
+		return "", "", fmt.Errorf("invalid tag [%s]", tag)
+	}
+	value := strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(rest[1 : len(rest)-1])
+	return name, value, nil
+}
+
+// skipPGNVariation returns the index of the parenthesis closing the
+// variation at the start of text, skipping nested variations and comments.
+func skipPGNVariation(text string) (int, error) {
+	depth := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		case '{':
+			end := strings.IndexByte(text[i:], '}')
+			if end < 0 {
+				return 0, fmt.Errorf("unterminated comment")
+			}
+			i += end
+		}
+	}
+	return 0, fmt.Errorf("unterminated variation")
+}
+
+// playPGNToken plays a move of the movetext, skipping move numbers and
+// annotation glyphs.
+func (p *PGNGame) playPGNToken(token string) error {
+	if token[0] == '$' {
+		return nil
+	}
+	token = strings.TrimLeft(token, "0123456789")
+	token = strings.TrimLeft(token, ".")
+	if token == "" {
+		return nil
+	}
+
+	if p.Game == nil {
+		p.Game = NewGame(p.Tags["White"], p.Tags["Black"])
+		if fen, ok := p.Tags["FEN"]; ok {
+			g, err := ParseFEN(fen)
+			if err != nil {
+				return err
+			}
+			g.Players[0].Name, g.Players[1].Name = p.Tags["White"], p.Tags["Black"]
+			p.Game = g
+		}
+		if strings.EqualFold(p.Tags["Variant"], "Chess960") {
+			p.Game.Chess960 = true
+		}
+	}
+	move, err := p.Game.ParseSAN(token)
+	if err != nil {
+		return err
+	}
+	p.Game.applyMove(move)
+	return nil
+}
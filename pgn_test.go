@@ -0,0 +1,119 @@
+package gochess
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReadPGN(t *testing.T) {
+	f, err := os.Open("testdata/games.pgn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	games, err := ReadPGN(f)
+	if err != nil {
+		t.Fatalf("ReadPGN() error = %v", err)
+	}
+	if len(games) != 4 {
+		t.Fatalf("Expected 4 games, got %d", len(games))
+	}
+
+	tests := []struct {
+		white, black, result string
+		plies                int
+		state                GameState
+	}{
+		{"alice", "bob", "1-0", 10, Ongoing},
+		{"carol", "dave", "1-0", 7, WhiteWon},
+		{"bob", "carol", "1/2-1/2", 6, Ongoing},
+		{"dave", "alice", "0-1", 10, Ongoing},
+	}
+	for i, tt := range tests {
+		game := games[i]
+		if game.Game.Players[0].Name != tt.white || game.Game.Players[1].Name != tt.black || game.Result() != tt.result {
+			t.Errorf("Game %d is %s - %s %s, want %s - %s %s", i+1,
+				game.Game.Players[0].Name, game.Game.Players[1].Name, game.Result(), tt.white, tt.black, tt.result)
+		}
+		if len(game.Game.History) != tt.plies || game.Game.State != tt.state {
+			t.Errorf("Game %d has %d plies and state %s, want %d and %s", i+1, len(game.Game.History), game.Game.State, tt.plies, tt.state)
+		}
+	}
+	if games[0].Tags["Round"] != "1" || games[0].Tags["Event"] != "Club night" {
+		t.Errorf("Expected the tags to be read, got %v", games[0].Tags)
+	}
+}
+
+func TestReadPGNMovetext(t *testing.T) {
+	tests := []struct {
+		name    string
+		pgn     string
+		fen     string
+		result  string
+		wantErr bool
+	}{
+		{
+			name:   "No tags or result",
+			pgn:    "1. e4 e5 2. Nf3",
+			fen:    "rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2",
+			result: "*",
+		},
+		{
+			name:   "Comments and glyphs",
+			pgn:    "1.e4 ; the king's pawn\n1...e5 {symmetry} $10 2.Nf3! 1-0",
+			fen:    "rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2",
+			result: "1-0",
+		},
+		{
+			name:   "Set up from FEN",
+			pgn:    "[SetUp \"1\"]\n[FEN \"8/P7/8/8/8/8/8/k6K w - - 0 40\"]\n\n40. a8=N 1/2-1/2",
+			fen:    "N7/8/8/8/8/8/8/k6K b - - 0 40",
+			result: "1/2-1/2",
+		},
+		{
+			name:    "Illegal move",
+			pgn:     "1. e4 e5 2. Ke3",
+			wantErr: true,
+		},
+		{
+			name:    "Unterminated variation",
+			pgn:     "1. e4 (1. d4 d5",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid tag",
+			pgn:     "[White alice]\n1. e4",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			games, err := ReadPGN(strings.NewReader(tt.pgn))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadPGN() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(games) != 1 {
+				t.Fatalf("Expected 1 game, got %d", len(games))
+			}
+			if got := games[0].Game.FEN(); got != tt.fen {
+				t.Errorf("FEN() = %q, want %q", got, tt.fen)
+			}
+			if got := games[0].Result(); got != tt.result {
+				t.Errorf("Result() = %q, want %q", got, tt.result)
+			}
+		})
+	}
+}
+
+func TestParsePGNTag(t *testing.T) {
+	name, value, err := parsePGNTag(`Annotator "The \"Doctor\""`)
+	if err != nil || name != "Annotator" || value != `The "Doctor"` {
+		t.Errorf("parsePGNTag() = %q, %q, %v", name, value, err)
+	}
+}
@@ -0,0 +1,195 @@
+package gochess
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestPolyglotKey(t *testing.T) {
+	// The keys published with the Polyglot book format
+	tests := []struct {
+		moves []string
+		want  uint64
+	}{
+		{[]string{}, 0x463b96181691fc9c},
+		{[]string{"e2e4"}, 0x823c9b50fd114196},
+		{[]string{"e2e4", "d7d5"}, 0x0756b94461c50fb0},
+		{[]string{"e2e4", "d7d5", "e4e5"}, 0x662fafb965db29d4},
+		{[]string{"e2e4", "d7d5", "e4e5", "f7f5"}, 0x22a48b5a8e47ff78},
+		{[]string{"e2e4", "d7d5", "e4e5", "f7f5", "e1e2"}, 0x652a607ca3f242c1},
+		{[]string{"e2e4", "d7d5", "e4e5", "f7f5", "e1e2", "e8f7"}, 0x00fdd303c946bdd9},
+		{[]string{"a2a4", "b7b5", "h2h4", "b5b4", "c2c4"}, 0x3c8123ea7b067637},
+		{[]string{"a2a4", "b7b5", "h2h4", "b5b4", "c2c4", "b4c3", "a1a3"}, 0x5c3f9b829b279560},
+	}
+
+	for _, tt := range tests {
+		g := NewGame("alice", "bob")
+		playMoves(t, g, tt.moves...)
+		if got := PolyglotKey(g); got != tt.want {
+			t.Errorf("PolyglotKey() after %v = %016x, want %016x", tt.moves, got, tt.want)
+		}
+	}
+}
+
+func TestPolyglotMove(t *testing.T) {
+	tests := []struct {
+		fen  string
+		move string
+		want uint16
+	}{
+		{StartFEN, "e2e4", 4<<6 | 1<<9 | 4 | 3<<3},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1g1", 4<<6 | 7},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "e8c8", 4<<6 | 7<<9 | 7<<3},
+		{"8/P7/8/8/8/8/8/k6K w - - 0 1", "a7a8q", 6<<9 | 7<<3 | 4<<12},
+		{"8/P7/8/8/8/8/8/k6K w - - 0 1", "a7a8n", 6<<9 | 7<<3 | 1<<12},
+	}
+
+	for _, tt := range tests {
+		g, err := ParseFEN(tt.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		move, err := parseUCIMove(g, tt.move)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := polyglotMove(g, move); got != tt.want {
+			t.Errorf("polyglotMove(%s) = %#x, want %#x", tt.move, got, tt.want)
+		}
+	}
+}
+
+// testBook builds a book from the games in testdata.
+func testBook(t *testing.T) *Book {
+	t.Helper()
+	f, err := os.Open("testdata/games.pgn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	games, err := ReadPGN(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	builder := NewBookBuilder(6)
+	for _, game := range games {
+		builder.Add(game.Game, game.Result())
+	}
+	return builder.Book()
+}
+
+func TestBook(t *testing.T) {
+	book := testBook(t)
+
+	// The book survives being written and read back
+	var buf bytes.Buffer
+	if err := book.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 16*len(book.entries) {
+		t.Errorf("Expected 16 bytes per entry, got %d for %d entries", buf.Len(), len(book.entries))
+	}
+	book, err := ReadBook(&buf)
+	if err != nil {
+		t.Fatalf("ReadBook() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		moves []string
+		want  []BookMove
+	}{
+		// White won both games opening e4 and drew the one opening d4
+		{"Start", nil, []BookMove{{Weight: 4}, {Weight: 1}}},
+		// Black lost with e5 and won with c5
+		{"After e4", []string{"e2e4"}, []BookMove{{Weight: 2}}},
+		{"Past the book depth", []string{"e2e4", "e7e5", "g1f3", "b8c6", "f1b5", "a7a6"}, []BookMove{}},
+	}
+	want := map[string][]string{"Start": {"e2e4", "d2d4"}, "After e4": {"c7c5"}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGame("alice", "bob")
+			playMoves(t, g, tt.moves...)
+			moves := book.Moves(g)
+			if len(moves) != len(tt.want) {
+				t.Fatalf("Moves() = %v, want %d moves", moves, len(tt.want))
+			}
+			for i, move := range moves {
+				if uciMove(move.Move) != want[tt.name][i] || move.Weight != tt.want[i].Weight {
+					t.Errorf("Moves()[%d] = %s weighing %d, want %s weighing %d", i, uciMove(move.Move), move.Weight, want[tt.name][i], tt.want[i].Weight)
+				}
+			}
+		})
+	}
+}
+
+func TestReadBookErrors(t *testing.T) {
+	if _, err := ReadBook(bytes.NewReader(make([]byte, 20))); err == nil {
+		t.Error("Expected a book of partial entries to be rejected")
+	}
+
+	unsorted := &Book{entries: []BookEntry{{Key: 2, Move: 1, Weight: 1}, {Key: 1, Move: 1, Weight: 1}}}
+	var buf bytes.Buffer
+	if err := unsorted.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadBook(&buf); err == nil {
+		t.Error("Expected an unsorted book to be rejected")
+	}
+}
+
+func TestBookCastling(t *testing.T) {
+	g, err := ParseFEN("r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Castling is stored as the king taking its rook
+	book := &Book{entries: []BookEntry{{Key: PolyglotKey(g), Move: 4<<6 | 0, Weight: 1}}}
+	moves := book.Moves(g)
+	if len(moves) != 1 || uciMove(moves[0].Move) != "e1c1" {
+		t.Errorf("Moves() = %v, want queenside castling", moves)
+	}
+}
+
+func TestPickBookMove(t *testing.T) {
+	e4, d4, c4 := Move{To: Position{X: 4, Y: 3}}, Move{To: Position{X: 3, Y: 3}}, Move{To: Position{X: 2, Y: 3}}
+	moves := []BookMove{{e4, 5}, {d4, 3}, {c4, 2}}
+	tests := []struct {
+		n    int
+		want Move
+	}{
+		{0, e4}, {4, e4}, {5, d4}, {7, d4}, {8, c4}, {9, c4},
+	}
+
+	for _, tt := range tests {
+		if got := pickBookMove(moves, tt.n); got != tt.want {
+			t.Errorf("pickBookMove(%d) = %v, want %v", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestEngineBook(t *testing.T) {
+	e := &Engine{Evaluate: Evaluate, Book: testBook(t), BookDepth: 2}
+
+	g := NewGame("alice", "bob")
+	result, err := e.Search(g, SearchLimits{Depth: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if move := uciMove(result.Move); (move != "e2e4" && move != "d2d4") || result.Depth != 0 {
+		t.Errorf("Expected a book move without searching, got %s at depth %d", move, result.Depth)
+	}
+
+	// Past the book depth the engine searches again
+	playMoves(t, g, "e2e4", "c7c5")
+	result, err = e.Search(g, SearchLimits{Depth: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Depth != 1 {
+		t.Errorf("Expected a search past the book depth, got depth %d", result.Depth)
+	}
+}
@@ -0,0 +1,311 @@
+package gochess
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Puzzle is a position with a single clearly winning continuation, for
+// training tactics.
+type Puzzle struct {
+	// ID identifies the position, the same in every game it comes up in
+	ID string
+	// FEN is the position with the solver on turn
+	FEN string
+	// Moves is the solution in long algebraic notation: the solver's moves
+	// and the replies in between, ending with a move of the solver
+	Moves  []string
+	Themes []string
+	// Source describes where the puzzle was found
+	Source string
+	// Rating is how hard the puzzle is, zero when it has not been rated
+	Rating float64
+}
+
+const (
+	// puzzleWinScore is the score from which a position is clearly won
+	puzzleWinScore = 300
+	// puzzleMargin is how much better than the others the move of a solution
+	// must be
+	puzzleMargin = 200
+	// puzzleMaxMoves is the most moves of the solver in a solution
+	puzzleMaxMoves = 4
+)
+
+// FindPuzzles searches every position of the game within the limits for
+// puzzles: positions where a single move wins clearly, by mate or material,
+// while every other move does not. The solution follows the engine's
+// defence for as long as the solver has a single good move.
+func (e *Engine) FindPuzzles(g *Game, limits SearchLimits) ([]Puzzle, error) {
+	position := g.startPosition()
+	puzzles := []Puzzle{}
+	for i := 0; ; i++ {
+		if position.State == Ongoing {
+			puzzle, ok, err := e.puzzleAt(position, limits)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				puzzles = append(puzzles, puzzle)
+			}
+		}
+		if i == len(g.History) {
+			return puzzles, nil
+		}
+		position.applyMove(g.History[i])
+		position.finishTurn(position.GetCurrentPlayerColor())
+	}
+}
+
+// puzzleAt looks for a puzzle in the position.
+func (e *Engine) puzzleAt(position *Game, limits SearchLimits) (Puzzle, bool, error) {
+	// Most positions are not won, which a single line tells
+	lines, err := e.Analyze(position, limits, 1, nil)
+	if err != nil || (lines[0].MateIn() <= 0 && lines[0].Score < puzzleWinScore) {
+		return Puzzle{}, false, err
+	}
+	if lines, err = e.Analyze(position, limits, 2, nil); err != nil || len(lines) < 2 {
+		return Puzzle{}, false, err
+	}
+	best, second := lines[0], lines[1]
+	if best.MateIn() > puzzleMaxMoves || !onlyMove(lines) || (best.MateIn() <= 0 && second.Score >= puzzleWinScore) {
+		return Puzzle{}, false, nil
+	}
+	// Taking back what was just taken is too easy to be a puzzle
+	if history := position.History; len(history) > 0 {
+		last := history[len(history)-1]
+		if last.PieceTaken != nil && best.Move.To == last.To {
+			return Puzzle{}, false, nil
+		}
+	}
+
+	solution, err := e.solvePuzzle(position, best.Move, limits)
+	if err != nil {
+		return Puzzle{}, false, err
+	}
+	puzzle := Puzzle{ID: fmt.Sprintf("%016x", position.Hash()), FEN: position.FEN(), Themes: puzzleThemes(position, solution)}
+	for _, move := range solution {
+		puzzle.Moves = append(puzzle.Moves, uciMove(move))
+	}
+	return puzzle, true, nil
+}
+
+// onlyMove reports whether the best of the lines is the only good move: the
+// only mate, any mate in one, or better than the next move by the margin.
+func onlyMove(lines []SearchResult) bool {
+	if len(lines) < 2 {
+		return true
+	}
+	best, second := lines[0], lines[1]
+	switch {
+	case best.MateIn() == 1:
+		return true
+	case best.MateIn() > 0:
+		return second.MateIn() <= 0
+	}
+	return best.Score-second.Score >= puzzleMargin
+}
+
+// solvePuzzle plays the first move of the solution and the engine's replies
+// to it, adding moves of the solver while each is the only good one.
+func (e *Engine) solvePuzzle(position *Game, first Move, limits SearchLimits) ([]Move, error) {
+	solver := position.searchCopy()
+	solution := []Move{solver.applyMove(first)}
+	for len(solution) < 2*puzzleMaxMoves-1 {
+		solver.finishTurn(solver.GetCurrentPlayerColor())
+		if solver.State != Ongoing {
+			break
+		}
+		replies, err := e.Analyze(solver, limits, 1, nil)
+		if err != nil {
+			return nil, err
+		}
+		reply := solver.applyMove(replies[0].Move)
+		solver.finishTurn(solver.GetCurrentPlayerColor())
+		if solver.State != Ongoing {
+			break
+		}
+		lines, err := e.Analyze(solver, limits, 2, nil)
+		if err != nil {
+			return nil, err
+		}
+		if !onlyMove(lines) {
+			break
+		}
+		solution = append(solution, reply, solver.applyMove(lines[0].Move))
+	}
+	return solution, nil
+}
+
+// puzzleThemes tags the solution of a puzzle in the position: mate and
+// mateInN when it ends in mate, fork when a move of the solver attacks two
+// pieces, material when the solver wins material, and promotion.
+func puzzleThemes(position *Game, solution []Move) []string {
+	p := position.searchCopy()
+	solver := p.GetCurrentPlayerColor()
+	before := materialBalance(p, solver)
+	fork, promotion := false, false
+	for i, move := range solution {
+		p.applyMove(move)
+		if i%2 == 0 {
+			fork = fork || p.forks(move.To)
+			promotion = promotion || move.Promotion != ""
+		}
+	}
+	p.finishTurn(p.GetCurrentPlayerColor())
+
+	themes := []string{}
+	if p.State == WhiteWon || p.State == BlackWon {
+		themes = append(themes, "mate", fmt.Sprintf("mateIn%d", (len(solution)+1)/2))
+	}
+	if fork {
+		themes = append(themes, "fork")
+	}
+	if materialBalance(p, solver)-before >= puzzleMargin {
+		themes = append(themes, "material")
+	}
+	if promotion {
+		themes = append(themes, "promotion")
+	}
+	return themes
+}
+
+// forks reports whether the piece on the square attacks two or more pieces
+// of the other player that are the king or worth more than it.
+func (g *Game) forks(square Position) bool {
+	piece := g.Board[square.X][square.Y]
+	targets := 0
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 8; y++ {
+			target := g.Board[x][y]
+			if target == nil || target.Color == piece.Color {
+				continue
+			}
+			if (target.Type == King || pieceValues[target.Type] > pieceValues[piece.Type]) && g.attacks(square, Position{X: x, Y: y}) {
+				targets++
+			}
+		}
+	}
+	return targets >= 2
+}
+
+// materialBalance is the value of the player's pieces less the other
+// player's, in centipawns.
+func materialBalance(g *Game, color PieceColor) int {
+	balance := 0
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 8; y++ {
+			if piece := g.Board[x][y]; piece != nil {
+				if piece.Color == color {
+					balance += pieceValues[piece.Type]
+				} else {
+					balance -= pieceValues[piece.Type]
+				}
+			}
+		}
+	}
+	return balance
+}
+
+// puzzleColumns are the columns of a puzzle file
+var puzzleColumns = []string{"PuzzleId", "FEN", "Moves", "Themes", "Source"}
+
+// WritePuzzles writes puzzles as CSV with a header, one puzzle per row and
+// the moves and themes separated by spaces, like the Lichess puzzle database.
+func WritePuzzles(w io.Writer, puzzles []Puzzle) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(puzzleColumns); err != nil {
+		return err
+	}
+	for _, puzzle := range puzzles {
+		row := []string{puzzle.ID, puzzle.FEN, strings.Join(puzzle.Moves, " "), strings.Join(puzzle.Themes, " "), puzzle.Source}
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// ReadPuzzles reads puzzles written by WritePuzzles. The columns are found by
+// their name in the header, so they can come in any order, and a Rating
+// column is read when there is one. Every solution is checked to be legal.
+func ReadPuzzles(r io.Reader) ([]Puzzle, error) {
+	in := csv.NewReader(r)
+	in.FieldsPerRecord = -1
+	header, err := in.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading the header: %v", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, name := range []string{"PuzzleId", "FEN", "Moves"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("the header has no %s column", name)
+		}
+	}
+
+	puzzles := []Puzzle{}
+	for line := 2; ; line++ {
+		row, err := in.Read()
+		if err == io.EOF {
+			return puzzles, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		puzzle := Puzzle{
+			ID:     field("PuzzleId"),
+			FEN:    field("FEN"),
+			Moves:  strings.Fields(field("Moves")),
+			Themes: strings.Fields(field("Themes")),
+			Source: field("Source"),
+		}
+		if rating := field("Rating"); rating != "" {
+			if puzzle.Rating, err = strconv.ParseFloat(rating, 64); err != nil {
+				return nil, fmt.Errorf("line %d: invalid rating %q", line, rating)
+			}
+		}
+		if _, _, err := puzzle.solution(); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		puzzles = append(puzzles, puzzle)
+	}
+}
+
+// solution returns the position of the puzzle and the moves that solve it.
+func (p Puzzle) solution() (*Game, []Move, error) {
+	if p.ID == "" {
+		return nil, nil, errors.New("the puzzle has no ID")
+	}
+	position, err := ParseFEN(p.FEN)
+	if err != nil {
+		return nil, nil, fmt.Errorf("puzzle %s: %v", p.ID, err)
+	}
+	if len(p.Moves)%2 != 1 {
+		return nil, nil, fmt.Errorf("puzzle %s: the solution must end with a move of the solver", p.ID)
+	}
+	solver := position.searchCopy()
+	moves := []Move{}
+	for _, notation := range p.Moves {
+		move, err := parseUCIMove(solver, notation)
+		if err != nil {
+			return nil, nil, fmt.Errorf("puzzle %s: %v", p.ID, err)
+		}
+		moves = append(moves, solver.applyMove(move))
+		solver.finishTurn(solver.GetCurrentPlayerColor())
+	}
+	return position, moves, nil
+}
@@ -0,0 +1,159 @@
+package gochess
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindPuzzles(t *testing.T) {
+	tests := []struct {
+		name   string
+		fen    string
+		first  string
+		themes []string
+	}{
+		{
+			name:   "back rank mate",
+			fen:    "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1",
+			first:  "a1a8",
+			themes: []string{"mate", "mateIn1"},
+		},
+		{
+			name:   "knight fork",
+			fen:    "4k3/8/8/5q2/4N3/8/P7/4K3 w - - 0 1",
+			first:  "e4d6",
+			themes: []string{"fork", "material"},
+		},
+		{
+			name:   "promotion",
+			fen:    "7k/P7/8/8/8/8/6r1/K7 w - - 0 1",
+			first:  "a7a8q",
+			themes: []string{"material", "promotion"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g, err := ParseFEN(test.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			puzzles, err := NewEngine().FindPuzzles(g, SearchLimits{Depth: 3})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(puzzles) != 1 {
+				t.Fatalf("Expected a puzzle, got %+v", puzzles)
+			}
+			puzzle := puzzles[0]
+			if puzzle.FEN != test.fen || puzzle.Moves[0] != test.first || len(puzzle.Moves)%2 != 1 {
+				t.Errorf("Expected the puzzle to start with %s, got %+v", test.first, puzzle)
+			}
+			if got := strings.Join(puzzle.Themes, " "); got != strings.Join(test.themes, " ") {
+				t.Errorf("Expected themes %v, got %v", test.themes, puzzle.Themes)
+			}
+		})
+	}
+}
+
+func TestFindPuzzlesInGame(t *testing.T) {
+	scholar := readTestGames(t, "games.pgn")[1]
+	puzzles, err := NewEngine().FindPuzzles(scholar.Game, SearchLimits{Depth: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the mate Black allowed with 3... Nf6 is a puzzle
+	want := "r1bqkb1r/pppp1ppp/2n2n2/4p2Q/2B1P3/8/PPPP1PPP/RNB1K1NR w KQkq - 4 4"
+	if len(puzzles) != 1 || puzzles[0].FEN != want || strings.Join(puzzles[0].Moves, " ") != "h5f7" {
+		t.Errorf("Expected the mate after 3... Nf6, got %+v", puzzles)
+	}
+}
+
+func TestNoPuzzleWithoutTactic(t *testing.T) {
+	tests := []struct {
+		name  string
+		fen   string
+		moves []string
+	}{
+		{name: "starting position", fen: "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"},
+		{name: "already winning", fen: "4k3/8/8/8/8/8/PPP5/1K1Q4 w - - 0 1"},
+		{name: "recapture", fen: "4k3/8/8/8/8/8/3Q4/3qK2R b - - 0 1", moves: []string{"d1d2"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g, err := ParseFEN(test.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			playMoves(t, g, test.moves...)
+			puzzles, err := NewEngine().FindPuzzles(g, SearchLimits{Depth: 2})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The position before the recapture has no puzzle either
+			if len(puzzles) != 0 {
+				t.Errorf("Expected no puzzle, got %+v", puzzles)
+			}
+		})
+	}
+}
+
+func TestWritePuzzles(t *testing.T) {
+	var out strings.Builder
+	err := WritePuzzles(&out, []Puzzle{{ID: "00ff", FEN: "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", Moves: []string{"a1a8"}, Themes: []string{"mate", "mateIn1"}, Source: "alice - bob, move 30"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "PuzzleId,FEN,Moves,Themes,Source\n00ff,6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1,a1a8,mate mateIn1,\"alice - bob, move 30\"\n"
+	if out.String() != want {
+		t.Errorf("WritePuzzles() = %q, want %q", out.String(), want)
+	}
+}
+
+func TestReadPuzzles(t *testing.T) {
+	puzzles := []Puzzle{
+		{ID: "00ff", FEN: "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", Moves: []string{"a1a8"}, Themes: []string{"mate", "mateIn1"}, Source: "alice - bob, move 30"},
+		{ID: "0100", FEN: "7k/P7/8/8/8/8/6r1/K7 w - - 0 1", Moves: []string{"a7a8q"}, Themes: []string{"promotion"}},
+	}
+	var out strings.Builder
+	if err := WritePuzzles(&out, puzzles); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadPuzzles(strings.NewReader(out.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != 2 || read[0].Source != puzzles[0].Source || read[1].Moves[0] != "a7a8q" || read[1].Themes[0] != "promotion" {
+		t.Errorf("Expected the puzzles written to be read back, got %+v", read)
+	}
+
+	rated, err := ReadPuzzles(strings.NewReader("Rating,Moves,FEN,PuzzleId\n1834,a1a8,6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1,00ff\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rated) != 1 || rated[0].ID != "00ff" || rated[0].Rating != 1834 {
+		t.Errorf("Expected a puzzle rated 1834, got %+v", rated)
+	}
+}
+
+func TestReadPuzzlesErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+	}{
+		{name: "missing column", csv: "PuzzleId,FEN\n00ff,6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1\n"},
+		{name: "invalid FEN", csv: "PuzzleId,FEN,Moves\n00ff,6k1/5ppp,a1a8\n"},
+		{name: "illegal move", csv: "PuzzleId,FEN,Moves\n00ff,6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1,a1h8\n"},
+		{name: "ends with a reply", csv: "PuzzleId,FEN,Moves\n00ff,6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1,a1a2 g8h8\n"},
+		{name: "invalid rating", csv: "PuzzleId,FEN,Moves,Rating\n00ff,6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1,a1a8,hard\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := ReadPuzzles(strings.NewReader(test.csv)); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
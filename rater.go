@@ -0,0 +1,399 @@
+package gochess
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	EloSystem    = "Elo"
+	GlickoSystem = "Glicko-2"
+)
+
+// RatingChange is an entry in a player's rating history.
+type RatingChange struct {
+	System string
+	Time   time.Time
+	GameID string `json:",omitempty"`
+	Before float64
+	After  float64
+}
+
+// PlayerRatings holds a player's ratings in every rating system, along with
+// the games waiting for the end of the current Glicko-2 rating period.
+type PlayerRatings struct {
+	Username      string
+	Elo           Rating
+	Glicko        Rating
+	PendingGlicko []GlickoResult `json:",omitempty"`
+	History       []RatingChange
+}
+
+func NewPlayerRatings(username string) *PlayerRatings {
+	return &PlayerRatings{Username: username, Elo: NewEloRating(), Glicko: NewGlickoRating()}
+}
+
+// RatingStore persists the ratings of every player.
+type RatingStore interface {
+	GetRatings(username string) (*PlayerRatings, error)
+	SaveRatings(r *PlayerRatings) error
+	AllRatings() ([]*PlayerRatings, error)
+	// PeriodStart is when the current Glicko-2 rating period started, zero
+	// before the first one
+	PeriodStart() (time.Time, error)
+	SavePeriodStart(start time.Time) error
+}
+
+// Rater updates ratings when games end. Elo ratings change straight away,
+// Glicko-2 ratings when ClosePeriod is called at the end of a rating period.
+type Rater struct {
+	NopGameListener
+
+	mu     sync.Mutex
+	store  RatingStore
+	elo    Elo
+	glicko Glicko2
+}
+
+func NewRater(store RatingStore, elo Elo, glicko Glicko2) *Rater {
+	return &Rater{store: store, elo: elo, glicko: glicko}
+}
+
+// Ratings returns the ratings of a player, who starts at the default
+// ratings if they have not played a rated game yet.
+func (r *Rater) Ratings(username string) (*PlayerRatings, error) {
+	ratings, err := r.store.GetRatings(username)
+	if errors.Is(err, ErrUserNotFound) {
+		return NewPlayerRatings(username), nil
+	}
+	return ratings, err
+}
+
+// isRated reports whether a game counts for ratings. Casual games and games
+// with guests are not rated.
+func isRated(g *Game) bool {
+	if g.Casual {
+		return false
+	}
+	for _, player := range g.Players {
+		if player.Name == "" || strings.HasPrefix(player.Name, guestPrefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// RecordGame updates the ratings of both players of a finished game.
+func (r *Rater) RecordGame(g *Game) error {
+	if !g.IsOver() {
+		return errors.New("Game is not over, got state: " + string(g.State))
+	}
+	if !isRated(g) {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	white, err := r.Ratings(g.Players[0].Name)
+	if err != nil {
+		return err
+	}
+	black, err := r.Ratings(g.Players[1].Name)
+	if err != nil {
+		return err
+	}
+
+	now := timeNow()
+	whiteElo, blackElo := white.Elo, black.Elo
+	whiteGlicko, blackGlicko := white.Glicko, black.Glicko
+	for _, p := range []struct {
+		ratings        *PlayerRatings
+		opponentElo    Rating
+		opponentGlicko Rating
+		score          float64
+	}{
+		{white, blackElo, blackGlicko, Score(g.State, White)},
+		{black, whiteElo, whiteGlicko, Score(g.State, Black)},
+	} {
+		before := p.ratings.Elo.Rating
+		p.ratings.Elo = r.elo.Update(p.ratings.Elo, p.opponentElo, p.score)
+		p.ratings.History = append(p.ratings.History, RatingChange{
+			System: EloSystem,
+			Time:   now,
+			GameID: g.ID,
+			Before: before,
+			After:  p.ratings.Elo.Rating,
+		})
+		p.ratings.PendingGlicko = append(p.ratings.PendingGlicko, GlickoResult{Opponent: p.opponentGlicko, Score: p.score})
+
+		if err := r.store.SaveRatings(p.ratings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClosePeriod ends the current Glicko-2 rating period, applying the games
+// played during it. Players who did not play become less certain of their
+// rating.
+func (r *Rater) ClosePeriod() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	all, err := r.store.AllRatings()
+	if err != nil {
+		return err
+	}
+
+	now := timeNow()
+	for _, ratings := range all {
+		before := ratings.Glicko.Rating
+		ratings.Glicko = r.glicko.Update(ratings.Glicko, ratings.PendingGlicko)
+		if len(ratings.PendingGlicko) > 0 {
+			ratings.History = append(ratings.History, RatingChange{
+				System: GlickoSystem,
+				Time:   now,
+				Before: before,
+				After:  ratings.Glicko.Rating,
+			})
+		}
+		ratings.PendingGlicko = nil
+
+		if err := r.store.SaveRatings(ratings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClosePeriods closes every Glicko-2 rating period of the given length that
+// has ended by now, counting from the start stored with the ratings, so that
+// periods keep their length across restarts. The first call starts a period.
+func (r *Rater) ClosePeriods(length time.Duration) error {
+	start, err := r.store.PeriodStart()
+	if err != nil {
+		return err
+	}
+	now := timeNow()
+	if start.IsZero() {
+		return r.store.SavePeriodStart(now)
+	}
+
+	for end := start.Add(length); !now.Before(end); end = end.Add(length) {
+		if err := r.ClosePeriod(); err != nil {
+			return err
+		}
+		// Saved after each period so a failure does not close it twice
+		if err := r.store.SavePeriodStart(end); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Leaderboard returns every rated player, best first in the given system.
+func (r *Rater) Leaderboard(system string) ([]*PlayerRatings, error) {
+	all, err := r.store.AllRatings()
+	if err != nil {
+		return nil, err
+	}
+
+	rating := func(p *PlayerRatings) float64 {
+		if system == GlickoSystem {
+			return p.Glicko.Rating
+		}
+		return p.Elo.Rating
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return rating(all[i]) > rating(all[j])
+	})
+	return all, nil
+}
+
+// OnGameOver rates games as soon as they end.
+func (r *Rater) OnGameOver(g *Game, state GameState) {
+	if err := r.RecordGame(g); err != nil {
+		log.Println("Failed to rate game", g.ID, err)
+	}
+}
+
+type MemoryRatingStore struct {
+	mu          sync.Mutex
+	ratings     map[string][]byte
+	periodStart time.Time
+}
+
+func NewMemoryRatingStore() *MemoryRatingStore {
+	return &MemoryRatingStore{ratings: map[string][]byte{}}
+}
+
+func (s *MemoryRatingStore) GetRatings(username string) (*PlayerRatings, error) {
+	s.mu.Lock()
+	data, ok := s.ratings[username]
+	s.mu.Unlock()
+
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	var ratings PlayerRatings
+	if err := json.Unmarshal(data, &ratings); err != nil {
+		return nil, err
+	}
+	return &ratings, nil
+}
+
+func (s *MemoryRatingStore) SaveRatings(ratings *PlayerRatings) error {
+	data, err := json.Marshal(ratings)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ratings[ratings.Username] = data
+	return nil
+}
+
+func (s *MemoryRatingStore) AllRatings() ([]*PlayerRatings, error) {
+	s.mu.Lock()
+	names := make([]string, 0, len(s.ratings))
+	for name := range s.ratings {
+		names = append(names, name)
+	}
+	s.mu.Unlock()
+	sort.Strings(names)
+
+	all := []*PlayerRatings{}
+	for _, name := range names {
+		ratings, err := s.GetRatings(name)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, ratings)
+	}
+	return all, nil
+}
+
+func (s *MemoryRatingStore) PeriodStart() (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.periodStart, nil
+}
+
+func (s *MemoryRatingStore) SavePeriodStart(start time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.periodStart = start
+	return nil
+}
+
+// FileRatingStore keeps one JSON file per player in a directory, and the
+// start of the rating period in a file of its own.
+type FileRatingStore struct {
+	dir string
+}
+
+func NewFileRatingStore(dir string) (*FileRatingStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileRatingStore{dir: dir}, nil
+}
+
+func (s *FileRatingStore) path(username string) (string, error) {
+	if !validUsername.MatchString(username) {
+		return "", ErrUserNotFound
+	}
+	return filepath.Join(s.dir, username+".json"), nil
+}
+
+func (s *FileRatingStore) GetRatings(username string) (*PlayerRatings, error) {
+	path, err := s.path(username)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ratings PlayerRatings
+	if err := json.Unmarshal(data, &ratings); err != nil {
+		return nil, err
+	}
+	return &ratings, nil
+}
+
+func (s *FileRatingStore) SaveRatings(ratings *PlayerRatings) error {
+	path, err := s.path(ratings.Username)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(ratings, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *FileRatingStore) AllRatings() ([]*PlayerRatings, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	all := []*PlayerRatings{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".json" {
+			continue
+		}
+		ratings, err := s.GetRatings(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, ratings)
+	}
+	return all, nil
+}
+
+func (s *FileRatingStore) periodPath() string {
+	return filepath.Join(s.dir, "period-start")
+}
+
+func (s *FileRatingStore) PeriodStart() (time.Time, error) {
+	data, err := os.ReadFile(s.periodPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339Nano, strings.TrimSpace(string(data)))
+}
+
+func (s *FileRatingStore) SavePeriodStart(start time.Time) error {
+	path := s.periodPath()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(start.Format(time.RFC3339Nano)+"\n"), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,158 @@
+package gochess
+
+import "math"
+
+const (
+	DefaultRating     = 1500
+	DefaultDeviation  = 350
+	DefaultVolatility = 0.06
+
+	// glickoScale converts between the Glicko and Glicko-2 scales
+	glickoScale = 173.7178
+)
+
+// Rating is a player's strength in one rating system. Elo only uses the
+// Rating, Glicko-2 also tracks the deviation and volatility.
+type Rating struct {
+	Rating     float64
+	Deviation  float64 `json:",omitempty"`
+	Volatility float64 `json:",omitempty"`
+	Games      int
+}
+
+func NewEloRating() Rating {
+	return Rating{Rating: DefaultRating}
+}
+
+func NewGlickoRating() Rating {
+	return Rating{Rating: DefaultRating, Deviation: DefaultDeviation, Volatility: DefaultVolatility}
+}
+
+// Score returns the points scored by the given color in a finished game.
+func Score(state GameState, color PieceColor) float64 {
+	switch {
+	case state == Draw:
+		return 0.5
+	case state == WhiteWon && color == White, state == BlackWon && color == Black:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Elo rates players with the classic Elo formula.
+type Elo struct {
+	K float64
+}
+
+// ExpectedScore returns the expected score of a player against an opponent.
+func (e Elo) ExpectedScore(player, opponent Rating) float64 {
+	return 1 / (1 + math.Pow(10, (opponent.Rating-player.Rating)/400))
+}
+
+// Update returns the player's new rating after scoring the given points
+// against the opponent.
+func (e Elo) Update(player, opponent Rating, score float64) Rating {
+	player.Rating += e.K * (score - e.ExpectedScore(player, opponent))
+	player.Games++
+	return player
+}
+
+// GlickoResult is one game played during a Glicko-2 rating period.
+type GlickoResult struct {
+	Opponent Rating
+	Score    float64
+}
+
+// Glicko2 rates players with Glickman's Glicko-2 system. Results are
+// collected over a rating period and applied together at its end.
+type Glicko2 struct {
+	// Tau constrains how much the volatility can change, usually 0.3 to 1.2
+	Tau float64
+}
+
+func glickoG(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+func glickoE(mu, muJ, phiJ float64) float64 {
+	return 1 / (1 + math.Exp(-glickoG(phiJ)*(mu-muJ)))
+}
+
+// Update returns the player's rating at the end of a rating period in which
+// the given games were played. A player without games only becomes less
+// certain of their rating.
+func (gl Glicko2) Update(player Rating, results []GlickoResult) Rating {
+	mu := (player.Rating - DefaultRating) / glickoScale
+	phi := player.Deviation / glickoScale
+	sigma := player.Volatility
+
+	if len(results) == 0 {
+		player.Deviation = math.Min(math.Sqrt(phi*phi+sigma*sigma)*glickoScale, DefaultDeviation)
+		return player
+	}
+
+	// Estimated variance and improvement based on the game outcomes
+	var vInv, sum float64
+	for _, result := range results {
+		muJ := (result.Opponent.Rating - DefaultRating) / glickoScale
+		phiJ := result.Opponent.Deviation / glickoScale
+		g := glickoG(phiJ)
+		e := glickoE(mu, muJ, phiJ)
+		vInv += g * g * e * (1 - e)
+		sum += g * (result.Score - e)
+	}
+	v := 1 / vInv
+	delta := v * sum
+
+	sigma = gl.volatility(phi, sigma, v, delta)
+
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phi = 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	mu += phi * phi * sum
+
+	player.Rating = mu*glickoScale + DefaultRating
+	player.Deviation = phi * glickoScale
+	player.Volatility = sigma
+	player.Games += len(results)
+	return player
+}
+
+// volatility finds the new volatility with the Illinois algorithm as
+// described in step 5 of the Glicko-2 paper.
+func (gl Glicko2) volatility(phi, sigma, v, delta float64) float64 {
+	const epsilon = 0.000001
+
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-d)/(2*d*d) - (x-a)/(gl.Tau*gl.Tau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*gl.Tau) < 0 {
+			k++
+		}
+		B = a - k*gl.Tau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > epsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+
+	return math.Exp(A / 2)
+}
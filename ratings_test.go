@@ -0,0 +1,198 @@
+package gochess
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEloUpdate(t *testing.T) {
+	tests := []struct {
+		name     string
+		player   float64
+		opponent float64
+		score    float64
+		want     float64
+	}{
+		{name: "win against equal", player: 1500, opponent: 1500, score: 1, want: 1516},
+		{name: "draw against equal", player: 1500, opponent: 1500, score: 0.5, want: 1500},
+		{name: "loss against stronger", player: 1500, opponent: 1900, score: 0, want: 1497.09},
+		{name: "win against stronger", player: 1500, opponent: 1900, score: 1, want: 1529.09},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Elo{K: 32}.Update(Rating{Rating: tt.player}, Rating{Rating: tt.opponent}, tt.score)
+			if math.Abs(got.Rating-tt.want) > 0.01 {
+				t.Errorf("Update() = %.2f, want %.2f", got.Rating, tt.want)
+			}
+			if got.Games != 1 {
+				t.Errorf("Expected 1 game, but got %d", got.Games)
+			}
+		})
+	}
+}
+
+// The example from Glickman's "Example of the Glicko-2 system"
+func TestGlicko2Update(t *testing.T) {
+	player := Rating{Rating: 1500, Deviation: 200, Volatility: 0.06}
+	results := []GlickoResult{
+		{Opponent: Rating{Rating: 1400, Deviation: 30}, Score: 1},
+		{Opponent: Rating{Rating: 1550, Deviation: 100}, Score: 0},
+		{Opponent: Rating{Rating: 1700, Deviation: 300}, Score: 0},
+	}
+
+	got := Glicko2{Tau: 0.5}.Update(player, results)
+
+	if math.Abs(got.Rating-1464.06) > 0.01 {
+		t.Errorf("Expected rating 1464.06, but got %.2f", got.Rating)
+	}
+	if math.Abs(got.Deviation-151.52) > 0.01 {
+		t.Errorf("Expected deviation 151.52, but got %.2f", got.Deviation)
+	}
+	if math.Abs(got.Volatility-0.05999) > 0.00001 {
+		t.Errorf("Expected volatility 0.05999, but got %.5f", got.Volatility)
+	}
+}
+
+func TestGlicko2UpdateWithoutGames(t *testing.T) {
+	player := Rating{Rating: 1500, Deviation: 200, Volatility: 0.06}
+
+	got := Glicko2{Tau: 0.5}.Update(player, nil)
+
+	if got.Rating != 1500 {
+		t.Errorf("Expected rating to stay 1500, but got %.2f", got.Rating)
+	}
+	if math.Abs(got.Deviation-200.27) > 0.01 {
+		t.Errorf("Expected deviation 200.27, but got %.2f", got.Deviation)
+	}
+}
+
+func TestRater(t *testing.T) {
+	fileStore, err := NewFileRatingStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileRatingStore() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		store RatingStore
+	}{
+		{name: "memory", store: NewMemoryRatingStore()},
+		{name: "file", store: fileStore},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rater := NewRater(tt.store, Elo{K: 20}, Glicko2{Tau: 0.5})
+
+			g := NewGame("alice", "bob")
+			g.AddListener(rater)
+			playMoves(t, g, "f2f3", "e7e5", "g2g4", "d8h4")
+
+			alice, _ := rater.Ratings("alice")
+			bob, _ := rater.Ratings("bob")
+			if alice.Elo.Rating != 1490 || bob.Elo.Rating != 1510 {
+				t.Errorf("Expected Elo ratings 1490 and 1510, but got %.2f and %.2f", alice.Elo.Rating, bob.Elo.Rating)
+			}
+			if len(bob.History) != 1 || bob.History[0].GameID != g.ID || bob.History[0].After != 1510 {
+				t.Errorf("Expected a rating history entry for the game, but got %v", bob.History)
+			}
+			if bob.Glicko.Rating != DefaultRating || len(bob.PendingGlicko) != 1 {
+				t.Errorf("Expected the Glicko-2 rating to wait for the end of the period, but got %+v", bob.Glicko)
+			}
+
+			if err := rater.ClosePeriod(); err != nil {
+				t.Fatalf("ClosePeriod() error = %v", err)
+			}
+			alice, _ = rater.Ratings("alice")
+			bob, _ = rater.Ratings("bob")
+			if bob.Glicko.Rating <= DefaultRating || alice.Glicko.Rating >= DefaultRating {
+				t.Errorf("Expected Bob above and Alice below %d, but got %.2f and %.2f", DefaultRating, bob.Glicko.Rating, alice.Glicko.Rating)
+			}
+			if len(bob.PendingGlicko) != 0 {
+				t.Errorf("Expected no pending games after closing the period, but got %v", bob.PendingGlicko)
+			}
+
+			leaders, err := rater.Leaderboard(GlickoSystem)
+			if err != nil {
+				t.Fatalf("Leaderboard() error = %v", err)
+			}
+			if len(leaders) != 2 || leaders[0].Username != "bob" {
+				t.Errorf("Expected Bob to lead, but got %v", leaders)
+			}
+
+			// Games with guests are not rated
+			guestGame := NewGame("alice", guestPrefix+"12345678")
+			if err := guestGame.Resign(White); err != nil {
+				t.Fatalf("Resign() error = %v", err)
+			}
+			if err := rater.RecordGame(guestGame); err != nil {
+				t.Fatalf("RecordGame() error = %v", err)
+			}
+			if leaders, _ := rater.Leaderboard(EloSystem); len(leaders) != 2 {
+				t.Errorf("Expected guests to not be rated, but got %v", leaders)
+			}
+		})
+	}
+}
+
+func TestRatingPeriods(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	fileStore, err := NewFileRatingStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileRatingStore() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		store RatingStore
+	}{
+		{name: "memory", store: NewMemoryRatingStore()},
+		{name: "file", store: fileStore},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := now
+			rater := NewRater(tt.store, Elo{K: 20}, Glicko2{Tau: 0.5})
+			if err := rater.ClosePeriods(7 * day); err != nil {
+				t.Fatalf("ClosePeriods() error = %v", err)
+			}
+			if got, _ := tt.store.PeriodStart(); !got.Equal(start) {
+				t.Errorf("Expected the first period to start now, but got %v", got)
+			}
+
+			g := NewGame("alice", "bob")
+			g.AddListener(rater)
+			playMoves(t, g, "f2f3", "e7e5", "g2g4", "d8h4")
+
+			// A restart does not start the period again
+			now = now.Add(3 * day)
+			rater = NewRater(tt.store, Elo{K: 20}, Glicko2{Tau: 0.5})
+			if err := rater.ClosePeriods(7 * day); err != nil {
+				t.Fatalf("ClosePeriods() error = %v", err)
+			}
+			if bob, _ := rater.Ratings("bob"); len(bob.PendingGlicko) != 1 {
+				t.Errorf("Expected the period to still be open, but got %+v", bob)
+			}
+
+			// Periods that ended while the server was down are all closed
+			now = now.Add(12 * day)
+			if err := rater.ClosePeriods(7 * day); err != nil {
+				t.Fatalf("ClosePeriods() error = %v", err)
+			}
+			bob, _ := rater.Ratings("bob")
+			if len(bob.PendingGlicko) != 0 || bob.Glicko.Rating <= DefaultRating {
+				t.Errorf("Expected the game to be rated, but got %+v", bob)
+			}
+			if got, _ := tt.store.PeriodStart(); !got.Equal(start.Add(14 * day)) {
+				t.Errorf("Expected the third period to start after two weeks, but got %v", got)
+			}
+			now = start
+		})
+	}
+}
@@ -0,0 +1,414 @@
+package gochess
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MoveClass is how bad a move of a reviewed game is.
+type MoveClass string
+
+const (
+	GoodMove   MoveClass = ""
+	Inaccuracy MoveClass = "Inaccuracy"
+	Mistake    MoveClass = "Mistake"
+	Blunder    MoveClass = "Blunder"
+)
+
+// The centipawn loss from which a move is an inaccuracy, a mistake or a blunder
+const (
+	inaccuracyLoss = 50
+	mistakeLoss    = 100
+	blunderLoss    = 300
+)
+
+// reviewScoreCap bounds the scores compared in a review, so that a mate
+// counts as a won position rather than an endless loss
+const reviewScoreCap = 1000
+
+// moveClassNAGs are the numeric annotation glyphs of the classes: ?!, ? and ??
+var moveClassNAGs = map[MoveClass]string{Inaccuracy: "$6", Mistake: "$2", Blunder: "$4"}
+
+// MoveReview is the engine's verdict on a move of a game.
+type MoveReview struct {
+	Move Move
+	SAN  string
+	// Best is the engine's choice in the position before the move, with the
+	// line it expects
+	Best    SearchResult
+	BestSAN string
+	// Score is the score of the position after the move in centipawns, from
+	// the point of view of the player who moved
+	Score int
+	// Loss is how many centipawns the move gave away compared to the best
+	Loss  int
+	Class MoveClass
+	// MissedMate is the number of moves of a forced mate the player had and
+	// lost with the move, zero otherwise
+	MissedMate int
+	// Ended is set when the move ended the game with mate or stalemate
+	Ended bool
+}
+
+// GameReview is the engine's review of every move of a game.
+type GameReview struct {
+	Moves []MoveReview
+	// Accuracy is how close White and Black played to the engine's moves in
+	// percent, measured on their chances of winning like Lichess does
+	Accuracy [2]float64
+	// AverageLoss is the average centipawn loss of White and Black
+	AverageLoss [2]int
+}
+
+// startPosition returns a copy of the position the game started from.
+func (g *Game) startPosition() *Game {
+	position := g.searchCopy()
+	for len(position.History) > 0 {
+		position.takeBack()
+	}
+	position.State = Ongoing
+	return position
+}
+
+// Review searches the position before every move of the game within the
+// limits and compares the move played with the engine's choice.
+func (e *Engine) Review(g *Game, limits SearchLimits) (*GameReview, error) {
+	position := g.startPosition()
+	review := &GameReview{}
+	if len(g.History) == 0 {
+		return review, nil
+	}
+
+	best, err := e.reviewSearch(position, limits)
+	if err != nil {
+		return nil, err
+	}
+	moves, losses := [2]int{}, [2]int{}
+	accuracy := [2]float64{}
+	for _, move := range g.History {
+		moveReview := MoveReview{Move: move, SAN: position.SAN(move), Best: best, BestSAN: position.SAN(best.Move)}
+
+		position.applyMove(move)
+		position.finishTurn(position.GetCurrentPlayerColor())
+		var next SearchResult
+		switch position.State {
+		case Ongoing:
+			if next, err = e.reviewSearch(position, limits); err != nil {
+				return nil, err
+			}
+			moveReview.Score = -next.Score
+			// The mate is a ply further away from the position before
+			if next.IsMate() && next.Score > 0 {
+				moveReview.Score++
+			} else if next.IsMate() {
+				moveReview.Score--
+			}
+		case Draw:
+			moveReview.Ended = true
+		default:
+			moveReview.Ended = true
+			moveReview.Score = mateScore - 1
+		}
+
+		if sameMove(move, best.Move) {
+			moveReview.Score = best.Score
+		}
+		before, after := capReviewScore(best.Score), capReviewScore(moveReview.Score)
+		if before > after {
+			moveReview.Loss = before - after
+		}
+		switch {
+		case moveReview.Loss >= blunderLoss:
+			moveReview.Class = Blunder
+		case moveReview.Loss >= mistakeLoss:
+			moveReview.Class = Mistake
+		case moveReview.Loss >= inaccuracyLoss:
+			moveReview.Class = Inaccuracy
+		}
+		if best.MateIn() > 0 && (SearchResult{Score: moveReview.Score}).MateIn() <= 0 {
+			moveReview.MissedMate = best.MateIn()
+		}
+
+		i := colorIndex(move.Color)
+		moves[i]++
+		losses[i] += moveReview.Loss
+		accuracy[i] += moveAccuracy(before, after)
+		review.Moves = append(review.Moves, moveReview)
+		best = next
+	}
+
+	for i := range moves {
+		if moves[i] > 0 {
+			review.AverageLoss[i] = losses[i] / moves[i]
+			review.Accuracy[i] = accuracy[i] / float64(moves[i])
+		}
+	}
+	return review, nil
+}
+
+// reviewSearch finds the engine's choice in a position of the game, never
+// from the book.
+func (e *Engine) reviewSearch(position *Game, limits SearchLimits) (SearchResult, error) {
+	lines, err := e.Analyze(position, limits, 1, nil)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	return lines[0], nil
+}
+
+func sameMove(a, b Move) bool {
+	return a.From == b.From && a.To == b.To && a.Promotion == b.Promotion
+}
+
+func capReviewScore(score int) int {
+	if score > reviewScoreCap {
+		return reviewScoreCap
+	}
+	if score < -reviewScoreCap {
+		return -reviewScoreCap
+	}
+	return score
+}
+
+// winChance is the chance in percent of winning a position with the score,
+// fitted by Lichess on its games.
+func winChance(score int) float64 {
+	return 50 + 50*(2/(1+math.Exp(-0.00368208*float64(score)))-1)
+}
+
+// moveAccuracy is the accuracy in percent of a move that changes the score
+// of the player from before to after.
+func moveAccuracy(before, after int) float64 {
+	drop := winChance(before) - winChance(after)
+	if drop <= 0 {
+		return 100
+	}
+	return math.Max(0, math.Min(100, 103.1668*math.Exp(-0.04354*drop)-3.1669))
+}
+
+// Reviewer reviews games in the background once they are over and keeps
+// their reviews.
+type Reviewer struct {
+	NopGameListener
+	engine *Engine
+	limits SearchLimits
+
+	mu      sync.Mutex
+	reviews map[string]*GameReview
+	// started holds the games reviewed or being reviewed
+	started map[string]bool
+	running sync.WaitGroup
+}
+
+func NewReviewer(engine *Engine, limits SearchLimits) *Reviewer {
+	return &Reviewer{engine: engine, limits: limits, reviews: map[string]*GameReview{}, started: map[string]bool{}}
+}
+
+func (r *Reviewer) OnGameOver(g *Game, state GameState) {
+	r.Start(g)
+}
+
+// Start reviews the game in the background, unless it has been already.
+func (r *Reviewer) Start(g *Game) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started[g.ID] {
+		return
+	}
+	r.started[g.ID] = true
+
+	position := g.searchCopy()
+	r.running.Add(1)
+	go func() {
+		defer r.running.Done()
+		review, err := r.engine.Review(position, r.limits)
+		if err != nil {
+			log.Println("Failed to review game", position.ID, err)
+			return
+		}
+		r.mu.Lock()
+		r.reviews[position.ID] = review
+		r.mu.Unlock()
+	}()
+}
+
+// Wait waits for the reviews running to finish.
+func (r *Reviewer) Wait() {
+	r.running.Wait()
+}
+
+// Review returns the review of the game, or nil until it is done.
+func (r *Reviewer) Review(id string) *GameReview {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reviews[id]
+}
+
+// pgnTags returns the tags of a game played on the server.
+func pgnTags(g *Game) map[string]string {
+	result := "*"
+	switch g.State {
+	case WhiteWon:
+		result = "1-0"
+	case BlackWon:
+		result = "0-1"
+	case Draw:
+		result = "1/2-1/2"
+	}
+	return map[string]string{
+		"Event":  "gochess game",
+		"Site":   "gochess",
+		"Date":   "????.??.??",
+		"Round":  "-",
+		"White":  g.Players[0].Name,
+		"Black":  g.Players[1].Name,
+		"Result": result,
+	}
+}
+
+// pgnRoster is the order of the tags every PGN game starts with
+var pgnRoster = []string{"Event", "Site", "Date", "Round", "White", "Black", "Result"}
+
+// WriteAnnotatedPGN writes the game with its review in PGN: the evaluation
+// after every move as a [%eval] comment, a glyph and a comment on the
+// inaccuracies, mistakes and blunders, and the engine's line instead as a
+// variation.
+func WriteAnnotatedPGN(w io.Writer, tags map[string]string, g *Game, review *GameReview) error {
+	position := g.startPosition()
+	tags = copyTags(tags)
+	if fen := position.FEN(); fen != NewGame("", "").FEN() {
+		tags["SetUp"], tags["FEN"] = "1", fen
+	}
+	if g.Chess960 {
+		tags["Variant"] = "Chess960"
+	}
+	tags["Annotator"] = "gochess"
+	result, ok := tags["Result"]
+	if !ok {
+		result = "*"
+		tags["Result"] = result
+	}
+
+	var text strings.Builder
+	names := append([]string{}, pgnRoster...)
+	others := []string{}
+	for name := range tags {
+		if !contains(pgnRoster, name) {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	for _, name := range append(names, others...) {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(tags[name])
+		fmt.Fprintf(&text, "[%s \"%s\"]\n", name, value)
+	}
+	text.WriteString("\n")
+
+	tokens := []string{fmt.Sprintf("{White accuracy %.1f%%, average loss %d; Black accuracy %.1f%%, average loss %d}",
+		review.Accuracy[0], review.AverageLoss[0], review.Accuracy[1], review.AverageLoss[1])}
+	number := true
+	for i, move := range g.History {
+		ply := position.ply()
+		if ply%2 == 0 {
+			tokens = append(tokens, fmt.Sprintf("%d.", ply/2+1))
+		} else if number {
+			tokens = append(tokens, fmt.Sprintf("%d...", ply/2+1))
+		}
+		number = false
+		tokens = append(tokens, position.SAN(move))
+
+		if i < len(review.Moves) {
+			moveReview := review.Moves[i]
+			if nag, ok := moveClassNAGs[moveReview.Class]; ok {
+				tokens = append(tokens, nag)
+			}
+			if comment := moveReview.comment(); comment != "" {
+				tokens = append(tokens, "{"+comment+"}")
+				number = true
+			}
+			if moveReview.Class != GoodMove || moveReview.MissedMate > 0 {
+				tokens = append(tokens, "("+position.moveText(moveReview.Best.PV)+")")
+				number = true
+			}
+		}
+		position.applyMove(move)
+	}
+	tokens = append(tokens, result)
+
+	// Lines of movetext are kept under 80 characters
+	line := 0
+	for i, token := range tokens {
+		if i > 0 && line+1+len(token) > 79 {
+			text.WriteString("\n")
+			line = 0
+		} else if i > 0 {
+			text.WriteString(" ")
+			line++
+		}
+		text.WriteString(token)
+		line += len(token)
+	}
+	text.WriteString("\n\n")
+
+	_, err := io.WriteString(w, text.String())
+	return err
+}
+
+// comment explains the move in the annotated PGN, with the evaluation after
+// it from White's point of view.
+func (r MoveReview) comment() string {
+	parts := []string{}
+	if !r.Ended {
+		parts = append(parts, "[%eval "+r.eval()+"]")
+	}
+	switch {
+	case r.MissedMate > 0:
+		verdict := "Missed"
+		if r.Class != GoodMove {
+			verdict = string(r.Class) + ". Missed"
+		}
+		parts = append(parts, fmt.Sprintf("%s a mate in %d with %s.", verdict, r.MissedMate, r.BestSAN))
+	case r.Class != GoodMove:
+		parts = append(parts, fmt.Sprintf("%s. %s was best.", r.Class, r.BestSAN))
+	}
+	return strings.Join(parts, " ")
+}
+
+// eval writes the score after the move from White's point of view, in pawns
+// or as a mate in moves from the position after the move.
+func (r MoveReview) eval() string {
+	score := r.Score
+	if r.Move.Color == Black {
+		score = -score
+	}
+	if (SearchResult{Score: score}).IsMate() {
+		moves := (mateScore - abs(score)) / 2
+		if score < 0 {
+			moves = -moves
+		}
+		return fmt.Sprintf("#%d", moves)
+	}
+	return fmt.Sprintf("%.2f", float64(score)/100)
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	copied := map[string]string{}
+	for name, value := range tags {
+		copied[name] = value
+	}
+	return copied
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package gochess
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// readTestGames reads the games of a PGN file in testdata.
+func readTestGames(t *testing.T, name string) []PGNGame {
+	t.Helper()
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	games, err := ReadPGN(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return games
+}
+
+func TestReview(t *testing.T) {
+	scholar := readTestGames(t, "games.pgn")[1]
+	review, err := NewEngine().Review(scholar.Game, SearchLimits{Depth: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(review.Moves) != 7 {
+		t.Fatalf("Expected 7 reviewed moves, got %d", len(review.Moves))
+	}
+
+	blunder := review.Moves[5]
+	if blunder.SAN != "Nf6" || blunder.Class != Blunder || blunder.Loss < blunderLoss {
+		t.Errorf("Expected Nf6 to be a blunder, got %s as %q losing %d", blunder.SAN, blunder.Class, blunder.Loss)
+	}
+	if blunder.BestSAN == "Nf6" || blunder.Best.MateIn() != 0 {
+		t.Errorf("Expected another move to hold, got %s with score %d", blunder.BestSAN, blunder.Best.Score)
+	}
+	mate := review.Moves[6]
+	if mate.SAN != "Qxf7#" || mate.Class != GoodMove || !mate.Ended || mate.Loss != 0 {
+		t.Errorf("Expected the mate to be best, got %+v", mate)
+	}
+	if review.Accuracy[1] >= review.Accuracy[0] {
+		t.Errorf("Expected Black to be less accurate than White, got %v", review.Accuracy)
+	}
+	if review.AverageLoss[1] < blunderLoss/4 {
+		t.Errorf("Expected Black's blunder to raise the average loss, got %v", review.AverageLoss)
+	}
+}
+
+func TestReviewMissedMate(t *testing.T) {
+	g, err := ParseFEN("6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	playMoves(t, g, "g1f2", "g8f8")
+
+	review, err := NewEngine().Review(g, SearchLimits{Depth: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	missed := review.Moves[0]
+	if missed.MissedMate != 1 || missed.BestSAN != "Ra8#" || missed.Class != Blunder {
+		t.Errorf("Expected Kf2 to miss mate with Ra8#, got %+v", missed)
+	}
+	if review.Moves[1].MissedMate != 0 {
+		t.Errorf("Expected Black to have no mate, got %+v", review.Moves[1])
+	}
+}
+
+func TestMoveAccuracy(t *testing.T) {
+	tests := []struct {
+		before, after int
+		min, max      float64
+	}{
+		{before: 30, after: 30, min: 100, max: 100},
+		{before: 30, after: 60, min: 100, max: 100},
+		{before: 30, after: 0, min: 85, max: 99},
+		{before: 200, after: -300, min: 0, max: 20},
+	}
+	for _, test := range tests {
+		if got := moveAccuracy(test.before, test.after); got < test.min || got > test.max {
+			t.Errorf("moveAccuracy(%d, %d) = %.1f, want between %.0f and %.0f", test.before, test.after, got, test.min, test.max)
+		}
+	}
+}
+
+func TestWriteAnnotatedPGN(t *testing.T) {
+	scholar := readTestGames(t, "games.pgn")[1]
+	review, err := NewEngine().Review(scholar.Game, SearchLimits{Depth: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	if err := WriteAnnotatedPGN(&out, scholar.Tags, scholar.Game, review); err != nil {
+		t.Fatal(err)
+	}
+	pgn := out.String()
+	movetext := strings.Join(strings.Fields(pgn), " ")
+	for _, want := range []string{
+		"[Event \"Club night\"] [Site \"gochess\"]",
+		"[Annotator \"gochess\"]",
+		"{White accuracy",
+		"1. e4 ",
+		"Nf6 $4 {[%eval #1] Blunder. " + review.Moves[5].BestSAN + " was best.} (3... " + review.Moves[5].BestSAN,
+		") 4. Qxf7# 1-0",
+	} {
+		if !strings.Contains(movetext, want) {
+			t.Errorf("Expected %q in the annotated PGN, got\n%s", want, pgn)
+		}
+	}
+	for _, line := range strings.Split(pgn, "\n") {
+		if len(line) >= 80 {
+			t.Errorf("Expected lines under 80 characters, got %q", line)
+		}
+	}
+
+	// The annotations are read back as comments, glyphs and variations
+	games, err := ReadPGN(strings.NewReader(pgn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 1 || games[0].Game.FEN() != scholar.Game.FEN() || games[0].Result() != "1-0" {
+		t.Errorf("Expected the game to be read back, got %+v", games)
+	}
+}
+
+func TestWriteAnnotatedPGNFromPosition(t *testing.T) {
+	g, err := ParseFEN("6k1/5ppp/8/8/8/8/8/R5K1 b - - 0 30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	playMoves(t, g, "g8h8", "a1a8")
+	g.finishTurn(g.GetCurrentPlayerColor())
+	review, err := NewEngine().Review(g, SearchLimits{Depth: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	if err := WriteAnnotatedPGN(&out, pgnTags(g), g, review); err != nil {
+		t.Fatal(err)
+	}
+	pgn := out.String()
+	for _, want := range []string{"[Result \"1-0\"]", "[FEN \"6k1/5ppp/8/8/8/8/8/R5K1 b - - 0 30\"]", "[SetUp \"1\"]", "30... Kh8", "31. Ra8# 1-0"} {
+		if !strings.Contains(pgn, want) {
+			t.Errorf("Expected %q in the annotated PGN, got\n%s", want, pgn)
+		}
+	}
+}
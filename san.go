@@ -0,0 +1,168 @@
+package gochess
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SAN writes a legal move of the player on turn in Standard Algebraic
+// Notation, like Nbd7, exd6, O-O or e8=Q+.
+func (g *Game) SAN(m Move) string {
+	piece := g.Board[m.From.X][m.From.Y]
+	san := ""
+	switch {
+	case g.isCastling(m):
+		san = "O-O"
+		if m.To.X < m.From.X {
+			san = "O-O-O"
+		}
+	case piece.Type == Pawn:
+		if m.From.X != m.To.X {
+			san = string(rune('a'+m.From.X)) + "x"
+		}
+		san += squareName(m.To)
+		if m.Promotion != "" {
+			san += "=" + strings.ToUpper(string(pieceLetters[m.Promotion]))
+		}
+	default:
+		san = strings.ToUpper(string(pieceLetters[piece.Type]))
+
+		// Name the file, the rank or both of the piece when another piece
+		// of the same type can go to the same square
+		ambiguous, sameFile, sameRank := false, false, false
+		for _, other := range g.legalMoves(m.Color) {
+			if other.To != m.To || other.From == m.From || g.Board[other.From.X][other.From.Y].Type != piece.Type {
+				continue
+			}
+			ambiguous = true
+			sameFile = sameFile || other.From.X == m.From.X
+			sameRank = sameRank || other.From.Y == m.From.Y
+		}
+		if ambiguous {
+			switch {
+			case !sameFile:
+				san += string(rune('a' + m.From.X))
+			case !sameRank:
+				san += string(rune('1' + m.From.Y))
+			default:
+				san += squareName(m.From)
+			}
+		}
+		if g.Board[m.To.X][m.To.Y] != nil {
+			san += "x"
+		}
+		san += squareName(m.To)
+	}
+
+	position := g.searchCopy()
+	position.applyMove(m)
+	opponent := position.GetCurrentPlayerColor()
+	if position.IsCheck(opponent) {
+		if len(position.legalMoves(opponent)) == 0 {
+			return san + "#"
+		}
+		return san + "+"
+	}
+	return san
+}
+
+// ParseSAN finds the legal move of the player on turn written in Standard
+// Algebraic Notation. Check marks and annotations are optional, castling can
+// be written with zeros and the piece can be named more fully than needed.
+func (g *Game) ParseSAN(san string) (Move, error) {
+	text := strings.TrimRight(san, "+#!?")
+	legal := g.LegalMoves()
+
+	switch strings.ReplaceAll(text, "0", "O") {
+	case "O-O", "O-O-O":
+		for _, move := range legal {
+			if g.isCastling(move) && (move.To.X > move.From.X) == (len(text) == 3) {
+				return move, nil
+			}
+		}
+		return Move{}, fmt.Errorf("illegal move %s", san)
+	}
+
+	pieceType := Pawn
+	if text != "" && strings.ContainsRune("NBRQK", rune(text[0])) {
+		piece, _ := pieceFromLetter(text[0])
+		pieceType = piece.Type
+		text = text[1:]
+	}
+	var promotion PieceType
+	if i := len(text) - 1; pieceType == Pawn && i > 0 && strings.ContainsRune("NBRQ", rune(text[i])) {
+		piece, _ := pieceFromLetter(text[i])
+		promotion = piece.Type
+		text = strings.TrimSuffix(text[:i], "=")
+	}
+	if len(text) < 2 {
+		return Move{}, fmt.Errorf("invalid move %q", san)
+	}
+	to := Position{X: int(text[len(text)-2] - 'a'), Y: int(text[len(text)-1] - '1')}
+	if to.X < 0 || to.X > 7 || to.Y < 0 || to.Y > 7 {
+		return Move{}, fmt.Errorf("invalid move %q", san)
+	}
+
+	// What is left names the file or rank the piece comes from
+	fromFile, fromRank := -1, -1
+	for _, c := range strings.TrimSuffix(text[:len(text)-2], "x") {
+		switch {
+		case c >= 'a' && c <= 'h':
+			fromFile = int(c - 'a')
+		case c >= '1' && c <= '8':
+			fromRank = int(c - '1')
+		default:
+			return Move{}, fmt.Errorf("invalid move %q", san)
+		}
+	}
+
+	found := []Move{}
+	for _, move := range legal {
+		piece := g.Board[move.From.X][move.From.Y]
+		if piece.Type != pieceType || move.To != to || move.Promotion != promotion || g.isCastling(move) ||
+			(fromFile >= 0 && move.From.X != fromFile) || (fromRank >= 0 && move.From.Y != fromRank) {
+			continue
+		}
+		found = append(found, move)
+	}
+	switch len(found) {
+	case 0:
+		return Move{}, fmt.Errorf("illegal move %s", san)
+	case 1:
+		return found[0], nil
+	default:
+		return Move{}, fmt.Errorf("ambiguous move %s", san)
+	}
+}
+
+// sanLine writes a line of moves played from the game's position in SAN.
+func (g *Game) sanLine(moves []Move) []string {
+	position := g.searchCopy()
+	line := []string{}
+	for _, move := range moves {
+		line = append(line, position.SAN(move))
+		position.applyMove(move)
+	}
+	return line
+}
+
+// moveText writes a line of moves played from the game's position in SAN
+// with move numbers, like "12. Nf3 Nc6 13. Bb5" or "12... Nc6 13. Bb5".
+func (g *Game) moveText(moves []Move) string {
+	var text strings.Builder
+	ply := g.ply()
+	for i, san := range g.sanLine(moves) {
+		if i > 0 {
+			text.WriteByte(' ')
+		}
+		switch {
+		case ply%2 == 0:
+			fmt.Fprintf(&text, "%d. ", ply/2+1)
+		case i == 0:
+			fmt.Fprintf(&text, "%d... ", ply/2+1)
+		}
+		text.WriteString(san)
+		ply++
+	}
+	return text.String()
+}
@@ -0,0 +1,114 @@
+package gochess
+
+import "testing"
+
+func TestSAN(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		move string
+		want string
+	}{
+		{"Pawn", StartFEN, "e2e4", "e4"},
+		{"Knight", StartFEN, "g1f3", "Nf3"},
+		{"File", "4k3/8/8/8/8/8/8/1N2KN2 w - - 0 1", "b1d2", "Nbd2"},
+		{"Rank", "4k3/8/8/R7/8/8/8/R3K3 w - - 0 1", "a1a3", "R1a3"},
+		{"File and rank", "4k3/8/8/8/8/Q7/8/Q1Q1K3 w - - 0 1", "a1b2", "Qa1b2"},
+		{"Pawn capture", "rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2", "e4d5", "exd5"},
+		{"En passant", "rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3", "e5f6", "exf6"},
+		{"Piece capture", "r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3", "f3e5", "Nxe5"},
+		{"Kingside castling", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1g1", "O-O"},
+		{"Queenside castling", "r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "e8c8", "O-O-O"},
+		{"Promotion with check", "8/P7/8/8/8/8/8/k6K w - - 0 1", "a7a8q", "a8=Q+"},
+		{"Underpromotion", "8/P7/8/8/8/8/8/k6K w - - 0 1", "a7a8n", "a8=N"},
+		{"Mate", "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", "a1a8", "Ra8#"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := ParseFEN(tt.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			move, err := parseUCIMove(g, tt.move)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := g.SAN(move); got != tt.want {
+				t.Errorf("SAN() = %q, want %q", got, tt.want)
+			}
+			parsed, err := g.ParseSAN(tt.want)
+			if err != nil || parsed != move {
+				t.Errorf("ParseSAN(%q) = %v, %v, want %s", tt.want, uciMove(parsed), err, tt.move)
+			}
+		})
+	}
+}
+
+func TestParseSAN(t *testing.T) {
+	tests := []struct {
+		name    string
+		fen     string
+		san     string
+		want    string
+		wantErr bool
+	}{
+		{"Castling with zeros", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "0-0-0", "e1c1", false},
+		{"Annotations", StartFEN, "e4!?", "e2e4", false},
+		{"Promotion without =", "8/P7/8/8/8/8/8/k6K w - - 0 1", "a8Q", "a7a8q", false},
+		{"More than needed", StartFEN, "Ng1f3", "g1f3", false},
+		{"Missing capture mark", "r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3", "Ne5", "f3e5", false},
+		{"Ambiguous", "4k3/8/8/8/8/8/8/1N2KN2 w - - 0 1", "Nd2", "", true},
+		{"Illegal", StartFEN, "Nf6", "", true},
+		{"Castling through check", "r3k2r/8/8/8/8/8/5r2/R3K2R w KQkq - 0 1", "O-O", "", true},
+		{"Not a move", StartFEN, "z9", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := ParseFEN(tt.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			move, err := g.ParseSAN(tt.san)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSAN(%q) error = %v, wantErr %v", tt.san, err, tt.wantErr)
+			}
+			if !tt.wantErr && uciMove(move) != tt.want {
+				t.Errorf("ParseSAN(%q) = %s, want %s", tt.san, uciMove(move), tt.want)
+			}
+		})
+	}
+}
+
+func TestSANLine(t *testing.T) {
+	g := NewGame("alice", "bob")
+	playMoves(t, g, "e2e4", "e7e5")
+	moves := []Move{}
+	position := g.searchCopy()
+	for _, notation := range []string{"d1h5", "b8c6", "f1c4", "g8f6", "h5f7"} {
+		move, err := parseUCIMove(position, notation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		moves = append(moves, position.applyMove(move))
+	}
+
+	want := []string{"Qh5", "Nc6", "Bc4", "Nf6", "Qxf7#"}
+	got := g.sanLine(moves)
+	for i := range want {
+		if i >= len(got) || got[i] != want[i] {
+			t.Fatalf("sanLine() = %v, want %v", got, want)
+		}
+	}
+	if len(g.History) != 2 {
+		t.Errorf("Expected the game to keep its 2 moves, got %d", len(g.History))
+	}
+	if got, want := g.moveText(moves), "2. Qh5 Nc6 3. Bc4 Nf6 4. Qxf7#"; got != want {
+		t.Errorf("moveText() = %q, want %q", got, want)
+	}
+	playMoves(t, g, "d1h5")
+	if got, want := g.moveText(moves[1:2]), "2... Nc6"; got != want {
+		t.Errorf("moveText() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,135 @@
+package gochess
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Series is a match of several games between the same two players. Each game
+// of the series carries the series as it stood when the game started.
+type Series struct {
+	ID string
+	// BestOf ends the series after that many games, or once a player cannot
+	// be caught. Zero for no limit.
+	BestOf int `json:",omitempty"`
+	// FirstTo ends the series once a player reaches that many points. Zero
+	// for no limit.
+	FirstTo float64 `json:",omitempty"`
+	// Games are the finished games of the series, oldest first
+	Games []string
+	// Scores are the points of each player over the finished games
+	Scores map[string]float64
+}
+
+func NewSeries(bestOf int, firstTo float64) *Series {
+	return &Series{ID: newGameID(), BestOf: bestOf, FirstTo: firstTo, Games: []string{}, Scores: map[string]float64{}}
+}
+
+// After returns the series including the result of a finished game.
+func (s *Series) After(g *Game) *Series {
+	next := &Series{
+		ID:      s.ID,
+		BestOf:  s.BestOf,
+		FirstTo: s.FirstTo,
+		Games:   append(append([]string{}, s.Games...), g.ID),
+		Scores:  map[string]float64{},
+	}
+	for name, score := range s.Scores {
+		next.Scores[name] = score
+	}
+	for _, player := range g.Players {
+		next.Scores[player.Name] += Score(g.State, player.Color)
+	}
+	return next
+}
+
+// Score returns the points of a player over the finished games of the series.
+func (s *Series) Score(name string) float64 {
+	return s.Scores[name]
+}
+
+// IsDecided reports whether the series is over.
+func (s *Series) IsDecided() bool {
+	leader, trailer := 0.0, 0.0
+	for _, score := range s.Scores {
+		if score > leader {
+			leader, trailer = score, leader
+		} else if score > trailer {
+			trailer = score
+		}
+	}
+
+	if s.FirstTo > 0 && leader >= s.FirstTo {
+		return true
+	}
+	if s.BestOf > 0 {
+		remaining := float64(s.BestOf - len(s.Games))
+		return remaining <= 0 || leader > trailer+remaining
+	}
+	return false
+}
+
+func (s *Series) String() string {
+	switch {
+	case s.BestOf > 0:
+		return fmt.Sprintf("best of %d", s.BestOf)
+	case s.FirstTo > 0:
+		return fmt.Sprintf("first to %g", s.FirstTo)
+	default:
+		return "match"
+	}
+}
+
+// Rematch sets up the next game between the players of a finished game, with
+// colours swapped and the same settings, including the computer opponent and
+// the correspondence time control. The games are played as a series, which
+// is started by the first rematch if the game was not part of one.
+func Rematch(g *Game) (*Game, error) {
+	if !g.IsOver() {
+		return nil, errors.New("Game is not over, got state: " + string(g.State))
+	}
+	if g.Rematch != "" {
+		return nil, errors.New("the game has already been rematched")
+	}
+
+	series := g.Series
+	if series == nil {
+		series = NewSeries(0, 0)
+	}
+	series = series.After(g)
+	if series.IsDecided() {
+		return nil, errors.New("the series is over")
+	}
+
+	next := NewGame(g.Players[1].Name, g.Players[0].Name)
+	next.Casual = g.Casual
+	next.SpectatorDelay = g.SpectatorDelay
+	next.Series = series
+	if g.Clock != nil {
+		next.SetClock(NewClock(g.Clock.Initial, g.Clock.Increment))
+	}
+	if g.Correspondence != nil {
+		next.SetCorrespondence(NewCorrespondence(g.Correspondence.DaysPerMove, g.Correspondence.VacationDays))
+	}
+	if g.Computer != nil {
+		computer := *g.Computer
+		computer.Color = White
+		if g.Computer.Color == White {
+			computer.Color = Black
+		}
+		next.Computer = &computer
+	}
+	return next, nil
+}
+
+// DecidedSeries returns the series including the game, if the game is over
+// and decided the series.
+func (g *Game) DecidedSeries() *Series {
+	if g.Series == nil || !g.IsOver() {
+		return nil
+	}
+	if series := g.Series.After(g); series.IsDecided() {
+		return series
+	}
+	return nil
+}
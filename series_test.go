@@ -0,0 +1,102 @@
+package gochess
+
+import (
+	"testing"
+	"time"
+)
+
+func finishedGame(white, black string, state GameState) *Game {
+	g := NewGame(white, black)
+	g.State = state
+	return g
+}
+
+func TestSeriesIsDecided(t *testing.T) {
+	tests := []struct {
+		name    string
+		series  *Series
+		results []GameState
+		decided bool
+	}{
+		{"best of 3 after one win", NewSeries(3, 0), []GameState{WhiteWon}, false},
+		{"best of 3 after two wins", NewSeries(3, 0), []GameState{WhiteWon, BlackWon}, true},
+		{"best of 3 after a win and a draw", NewSeries(3, 0), []GameState{WhiteWon, Draw}, false},
+		{"best of 2 all drawn", NewSeries(2, 0), []GameState{Draw, Draw}, true},
+		{"first to 1.5 on a draw", NewSeries(0, 1.5), []GameState{Draw}, false},
+		{"first to 1.5 after a draw and a win", NewSeries(0, 1.5), []GameState{Draw, WhiteWon}, true},
+		{"open match", NewSeries(0, 0), []GameState{WhiteWon, WhiteWon, WhiteWon}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			series := test.series
+			for i, state := range test.results {
+				// Colours alternate, so White wins go to alice and bob in turn
+				white, black := "alice", "bob"
+				if i%2 == 1 {
+					white, black = black, white
+				}
+				series = series.After(finishedGame(white, black, state))
+			}
+			if series.IsDecided() != test.decided {
+				t.Errorf("Expected decided to be %v, but got %v with %v", test.decided, series.IsDecided(), series.Scores)
+			}
+			if len(series.Games) != len(test.results) {
+				t.Errorf("Expected %d games, but got %d", len(test.results), len(series.Games))
+			}
+		})
+	}
+}
+
+func TestRematch(t *testing.T) {
+	g := NewGame("alice", "bob")
+	g.Casual = true
+	g.SetClock(NewClock(5*time.Minute, 3*time.Second))
+	if _, err := Rematch(g); err == nil {
+		t.Errorf("Expected no rematch before the game is over")
+	}
+
+	g.Resign(Black)
+	next, err := Rematch(g)
+	if err != nil {
+		t.Fatalf("Expected rematch, but got %v", err)
+	}
+	if next.Players[0].Name != "bob" || next.Players[1].Name != "alice" {
+		t.Errorf("Expected colours to be swapped, but got %v", next.Players)
+	}
+	if !next.Casual || next.Clock == nil || next.Clock.Initial != g.Clock.Initial {
+		t.Errorf("Expected the settings to carry over, but got %+v", next)
+	}
+	if next.Series == nil || next.Series.Score("alice") != 1 || next.Series.Games[0] != g.ID {
+		t.Errorf("Expected a series with Alice leading, but got %+v", next.Series)
+	}
+
+	g.Series = NewSeries(1, 0)
+	if _, err := Rematch(g); err == nil {
+		t.Errorf("Expected no rematch once the series is decided")
+	}
+	if g.DecidedSeries() == nil || g.DecidedSeries().Score("alice") != 1 {
+		t.Errorf("Expected the game to decide the series, but got %+v", g.DecidedSeries())
+	}
+
+	g.Series, g.Rematch = nil, next.ID
+	if _, err := Rematch(g); err == nil {
+		t.Errorf("Expected no second rematch of the game")
+	}
+
+	// The computer and the correspondence time control carry over too
+	g = NewGame("alice", computerName)
+	g.Computer = &ComputerOpponent{Color: Black, Limits: SearchLimits{Depth: 3}}
+	g.SetCorrespondence(NewCorrespondence(3, 5))
+	g.Resign(White)
+	next, err = Rematch(g)
+	if err != nil {
+		t.Fatalf("Expected rematch, but got %v", err)
+	}
+	if next.Computer == nil || next.Computer.Color != White || next.Computer.Limits.Depth != 3 || next.Players[0].Name != computerName {
+		t.Errorf("Expected the computer to play White, but got %+v", next.Computer)
+	}
+	if next.Correspondence == nil || next.Correspondence.DaysPerMove != 3 || next.Correspondence.VacationLeft[White] != 5 {
+		t.Errorf("Expected the correspondence time control to carry over, but got %+v", next.Correspondence)
+	}
+}
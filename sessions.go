@@ -0,0 +1,88 @@
+package gochess
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	sessionCookieName = "gochess_session"
+	sessionDuration   = 30 * 24 * time.Hour
+)
+
+type session struct {
+	username string
+	expires  time.Time
+}
+
+// SessionManager maps random session tokens, handed out as cookies, to the
+// logged in user.
+type SessionManager struct {
+	mu       sync.Mutex
+	sessions map[string]session
+}
+
+func NewSessionManager() *SessionManager {
+	return &SessionManager{sessions: map[string]session{}}
+}
+
+// Start creates a session for the user and sets its cookie on the response.
+func (m *SessionManager) Start(w http.ResponseWriter, r *http.Request, username string) {
+	token := newGameID() + newGameID()
+	expires := timeNow().Add(sessionDuration)
+
+	m.mu.Lock()
+	m.sessions[token] = session{username: username, expires: expires}
+	m.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// End removes the session of the request and clears its cookie.
+func (m *SessionManager) End(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		m.mu.Lock()
+		delete(m.sessions, cookie.Value)
+		m.mu.Unlock()
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Username returns the user logged in for the request, or "" if there is none.
+func (m *SessionManager) Username(r *http.Request) string {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return ""
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[cookie.Value]
+	if !ok {
+		return ""
+	}
+	if timeNow().After(s.expires) {
+		delete(m.sessions, cookie.Value)
+		return ""
+	}
+	return s.username
+}
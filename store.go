@@ -0,0 +1,314 @@
+package gochess
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var ErrGameNotFound = errors.New("game not found")
+
+// GameStore persists games so they survive a restart of the server. Next to
+// a snapshot of each game it keeps the log of events that led to it.
+type GameStore interface {
+	Save(g *Game) error
+	Load(id string) (*Game, error)
+	List() ([]*Game, error)
+	Delete(id string) error
+
+	EventLog
+}
+
+func newGameID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func encodeGame(g *Game) ([]byte, error) {
+	if g.ID == "" {
+		return nil, errors.New("cannot store a game without an ID")
+	}
+	return json.MarshalIndent(g, "", "  ")
+}
+
+// decodeGame restores a game and re-attaches the listeners that belong to it.
+func decodeGame(data []byte) (*Game, error) {
+	var g Game
+	if err := json.Unmarshal(data, &g); err != nil {
+		return nil, err
+	}
+	if g.Clock != nil {
+		g.SetClock(g.Clock)
+	}
+	if g.Correspondence != nil {
+		g.SetCorrespondence(g.Correspondence)
+	}
+	return &g, nil
+}
+
+// MemoryGameStore keeps games in memory, mostly useful for tests and
+// throwaway servers.
+type MemoryGameStore struct {
+	mu     sync.Mutex
+	games  map[string][]byte
+	events map[string][]GameEvent
+}
+
+func NewMemoryGameStore() *MemoryGameStore {
+	return &MemoryGameStore{games: map[string][]byte{}, events: map[string][]GameEvent{}}
+}
+
+func (s *MemoryGameStore) Save(g *Game) error {
+	data, err := encodeGame(g)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.games[g.ID] = data
+	return nil
+}
+
+func (s *MemoryGameStore) Load(id string) (*Game, error) {
+	s.mu.Lock()
+	data, ok := s.games[id]
+	s.mu.Unlock()
+
+	if !ok {
+		return nil, ErrGameNotFound
+	}
+	return decodeGame(data)
+}
+
+func (s *MemoryGameStore) List() ([]*Game, error) {
+	s.mu.Lock()
+	ids := make([]string, 0, len(s.games))
+	for id := range s.games {
+		ids = append(ids, id)
+	}
+	s.mu.Unlock()
+	sort.Strings(ids)
+
+	games := []*Game{}
+	for _, id := range ids {
+		g, err := s.Load(id)
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, g)
+	}
+	return games, nil
+}
+
+func (s *MemoryGameStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.games[id]; !ok {
+		return ErrGameNotFound
+	}
+	delete(s.games, id)
+	delete(s.events, id)
+	return nil
+}
+
+func (s *MemoryGameStore) AppendEvents(gameID string, events ...GameEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events[gameID] = append(s.events[gameID], events...)
+	return nil
+}
+
+func (s *MemoryGameStore) Events(gameID string) ([]GameEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events, ok := s.events[gameID]
+	if !ok {
+		return nil, ErrGameNotFound
+	}
+	return append([]GameEvent{}, events...), nil
+}
+
+// FileGameStore keeps one JSON file per game in a directory, next to a JSON
+// lines file with the events of the game.
+type FileGameStore struct {
+	dir string
+}
+
+func NewFileGameStore(dir string) (*FileGameStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileGameStore{dir: dir}, nil
+}
+
+func (s *FileGameStore) path(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return "", errors.New("invalid game ID: " + id)
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+func (s *FileGameStore) Save(g *Game) error {
+	data, err := encodeGame(g)
+	if err != nil {
+		return err
+	}
+	path, err := s.path(g.ID)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash never leaves a half written game
+	tmp, err := os.CreateTemp(s.dir, g.ID+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *FileGameStore) Load(id string) (*Game, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrGameNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeGame(data)
+}
+
+func (s *FileGameStore) List() ([]*Game, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	games := []*Game{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".json" {
+			continue
+		}
+		g, err := s.Load(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, g)
+	}
+	return games, nil
+}
+
+func (s *FileGameStore) Delete(id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrGameNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	eventsPath, err := s.eventsPath(id)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(eventsPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *FileGameStore) eventsPath(gameID string) (string, error) {
+	path, err := s.path(gameID)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(path, ".json") + ".events.jsonl", nil
+}
+
+func (s *FileGameStore) AppendEvents(gameID string, events ...GameEvent) error {
+	path, err := s.eventsPath(gameID)
+	if err != nil {
+		return err
+	}
+
+	// Events are only ever appended, one JSON document per line
+	var data []byte
+	for _, event := range events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *FileGameStore) Events(gameID string) ([]GameEvent, error) {
+	path, err := s.eventsPath(gameID)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrGameNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	events := []GameEvent{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		var event GameEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
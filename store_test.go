@@ -0,0 +1,101 @@
+package gochess
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGameStores(t *testing.T) {
+	fileStore, err := NewFileGameStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileGameStore() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		store GameStore
+	}{
+		{name: "memory", store: NewMemoryGameStore()},
+		{name: "file", store: fileStore},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGame("Alice", "Bob")
+			g.SetClock(NewClock(5*time.Minute, 2*time.Second))
+			playMoves(t, g, "e2e4", "e7e5")
+
+			if err := tt.store.Save(g); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+
+			loaded, err := tt.store.Load(g.ID)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if loaded.Players != g.Players {
+				t.Errorf("Expected players %v, but got %v", g.Players, loaded.Players)
+			}
+			if len(loaded.History) != 2 || loaded.History[1] != g.History[1] {
+				t.Errorf("Expected history %v, but got %v", g.History, loaded.History)
+			}
+			if piece := loaded.Board[4][3]; piece == nil || *piece != (Piece{Type: Pawn, Color: White}) {
+				t.Errorf("Expected a white pawn at e4, but got %v", piece)
+			}
+			if loaded.Clock == nil || loaded.Clock.White != g.Clock.White || loaded.Clock.Increment != g.Clock.Increment {
+				t.Errorf("Expected clock %+v, but got %+v", g.Clock, loaded.Clock)
+			}
+
+			// The loaded game keeps playing, including its clock
+			playMoves(t, loaded, "g1f3")
+			if loaded.Clock.LastMoveAt.Equal(g.Clock.LastMoveAt) {
+				t.Errorf("Expected the clock of the loaded game to run")
+			}
+
+			games, err := tt.store.List()
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if len(games) != 1 || games[0].ID != g.ID {
+				t.Errorf("Expected to list game %v, but got %v", g.ID, games)
+			}
+
+			if err := tt.store.AppendEvents(g.ID, CreatedEvent(g)); err != nil {
+				t.Fatalf("AppendEvents() error = %v", err)
+			}
+			if err := tt.store.AppendEvents(g.ID, MovedEvent(loaded), ClockTickEvent(loaded)); err != nil {
+				t.Fatalf("AppendEvents() error = %v", err)
+			}
+			events, err := tt.store.Events(g.ID)
+			if err != nil {
+				t.Fatalf("Events() error = %v", err)
+			}
+			if len(events) != 3 || events[0].Type != EventCreated || events[1].Type != EventMoved || events[2].Type != EventClockTick {
+				t.Errorf("Expected created, moved and clock tick events, but got %v", events)
+			}
+			if events[1].To != (Position{X: 5, Y: 2}) {
+				t.Errorf("Expected move to f3, but got %v", events[1].To)
+			}
+
+			if err := tt.store.Delete(g.ID); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if _, err := tt.store.Load(g.ID); err != ErrGameNotFound {
+				t.Errorf("Expected %v after delete, but got %v", ErrGameNotFound, err)
+			}
+			if _, err := tt.store.Events(g.ID); err != ErrGameNotFound {
+				t.Errorf("Expected %v for events after delete, but got %v", ErrGameNotFound, err)
+			}
+		})
+	}
+}
+
+func TestFileGameStoreRejectsPaths(t *testing.T) {
+	s, err := NewFileGameStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileGameStore() error = %v", err)
+	}
+	if _, err := s.Load("../secret"); err == nil || err == ErrGameNotFound {
+		t.Errorf("Expected an invalid ID error, but got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package gochess
+
+// WDL is the result of a position with perfect play for the player on turn,
+// telling wins and losses the fifty-move rule turns into draws apart.
+type WDL int
+
+const (
+	WDLLoss        WDL = -2
+	WDLBlessedLoss WDL = -1
+	WDLDraw        WDL = 0
+	WDLCursedWin   WDL = 1
+	WDLWin         WDL = 2
+)
+
+// tablebaseWin is the score of a position the tablebase says is won, less
+// the plies it takes to reach it. It is below the scores of mates found by
+// the search.
+const tablebaseWin = mateScore - 2000
+
+// Tablebase knows the result of every position with few pieces, like the
+// Syzygy tables. There is no reader for Syzygy files yet.
+type Tablebase interface {
+	// MaxPieces is the most pieces, kings included, it knows positions of
+	MaxPieces() int
+	// ProbeWDL returns the result of the position
+	ProbeWDL(g *Game) (WDL, error)
+	// ProbeDTZ returns the plies to the next capture or pawn move with
+	// perfect play, negative when the player on turn loses
+	ProbeDTZ(g *Game) (int, error)
+}
+
+// inTablebase reports whether the tablebase knows the position. Positions
+// where castling is still possible are never in it.
+func inTablebase(tb Tablebase, g *Game) bool {
+	if g.Castling != 0 {
+		return false
+	}
+	pieces := 0
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 8; y++ {
+			if g.Board[x][y] != nil {
+				pieces++
+			}
+		}
+	}
+	return pieces <= tb.MaxPieces()
+}
+
+// wdlScore scores a result for the search, won positions reached sooner
+// scoring higher. Results the fifty-move rule draws score as draws.
+func wdlScore(wdl WDL, ply int) int {
+	switch wdl {
+	case WDLWin:
+		return tablebaseWin - ply
+	case WDLLoss:
+		return -tablebaseWin + ply
+	}
+	return 0
+}
+
+// probeRoot picks the move of a position in the tablebase that keeps the
+// best result, reaching the next capture or pawn move soonest when winning
+// and latest when losing. It returns false if a probe fails.
+func (e *Engine) probeRoot(g *Game) (SearchResult, bool) {
+	if !inTablebase(e.Tablebase, g) {
+		return SearchResult{}, false
+	}
+
+	position := g.searchCopy()
+	best := SearchResult{}
+	bestWDL, bestDistance := WDLLoss-1, 0
+	for _, move := range position.LegalMoves() {
+		played := position.applyMove(move)
+		wdl, err := e.Tablebase.ProbeWDL(position)
+		distance := 0
+		if err == nil && played.PieceTaken == nil && position.Board[move.To.X][move.To.Y].Type != Pawn {
+			distance, err = e.Tablebase.ProbeDTZ(position)
+			distance = abs(distance)
+		}
+		position.takeBack()
+		if err != nil {
+			return SearchResult{}, false
+		}
+
+		// The result after the move is the other player's
+		wdl = -wdl
+		better := wdl > bestWDL ||
+			(wdl == bestWDL && wdl > 0 && distance < bestDistance) ||
+			(wdl == bestWDL && wdl < 0 && distance > bestDistance)
+		if better {
+			bestWDL, bestDistance = wdl, distance
+			best = SearchResult{Move: move, Score: wdlScore(wdl, distance+1), PV: []Move{move}}
+		}
+	}
+	return best, bestWDL >= WDLLoss
+}
@@ -0,0 +1,108 @@
+package gochess
+
+import (
+	"errors"
+	"testing"
+)
+
+// materialTablebase is a stand-in for real tables: the side with more
+// material wins, taking ten plies to the next capture or pawn move.
+type materialTablebase struct {
+	maxPieces int
+	probes    int
+}
+
+func (tb *materialTablebase) MaxPieces() int { return tb.maxPieces }
+
+func (tb *materialTablebase) ProbeWDL(g *Game) (WDL, error) {
+	tb.probes++
+	if !inTablebase(tb, g) {
+		return 0, errors.New("no table for the position")
+	}
+	balance := 0
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 8; y++ {
+			if piece := g.Board[x][y]; piece != nil && piece.Type != King {
+				if piece.Color == g.GetCurrentPlayerColor() {
+					balance += pieceValues[piece.Type]
+				} else {
+					balance -= pieceValues[piece.Type]
+				}
+			}
+		}
+	}
+	switch {
+	case balance > 0:
+		return WDLWin, nil
+	case balance < 0:
+		return WDLLoss, nil
+	}
+	return WDLDraw, nil
+}
+
+func (tb *materialTablebase) ProbeDTZ(g *Game) (int, error) {
+	wdl, err := tb.ProbeWDL(g)
+	return 5 * int(wdl), err
+}
+
+func TestTablebaseRoot(t *testing.T) {
+	tb := &materialTablebase{maxPieces: 4}
+	e := &Engine{Evaluate: Evaluate, Tablebase: tb}
+
+	// Taking the rook wins straight away
+	g, err := ParseFEN("3r3k/8/8/8/8/8/8/K2Q4 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := e.Search(g, SearchLimits{Depth: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uciMove(result.Move) != "d1d8" || result.Score != tablebaseWin-1 || result.Depth != 0 {
+		t.Errorf("Expected Qxd8 from the tablebase, got %s scoring %d at depth %d", uciMove(result.Move), result.Score, result.Depth)
+	}
+
+	// Every move loses, and none of them takes a piece
+	g, err = ParseFEN("3r3k/8/8/8/8/8/8/K5Q1 b - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = e.Search(g, SearchLimits{Depth: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Score != -tablebaseWin+11 {
+		t.Errorf("Expected a loss 11 plies from the next capture, got %s scoring %d", uciMove(result.Move), result.Score)
+	}
+}
+
+func TestTablebaseInSearch(t *testing.T) {
+	// With the pawn the position has too many pieces for the tablebase, until
+	// the queen takes the rook
+	tb := &materialTablebase{maxPieces: 4}
+	e := &Engine{Evaluate: Evaluate, Tablebase: tb}
+	g, err := ParseFEN("3r3k/8/8/8/8/8/7P/K2Q4 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := e.Search(g, SearchLimits{Depth: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uciMove(result.Move) != "d1d8" || result.Score != tablebaseWin-1 {
+		t.Errorf("Expected Qxd8 scored by the tablebase, got %s scoring %d", uciMove(result.Move), result.Score)
+	}
+	if tb.probes == 0 {
+		t.Error("Expected the search to probe the tablebase")
+	}
+
+	// Castling rights keep positions out of the tablebase
+	g, err = ParseFEN("4k3/8/8/8/8/8/8/R3K3 w Q - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inTablebase(tb, g) {
+		t.Error("Expected a position with castling rights not to be in the tablebase")
+	}
+}
@@ -0,0 +1,66 @@
+// Command fakeengine is a tiny UCI engine for the tests of the UCI client.
+// It plays a fixed opening for each side, in any position, and searches until
+// stopped on go infinite.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var replies = [2][]string{
+	{"e2e4", "g1f3", "f1c4", "d2d3", "b1c3"},
+	{"e7e5", "b8c6", "g8f6", "d7d6", "f8e7"},
+}
+
+func main() {
+	scanner := bufio.NewScanner(os.Stdin)
+	plies := 0
+	pending := ""
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "uci":
+			fmt.Println("id name Fake Engine")
+			fmt.Println("id author gochess tests")
+			fmt.Println("option name Skill Level type spin default 10 min 0 max 20")
+			fmt.Println("option name Style type combo default Normal var Solid var Normal var Risky")
+			fmt.Println("option name Clear Hash type button")
+			fmt.Println("uciok")
+		case "setoption":
+			fmt.Println("info string " + strings.Join(fields, " "))
+		case "isready":
+			fmt.Println("readyok")
+		case "position":
+			plies = 0
+			for i, field := range fields {
+				if field == "moves" {
+					plies = len(fields) - i - 1
+				}
+			}
+		case "go":
+			move := replies[plies%2][(plies/2)%len(replies[0])]
+			fmt.Println("info string " + strings.Join(fields, " "))
+			fmt.Println("info depth 1 score cp 20 nodes 20 time 1 pv " + move)
+			if fields[len(fields)-1] == "infinite" {
+				pending = move
+				continue
+			}
+			fmt.Println("info depth 2 seldepth 4 multipv 1 score mate 3 nodes 250 nps 125000 time 2 pv " + move)
+			fmt.Println("bestmove " + move)
+		case "stop":
+			if pending != "" {
+				fmt.Println("bestmove " + pending)
+				pending = ""
+			}
+		case "quit":
+			return
+		}
+	}
+}
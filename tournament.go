@@ -0,0 +1,714 @@
+package gochess
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var ErrTournamentNotFound = errors.New("tournament not found")
+
+type TournamentFormat string
+
+const (
+	RoundRobin TournamentFormat = "RoundRobin"
+	Swiss      TournamentFormat = "Swiss"
+)
+
+// TieBreak separates players on the same number of points.
+type TieBreak string
+
+const (
+	Buchholz        TieBreak = "Buchholz"
+	SonnebornBerger TieBreak = "SonnebornBerger"
+	DirectEncounter TieBreak = "DirectEncounter"
+)
+
+// Pairing is a game of a tournament round. A player without an opponent has
+// a bye, which scores a full point.
+type Pairing struct {
+	White  string
+	Black  string
+	GameID string
+	// Result is the final state of the game, empty while it is being played
+	Result GameState
+}
+
+func (p *Pairing) IsBye() bool {
+	return p.Black == ""
+}
+
+func (p *Pairing) finished() bool {
+	return p.IsBye() || p.Result != ""
+}
+
+// score returns the points of the named player in the pairing.
+func (p *Pairing) score(name string) float64 {
+	if p.IsBye() {
+		return 1
+	}
+	color := White
+	if name == p.Black {
+		color = Black
+	}
+	return Score(p.Result, color)
+}
+
+func (p *Pairing) opponent(name string) string {
+	if name == p.White {
+		return p.Black
+	}
+	return p.White
+}
+
+type Round struct {
+	Pairings []*Pairing
+}
+
+// Standing is the place of a player in a tournament.
+type Standing struct {
+	Player          string
+	Points          float64
+	Buchholz        float64
+	SonnebornBerger float64
+}
+
+// Tournament is a round-robin or Swiss tournament. Players register before
+// the first round, and each round is paired once the previous one is over.
+type Tournament struct {
+	ID          string
+	Name        string
+	Organizer   string
+	Format      TournamentFormat
+	TimeControl TimeControl
+	Rated       bool
+	// Players are ordered by their pairing number, highest rated first
+	Players []string
+	Ratings map[string]float64
+	// TotalRounds is the number of rounds of a Swiss tournament. A round
+	// robin plays one round less than there are players.
+	TotalRounds int
+	Rounds      []*Round
+	TieBreaks   []TieBreak
+}
+
+func NewTournament(name, organizer string, format TournamentFormat, totalRounds int, tc TimeControl, rated bool) *Tournament {
+	tieBreaks := []TieBreak{Buchholz, SonnebornBerger, DirectEncounter}
+	if format == RoundRobin {
+		// Everyone plays everyone, so Buchholz only tells who played the winner
+		tieBreaks = []TieBreak{DirectEncounter, SonnebornBerger}
+	}
+	return &Tournament{
+		ID:          newGameID(),
+		Name:        name,
+		Organizer:   organizer,
+		Format:      format,
+		TimeControl: tc,
+		Rated:       rated,
+		Ratings:     map[string]float64{},
+		TotalRounds: totalRounds,
+		TieBreaks:   tieBreaks,
+	}
+}
+
+// Register adds a player to the tournament before it starts.
+func (t *Tournament) Register(name string, rating float64) error {
+	if len(t.Rounds) > 0 {
+		return errors.New("the tournament has already started")
+	}
+	if _, ok := t.Ratings[name]; ok {
+		return errors.New(name + " is already registered")
+	}
+	if t.Rated && strings.HasPrefix(name, guestPrefix) {
+		return errors.New("guests can only play casual tournaments")
+	}
+
+	t.Ratings[name] = rating
+	t.Players = append(t.Players, name)
+	sort.SliceStable(t.Players, func(i, j int) bool {
+		return t.Ratings[t.Players[i]] > t.Ratings[t.Players[j]]
+	})
+	return nil
+}
+
+// NumRounds returns the number of rounds the tournament is played over.
+func (t *Tournament) NumRounds() int {
+	if t.Format == RoundRobin {
+		return len(t.Players) + len(t.Players)%2 - 1
+	}
+	return t.TotalRounds
+}
+
+// IsOver reports whether every round has been played.
+func (t *Tournament) IsOver() bool {
+	return len(t.Rounds) > 0 && len(t.Rounds) == t.NumRounds() && t.roundFinished()
+}
+
+func (t *Tournament) roundFinished() bool {
+	if len(t.Rounds) == 0 {
+		return true
+	}
+	for _, p := range t.Rounds[len(t.Rounds)-1].Pairings {
+		if !p.finished() {
+			return false
+		}
+	}
+	return true
+}
+
+// PairNextRound adds the pairings of the next round. The games are created by
+// the caller, who sets the GameID of each pairing.
+func (t *Tournament) PairNextRound() (*Round, error) {
+	if len(t.Players) < 2 {
+		return nil, errors.New("a tournament needs at least two players")
+	}
+	if !t.roundFinished() {
+		return nil, errors.New("the current round is not over")
+	}
+	if len(t.Rounds) >= t.NumRounds() {
+		return nil, errors.New("the tournament is over")
+	}
+
+	var round *Round
+	var err error
+	if t.Format == RoundRobin {
+		round = bergerRound(t.Players, len(t.Rounds))
+	} else {
+		round, err = t.swissRound()
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.Rounds = append(t.Rounds, round)
+	return round, nil
+}
+
+// RecordGame records the result of a finished tournament game. It reports
+// whether the game belongs to the tournament.
+func (t *Tournament) RecordGame(g *Game) bool {
+	for _, round := range t.Rounds {
+		for _, p := range round.Pairings {
+			if p.GameID != "" && p.GameID == g.ID {
+				p.Result = g.State
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bergerRound returns the pairings of a round robin round from the Berger
+// tables. With an odd number of players the last seat is a bye.
+func bergerRound(players []string, round int) *Round {
+	seats := append([]string{}, players...)
+	if len(seats)%2 == 1 {
+		seats = append(seats, "")
+	}
+	n := len(seats)
+
+	// The last seat stays put while the others rotate by half a table each round
+	rotated := make([]string, n-1)
+	for i := range rotated {
+		rotated[i] = seats[(round*(n/2)+i)%(n-1)]
+	}
+
+	pairings := []*Pairing{}
+	first, last := rotated[0], seats[n-1]
+	if round%2 == 1 {
+		first, last = last, first
+	}
+	pairings = append(pairings, newPairing(first, last))
+	for i := 1; i < n/2; i++ {
+		pairings = append(pairings, newPairing(rotated[i], rotated[n-1-i]))
+	}
+	return &Round{Pairings: pairings}
+}
+
+// newPairing pairs two players, turning a game against the empty seat into a bye.
+func newPairing(white, black string) *Pairing {
+	if white == "" {
+		white, black = black, ""
+	}
+	return &Pairing{White: white, Black: black}
+}
+
+// playerHistory is what the Swiss pairing needs to know about a player.
+type playerHistory struct {
+	points    float64
+	opponents map[string]bool
+	// colors is the number of games with White minus the games with Black
+	colors    int
+	lastColor PieceColor
+	hadBye    bool
+}
+
+func (t *Tournament) histories() map[string]*playerHistory {
+	histories := map[string]*playerHistory{}
+	for _, name := range t.Players {
+		histories[name] = &playerHistory{opponents: map[string]bool{}}
+	}
+	for _, round := range t.Rounds {
+		for _, p := range round.Pairings {
+			white := histories[p.White]
+			white.points += p.score(p.White)
+			if p.IsBye() {
+				white.hadBye = true
+				continue
+			}
+			black := histories[p.Black]
+			black.points += p.score(p.Black)
+			white.opponents[p.Black] = true
+			black.opponents[p.White] = true
+			white.colors++
+			black.colors--
+			white.lastColor = White
+			black.lastColor = Black
+		}
+	}
+	return histories
+}
+
+// swissRound pairs the players with the Dutch system: players are ranked by
+// points and pairing number, and the top half of each score group plays the
+// bottom half. Players who cannot be paired in their score group float down
+// to the next one. Players never meet twice.
+func (t *Tournament) swissRound() (*Round, error) {
+	histories := t.histories()
+	ranked := append([]string{}, t.Players...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return histories[ranked[i]].points > histories[ranked[j]].points
+	})
+
+	// With an odd number of players, the lowest ranked player who has not
+	// had a bye yet gets one, as long as the others can still be paired.
+	// Players who have had a bye get another one as a last resort.
+	byes := []int{-1}
+	if len(ranked)%2 == 1 {
+		byes = []int{}
+		for _, repeat := range []bool{false, true} {
+			for i := len(ranked) - 1; i >= 0; i-- {
+				if histories[ranked[i]].hadBye == repeat {
+					byes = append(byes, i)
+				}
+			}
+		}
+	}
+
+	for _, bye := range byes {
+		rest := ranked
+		if bye >= 0 {
+			rest = append(append([]string{}, ranked[:bye]...), ranked[bye+1:]...)
+		}
+		pairs, ok := pairDutch(rest, histories)
+		if !ok {
+			continue
+		}
+		round := &Round{}
+		for _, pair := range pairs {
+			round.Pairings = append(round.Pairings, balanceColors(pair[0], pair[1], histories))
+		}
+		if bye >= 0 {
+			// The bye is listed last, after the boards
+			round.Pairings = append(round.Pairings, newPairing(ranked[bye], ""))
+		}
+		return round, nil
+	}
+	return nil, errors.New("no pairing left without players meeting twice")
+}
+
+// pairDutch pairs the ranked players, backtracking when a choice leaves the
+// rest of the players without a valid pairing.
+func pairDutch(ranked []string, histories map[string]*playerHistory) ([][2]string, bool) {
+	if len(ranked) == 0 {
+		return nil, true
+	}
+
+	top := ranked[0]
+	group := 1
+	for group < len(ranked) && histories[ranked[group]].points == histories[top].points {
+		group++
+	}
+
+	// The preferred opponent is the first of the bottom half of the score
+	// group, then the rest of the group, then the lower groups
+	half := group / 2
+	if half == 0 {
+		half = 1
+	}
+	candidates := []int{}
+	for i := half; i < len(ranked); i++ {
+		candidates = append(candidates, i)
+	}
+	for i := 1; i < half; i++ {
+		candidates = append(candidates, i)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i] < group && candidates[j] >= group
+	})
+
+	for _, i := range candidates {
+		opponent := ranked[i]
+		if histories[top].opponents[opponent] {
+			continue
+		}
+		rest := append(append([]string{}, ranked[1:i]...), ranked[i+1:]...)
+		if pairs, ok := pairDutch(rest, histories); ok {
+			return append([][2]string{{top, opponent}}, pairs...), true
+		}
+	}
+	return nil, false
+}
+
+// balanceColors gives White to the player who has had it less often, or who
+// had Black last, so colours even out over the tournament.
+func balanceColors(a, b string, histories map[string]*playerHistory) *Pairing {
+	ha, hb := histories[a], histories[b]
+	switch {
+	case ha.colors != hb.colors:
+		if ha.colors > hb.colors {
+			a, b = b, a
+		}
+	case ha.lastColor != hb.lastColor:
+		if ha.lastColor == White || hb.lastColor == Black {
+			a, b = b, a
+		}
+	}
+	return &Pairing{White: a, Black: b}
+}
+
+// Standings ranks the players by points and then by the tie-breaks of the
+// tournament.
+func (t *Tournament) Standings() []Standing {
+	histories := t.histories()
+	standings := map[string]*Standing{}
+	for _, name := range t.Players {
+		standings[name] = &Standing{Player: name, Points: histories[name].points}
+	}
+	for _, round := range t.Rounds {
+		for _, p := range round.Pairings {
+			if p.IsBye() || p.Result == "" {
+				continue
+			}
+			for _, name := range []string{p.White, p.Black} {
+				opponent := p.opponent(name)
+				standings[name].Buchholz += histories[opponent].points
+				standings[name].SonnebornBerger += p.score(name) * histories[opponent].points
+			}
+		}
+	}
+
+	ranked := []Standing{}
+	for _, name := range t.Players {
+		ranked = append(ranked, *standings[name])
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		for _, tieBreak := range t.TieBreaks {
+			switch tieBreak {
+			case Buchholz:
+				if a.Buchholz != b.Buchholz {
+					return a.Buchholz > b.Buchholz
+				}
+			case SonnebornBerger:
+				if a.SonnebornBerger != b.SonnebornBerger {
+					return a.SonnebornBerger > b.SonnebornBerger
+				}
+			case DirectEncounter:
+				if score := t.directEncounter(a.Player, b.Player); score != 0 {
+					return score > 0
+				}
+			}
+		}
+		return false
+	})
+	return ranked
+}
+
+// directEncounter returns the points of a minus the points of b in the games
+// they played against each other.
+func (t *Tournament) directEncounter(a, b string) float64 {
+	score := 0.0
+	for _, round := range t.Rounds {
+		for _, p := range round.Pairings {
+			if p.Result != "" && p.opponent(a) == b && (p.White == a || p.Black == a) {
+				score += p.score(a) - p.score(b)
+			}
+		}
+	}
+	return score
+}
+
+// TournamentStore persists tournaments.
+type TournamentStore interface {
+	SaveTournament(t *Tournament) error
+	AllTournaments() ([]*Tournament, error)
+}
+
+// TournamentDirector runs the tournaments of the server and records the
+// results of their games as they finish. Tournaments are saved to the store
+// after every change.
+type TournamentDirector struct {
+	NopGameListener
+
+	mu          sync.Mutex
+	store       TournamentStore
+	tournaments map[string]*Tournament
+	// While rounds are being started, results of games that are not known
+	// yet are kept in case they belong to the new round
+	starting  int
+	unclaimed map[string]GameState
+}
+
+func NewTournamentDirector(store TournamentStore) *TournamentDirector {
+	return &TournamentDirector{store: store, tournaments: map[string]*Tournament{}, unclaimed: map[string]GameState{}}
+}
+
+// Load reads the tournaments saved in the store.
+func (d *TournamentDirector) Load() error {
+	all, err := d.store.AllTournaments()
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, t := range all {
+		d.tournaments[t.ID] = t
+	}
+	return nil
+}
+
+// Create adds a new tournament that players can register for.
+func (d *TournamentDirector) Create(t *Tournament) error {
+	if t.Format != RoundRobin && t.Format != Swiss {
+		return errors.New("unknown tournament format: " + string(t.Format))
+	}
+	if t.Format == Swiss && t.TotalRounds < 1 {
+		return errors.New("a Swiss tournament needs at least one round")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.store.SaveTournament(t); err != nil {
+		return err
+	}
+	d.tournaments[t.ID] = t
+	return nil
+}
+
+// Tournament runs fn with the tournament while holding the director's lock.
+// Changes to the tournament are made with Update instead.
+func (d *TournamentDirector) Tournament(id string, fn func(t *Tournament) error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	t, ok := d.tournaments[id]
+	if !ok {
+		return ErrTournamentNotFound
+	}
+	return fn(t)
+}
+
+// Update runs fn with the tournament while holding the director's lock, and
+// saves the tournament if fn succeeds.
+func (d *TournamentDirector) Update(id string, fn func(t *Tournament) error) error {
+	return d.Tournament(id, func(t *Tournament) error {
+		if err := fn(t); err != nil {
+			return err
+		}
+		return d.store.SaveTournament(t)
+	})
+}
+
+// Tournaments returns all tournaments, the newest last.
+func (d *TournamentDirector) Tournaments() []*Tournament {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	list := []*Tournament{}
+	for _, t := range d.tournaments {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// StartRound pairs the next round of the tournament and creates its games
+// with pair. Only the organizer can start a round.
+func (d *TournamentDirector) StartRound(id, username string, pair PairFunc) (*Round, error) {
+	var t *Tournament
+	var round *Round
+	err := d.Tournament(id, func(found *Tournament) error {
+		if found.Organizer != username {
+			return errors.New("only the organizer can start a round")
+		}
+		var err error
+		t = found
+		round, err = t.PairNextRound()
+		if err == nil {
+			d.starting++
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The games are created without holding the lock, as the results of
+	// games come in while the games are locked
+	gameIDs := make([]string, len(round.Pairings))
+	var pairErr error
+	for i, p := range round.Pairings {
+		if p.IsBye() {
+			continue
+		}
+		g, err := pair(p.White, p.Black, t.TimeControl, t.Rated)
+		if err != nil {
+			pairErr = err
+			break
+		}
+		gameIDs[i] = g.ID
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.starting--
+	if pairErr != nil {
+		t.Rounds = t.Rounds[:len(t.Rounds)-1]
+	} else {
+		for i, p := range round.Pairings {
+			p.GameID = gameIDs[i]
+			p.Result = d.unclaimed[p.GameID]
+		}
+	}
+	if d.starting == 0 {
+		d.unclaimed = map[string]GameState{}
+	}
+	if pairErr != nil {
+		return round, pairErr
+	}
+	return round, d.store.SaveTournament(t)
+}
+
+// OnGameOver records the result in the tournament the game belongs to.
+func (d *TournamentDirector) OnGameOver(g *Game, state GameState) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, t := range d.tournaments {
+		if t.RecordGame(g) {
+			if err := d.store.SaveTournament(t); err != nil {
+				log.Println("Failed to save tournament", t.ID, err)
+			}
+			return
+		}
+	}
+	if d.starting > 0 {
+		d.unclaimed[g.ID] = state
+	}
+}
+
+type MemoryTournamentStore struct {
+	mu          sync.Mutex
+	tournaments map[string][]byte
+}
+
+func NewMemoryTournamentStore() *MemoryTournamentStore {
+	return &MemoryTournamentStore{tournaments: map[string][]byte{}}
+}
+
+func (s *MemoryTournamentStore) SaveTournament(t *Tournament) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tournaments[t.ID] = data
+	return nil
+}
+
+func (s *MemoryTournamentStore) AllTournaments() ([]*Tournament, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all := []*Tournament{}
+	for _, data := range s.tournaments {
+		var t Tournament
+		if err := json.Unmarshal(data, &t); err != nil {
+			return nil, err
+		}
+		all = append(all, &t)
+	}
+	return all, nil
+}
+
+// FileTournamentStore keeps one JSON file per tournament in a directory.
+type FileTournamentStore struct {
+	dir string
+}
+
+func NewFileTournamentStore(dir string) (*FileTournamentStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileTournamentStore{dir: dir}, nil
+}
+
+func (s *FileTournamentStore) path(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return "", errors.New("invalid tournament ID: " + id)
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+func (s *FileTournamentStore) SaveTournament(t *Tournament) error {
+	path, err := s.path(t.ID)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *FileTournamentStore) AllTournaments() ([]*Tournament, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	all := []*Tournament{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, name))
+		if err != nil {
+			return nil, err
+		}
+		var t Tournament
+		if err := json.Unmarshal(data, &t); err != nil {
+			return nil, err
+		}
+		all = append(all, &t)
+	}
+	return all, nil
+}
@@ -0,0 +1,278 @@
+package gochess
+
+import (
+	"fmt"
+	"testing"
+)
+
+func newTestTournament(t *testing.T, format TournamentFormat, rounds int, players ...string) *Tournament {
+	t.Helper()
+	tournament := NewTournament("Club championship", "alice", format, rounds, TimeControl{}, false)
+	for i, name := range players {
+		// Players are registered in order of their pairing number
+		if err := tournament.Register(name, float64(2000-i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tournament
+}
+
+// playRound pairs the next round and finishes its games with the result
+// returned by result.
+func playRound(t *testing.T, tournament *Tournament, result func(p *Pairing) GameState) *Round {
+	t.Helper()
+	round, err := tournament.PairNextRound()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range round.Pairings {
+		if p.IsBye() {
+			continue
+		}
+		p.GameID = fmt.Sprintf("%d-%d", len(tournament.Rounds), i)
+		g := NewGame(p.White, p.Black)
+		g.ID = p.GameID
+		g.State = result(p)
+		if !tournament.RecordGame(g) {
+			t.Fatalf("Expected game %s to belong to the tournament", g.ID)
+		}
+	}
+	return round
+}
+
+func whiteWins(p *Pairing) GameState { return WhiteWon }
+
+func TestBergerTables(t *testing.T) {
+	tournament := newTestTournament(t, RoundRobin, 0, "1", "2", "3", "4")
+
+	// The Berger tables for four players
+	expected := [][]string{
+		{"1-4", "2-3"},
+		{"4-3", "1-2"},
+		{"2-4", "3-1"},
+	}
+	for _, boards := range expected {
+		round := playRound(t, tournament, whiteWins)
+		for i, board := range boards {
+			if got := round.Pairings[i].White + "-" + round.Pairings[i].Black; got != board {
+				t.Errorf("Expected board %d to be %s, but got %s", i+1, board, got)
+			}
+		}
+	}
+
+	if !tournament.IsOver() {
+		t.Errorf("Expected the tournament to be over after 3 rounds")
+	}
+	if _, err := tournament.PairNextRound(); err == nil {
+		t.Errorf("Expected no more rounds")
+	}
+}
+
+func TestRoundRobinEveryoneMeetsOnce(t *testing.T) {
+	for _, n := range []int{5, 6, 7} {
+		players := []string{}
+		for i := 0; i < n; i++ {
+			players = append(players, fmt.Sprint("p", i))
+		}
+		tournament := newTestTournament(t, RoundRobin, 0, players...)
+
+		met := map[[2]string]int{}
+		byes := map[string]int{}
+		for !tournament.IsOver() {
+			for _, p := range playRound(t, tournament, whiteWins).Pairings {
+				if p.IsBye() {
+					byes[p.White]++
+					continue
+				}
+				a, b := p.White, p.Black
+				if a > b {
+					a, b = b, a
+				}
+				met[[2]string{a, b}]++
+			}
+		}
+
+		if len(met) != n*(n-1)/2 {
+			t.Errorf("Expected %d games with %d players, but got %d", n*(n-1)/2, n, len(met))
+		}
+		for pair, count := range met {
+			if count != 1 {
+				t.Errorf("Expected %v to meet once, but met %d times", pair, count)
+			}
+		}
+		if n%2 == 1 && len(byes) != n {
+			t.Errorf("Expected every player to have a bye, but got %v", byes)
+		}
+	}
+}
+
+func TestSwissPairing(t *testing.T) {
+	tournament := newTestTournament(t, Swiss, 4, "a", "b", "c", "d", "e", "f", "g")
+
+	// The first round pairs the top half against the bottom half
+	round := playRound(t, tournament, whiteWins)
+	expected := []string{"a-d", "e-b", "c-f"}
+	for i, board := range expected {
+		p := round.Pairings[i]
+		if got := p.White + "-" + p.Black; got != board && p.Black+"-"+p.White != board {
+			t.Errorf("Expected board %d to be %s, but got %s", i+1, board, got)
+		}
+	}
+	if !round.Pairings[3].IsBye() || round.Pairings[3].White != "g" {
+		t.Errorf("Expected the lowest ranked player to get the bye, but got %+v", round.Pairings[3])
+	}
+
+	for len(tournament.Rounds) < 4 {
+		playRound(t, tournament, whiteWins)
+	}
+
+	histories := tournament.histories()
+	met := map[[2]string]bool{}
+	for _, round := range tournament.Rounds {
+		for _, p := range round.Pairings {
+			if p.IsBye() {
+				continue
+			}
+			if met[[2]string{p.White, p.Black}] || met[[2]string{p.Black, p.White}] {
+				t.Errorf("Expected %s and %s to meet only once", p.White, p.Black)
+			}
+			met[[2]string{p.White, p.Black}] = true
+		}
+	}
+	for name, history := range histories {
+		if history.colors < -2 || history.colors > 2 {
+			t.Errorf("Expected balanced colours for %s, but got %d", name, history.colors)
+		}
+	}
+}
+
+func TestStandingsTieBreaks(t *testing.T) {
+	tournament := newTestTournament(t, Swiss, 2, "a", "b", "c", "d")
+	tournament.Rounds = []*Round{
+		{Pairings: []*Pairing{
+			{White: "a", Black: "c", Result: Draw},
+			{White: "b", Black: "d", Result: WhiteWon},
+		}},
+		{Pairings: []*Pairing{
+			{White: "d", Black: "a", Result: BlackWon},
+			{White: "c", Black: "b", Result: WhiteWon},
+		}},
+	}
+
+	// a and c have 1.5 points, b has 1 and d none
+	standings := tournament.Standings()
+	expected := []Standing{
+		{Player: "c", Points: 1.5, Buchholz: 2.5, SonnebornBerger: 1.75},
+		{Player: "a", Points: 1.5, Buchholz: 1.5, SonnebornBerger: 0.75},
+		{Player: "b", Points: 1, Buchholz: 1.5, SonnebornBerger: 0},
+		{Player: "d", Points: 0, Buchholz: 2.5, SonnebornBerger: 0},
+	}
+	for i, standing := range expected {
+		if standings[i] != standing {
+			t.Errorf("Expected place %d to be %+v, but got %+v", i+1, standing, standings[i])
+		}
+	}
+
+	// a and b finish level and b won their game
+	tournament.TieBreaks = []TieBreak{DirectEncounter}
+	tournament.Rounds = []*Round{
+		{Pairings: []*Pairing{
+			{White: "b", Black: "a", Result: WhiteWon},
+			{White: "c", Black: "d", Result: Draw},
+		}},
+		{Pairings: []*Pairing{
+			{White: "a", Black: "c", Result: WhiteWon},
+			{White: "d", Black: "b", Result: WhiteWon},
+		}},
+	}
+	if standings := tournament.Standings(); standings[1].Player != "b" || standings[2].Player != "a" {
+		t.Errorf("Expected b to finish ahead of a on the direct encounter, but got %+v", standings)
+	}
+}
+
+func TestSwissRepeatBye(t *testing.T) {
+	tournament := newTestTournament(t, Swiss, 4, "a", "b", "c", "d", "e")
+	tournament.Rounds = []*Round{
+		{Pairings: []*Pairing{{White: "b", Black: "d", Result: Draw}, {White: "c", Black: "e", Result: Draw}, {White: "a"}}},
+		{Pairings: []*Pairing{{White: "a", Black: "e", Result: Draw}, {White: "c", Black: "d", Result: Draw}, {White: "b"}}},
+		{Pairings: []*Pairing{{White: "a", Black: "d", Result: Draw}, {White: "b", Black: "e", Result: Draw}, {White: "c"}}},
+	}
+
+	// Whether d or e sits out, the other four have all met, so a player who
+	// has had a bye gets another
+	round, err := tournament.PairNextRound()
+	if err != nil {
+		t.Fatal(err)
+	}
+	boards := []string{}
+	for _, p := range round.Pairings {
+		boards = append(boards, p.White+"-"+p.Black)
+	}
+	if len(boards) != 3 || boards[2] != "c-" {
+		t.Errorf("Expected c to get the bye again, but got %v", boards)
+	}
+}
+
+func TestTournamentDirector(t *testing.T) {
+	fileStore, err := NewFileTournamentStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		store TournamentStore
+	}{
+		{name: "memory", store: NewMemoryTournamentStore()},
+		{name: "file", store: fileStore},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			director := NewTournamentDirector(tt.store)
+			tournament := NewTournament("Blitz", "alice", Swiss, 1, TimeControl{}, false)
+			if err := director.Create(tournament); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range []string{"alice", "bob"} {
+				director.Update(tournament.ID, func(t *Tournament) error { return t.Register(name, 1500) })
+			}
+
+			pair := func(white, black string, tc TimeControl, rated bool) (*Game, error) {
+				g := NewGame(white, black)
+				g.AddListener(director)
+				// The game is over before the round has been set up
+				g.Resign(Black)
+				return g, nil
+			}
+			if _, err := director.StartRound(tournament.ID, "bob", pair); err == nil {
+				t.Errorf("Expected only the organizer to start a round")
+			}
+			round, err := director.StartRound(tournament.ID, "alice", pair)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if round.Pairings[0].GameID == "" || round.Pairings[0].Result != WhiteWon {
+				t.Errorf("Expected the result of the game to be recorded, but got %+v", round.Pairings[0])
+			}
+			if !tournament.IsOver() {
+				t.Errorf("Expected the tournament to be over")
+			}
+
+			// The tournament is still there after a restart
+			restarted := NewTournamentDirector(tt.store)
+			if err := restarted.Load(); err != nil {
+				t.Fatal(err)
+			}
+			err = restarted.Tournament(tournament.ID, func(loaded *Tournament) error {
+				if !loaded.IsOver() || len(loaded.Players) != 2 || loaded.Rounds[0].Pairings[0].GameID != round.Pairings[0].GameID {
+					t.Errorf("Expected the finished tournament to be loaded, but got %+v", loaded)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
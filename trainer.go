@@ -0,0 +1,353 @@
+package gochess
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrNoPuzzle is returned when a user has tried every puzzle of the set
+var ErrNoPuzzle = errors.New("no puzzle left to solve")
+
+// PuzzleRecord is a user's puzzle rating, kept apart from their game
+// ratings, and the puzzles they have tried.
+type PuzzleRecord struct {
+	Username string
+	Rating   Rating
+	Solved   int
+	Failed   int
+	// Played holds the IDs of the puzzles tried, which are not served again
+	Played map[string]bool
+}
+
+func NewPuzzleRecord(username string) *PuzzleRecord {
+	return &PuzzleRecord{Username: username, Rating: NewEloRating(), Played: map[string]bool{}}
+}
+
+// PuzzleRecordStore persists the puzzle records of every user.
+type PuzzleRecordStore interface {
+	GetPuzzleRecord(username string) (*PuzzleRecord, error)
+	SavePuzzleRecord(r *PuzzleRecord) error
+}
+
+// PuzzleOutcome is how an attempt at a puzzle went.
+type PuzzleOutcome string
+
+const (
+	PuzzleUnsolved PuzzleOutcome = ""
+	PuzzleSolved   PuzzleOutcome = "Solved"
+	PuzzleFailed   PuzzleOutcome = "Failed"
+)
+
+// PuzzleAttempt is a user working through the solution of a puzzle.
+type PuzzleAttempt struct {
+	Puzzle Puzzle
+	// Position is the position after the moves played so far
+	Position *Game
+	// Played is the number of moves of the solution played so far
+	Played int
+	// Reply is the opponent's last move in SAN, played by the trainer
+	Reply   string
+	Outcome PuzzleOutcome
+	// RatingChange is how much the user's puzzle rating changed when the
+	// attempt ended
+	RatingChange float64
+}
+
+// PuzzleTrainer serves puzzles of a set to users, near their puzzle rating,
+// checks their moves against the solutions and plays the opponent's replies.
+// Users and puzzles are rated against each other with Elo: solving a puzzle
+// wins against it, failing loses.
+type PuzzleTrainer struct {
+	mu       sync.Mutex
+	puzzles  []Puzzle
+	records  PuzzleRecordStore
+	elo      Elo
+	attempts map[string]*PuzzleAttempt
+	// guests are the records of guests, which are not kept past a restart
+	guests PuzzleRecordStore
+}
+
+func NewPuzzleTrainer(puzzles []Puzzle, records PuzzleRecordStore, elo Elo) *PuzzleTrainer {
+	rated := make([]Puzzle, len(puzzles))
+	for i, puzzle := range puzzles {
+		if puzzle.Rating == 0 {
+			puzzle.Rating = DefaultRating
+		}
+		rated[i] = puzzle
+	}
+	return &PuzzleTrainer{
+		puzzles:  rated,
+		records:  records,
+		elo:      elo,
+		attempts: map[string]*PuzzleAttempt{},
+		guests:   NewMemoryPuzzleStore(),
+	}
+}
+
+func (t *PuzzleTrainer) store(username string) PuzzleRecordStore {
+	if strings.HasPrefix(username, guestPrefix) {
+		return t.guests
+	}
+	return t.records
+}
+
+// Record returns the user's puzzle record, a new one if they have not tried
+// a puzzle yet.
+func (t *PuzzleTrainer) Record(username string) (*PuzzleRecord, error) {
+	record, err := t.store(username).GetPuzzleRecord(username)
+	if errors.Is(err, ErrUserNotFound) {
+		return NewPuzzleRecord(username), nil
+	}
+	return record, err
+}
+
+// Current returns the user's attempt at their current puzzle, starting the
+// next one if they have none.
+func (t *PuzzleTrainer) Current(username string) (PuzzleAttempt, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if attempt, ok := t.attempts[username]; ok {
+		return attempt.snapshot(), nil
+	}
+	return t.next(username)
+}
+
+// Next starts the puzzle nearest the user's rating they have not tried yet.
+// Leaving a puzzle unsolved fails it.
+func (t *PuzzleTrainer) Next(username string) (PuzzleAttempt, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if attempt, ok := t.attempts[username]; ok && attempt.Outcome == PuzzleUnsolved {
+		if err := t.finish(username, attempt, PuzzleFailed); err != nil {
+			return PuzzleAttempt{}, err
+		}
+	}
+	return t.next(username)
+}
+
+func (t *PuzzleTrainer) next(username string) (PuzzleAttempt, error) {
+	record, err := t.Record(username)
+	if err != nil {
+		return PuzzleAttempt{}, err
+	}
+	best := -1
+	for i, puzzle := range t.puzzles {
+		if record.Played[puzzle.ID] {
+			continue
+		}
+		if best < 0 || math.Abs(puzzle.Rating-record.Rating.Rating) < math.Abs(t.puzzles[best].Rating-record.Rating.Rating) {
+			best = i
+		}
+	}
+	if best < 0 {
+		delete(t.attempts, username)
+		return PuzzleAttempt{}, ErrNoPuzzle
+	}
+
+	puzzle := t.puzzles[best]
+	position, _, err := puzzle.solution()
+	if err != nil {
+		return PuzzleAttempt{}, err
+	}
+	attempt := &PuzzleAttempt{Puzzle: puzzle, Position: position}
+	t.attempts[username] = attempt
+	return attempt.snapshot(), nil
+}
+
+// Move plays the user's move in long algebraic notation, a pawn reaching the
+// last rank becoming a queen unless told otherwise. The move must be the one
+// of the solution or give mate. The opponent's reply is played straight
+// away, and the attempt ends with the solution or the first wrong move.
+func (t *PuzzleTrainer) Move(username, notation string) (PuzzleAttempt, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	attempt, ok := t.attempts[username]
+	if !ok {
+		return PuzzleAttempt{}, errors.New("there is no puzzle to solve")
+	}
+	if attempt.Outcome != PuzzleUnsolved {
+		return PuzzleAttempt{}, errors.New("the puzzle is over")
+	}
+	position := attempt.Position
+	move, err := parseUCIMove(position, notation)
+	if err != nil && len(notation) == 4 {
+		move, err = parseUCIMove(position, notation+"q")
+	}
+	if err != nil {
+		return PuzzleAttempt{}, err
+	}
+
+	_, solution, err := attempt.Puzzle.solution()
+	if err != nil {
+		return PuzzleAttempt{}, err
+	}
+	right := sameMove(move, solution[attempt.Played])
+	position.applyMove(move)
+	position.finishTurn(position.GetCurrentPlayerColor())
+	attempt.Played++
+	attempt.Reply = ""
+
+	switch {
+	case position.State == WhiteWon || position.State == BlackWon:
+		// Any mate solves the puzzle, even one the solution does not play
+		err = t.finish(username, attempt, PuzzleSolved)
+	case !right:
+		err = t.finish(username, attempt, PuzzleFailed)
+	case attempt.Played == len(solution):
+		err = t.finish(username, attempt, PuzzleSolved)
+	default:
+		reply := solution[attempt.Played]
+		attempt.Reply = position.SAN(reply)
+		position.applyMove(reply)
+		position.finishTurn(position.GetCurrentPlayerColor())
+		attempt.Played++
+	}
+	if err != nil {
+		return PuzzleAttempt{}, err
+	}
+	return attempt.snapshot(), nil
+}
+
+// finish ends the attempt and rates the user and the puzzle against each
+// other. The caller must hold t.mu.
+func (t *PuzzleTrainer) finish(username string, attempt *PuzzleAttempt, outcome PuzzleOutcome) error {
+	record, err := t.Record(username)
+	if err != nil {
+		return err
+	}
+	score := 0.0
+	if outcome == PuzzleSolved {
+		score = 1
+		record.Solved++
+	} else {
+		record.Failed++
+	}
+
+	for i := range t.puzzles {
+		if t.puzzles[i].ID != attempt.Puzzle.ID {
+			continue
+		}
+		puzzle := Rating{Rating: t.puzzles[i].Rating}
+		before := record.Rating.Rating
+		record.Rating = t.elo.Update(record.Rating, puzzle, score)
+		t.puzzles[i].Rating = t.elo.Update(puzzle, Rating{Rating: before}, 1-score).Rating
+		attempt.RatingChange = record.Rating.Rating - before
+	}
+	record.Played[attempt.Puzzle.ID] = true
+	if err := t.store(username).SavePuzzleRecord(record); err != nil {
+		return err
+	}
+	attempt.Outcome = outcome
+	return nil
+}
+
+// snapshot copies the attempt, so that it can be shown while the user plays
+// on.
+func (a *PuzzleAttempt) snapshot() PuzzleAttempt {
+	copied := *a
+	copied.Position = a.Position.searchCopy()
+	return copied
+}
+
+type MemoryPuzzleStore struct {
+	mu      sync.Mutex
+	records map[string][]byte
+}
+
+func NewMemoryPuzzleStore() *MemoryPuzzleStore {
+	return &MemoryPuzzleStore{records: map[string][]byte{}}
+}
+
+func (s *MemoryPuzzleStore) GetPuzzleRecord(username string) (*PuzzleRecord, error) {
+	s.mu.Lock()
+	data, ok := s.records[username]
+	s.mu.Unlock()
+
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return decodePuzzleRecord(data)
+}
+
+func (s *MemoryPuzzleStore) SavePuzzleRecord(record *PuzzleRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[record.Username] = data
+	return nil
+}
+
+// FilePuzzleStore keeps one JSON file per user in a directory.
+type FilePuzzleStore struct {
+	dir string
+}
+
+func NewFilePuzzleStore(dir string) (*FilePuzzleStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FilePuzzleStore{dir: dir}, nil
+}
+
+func (s *FilePuzzleStore) path(username string) (string, error) {
+	if !validUsername.MatchString(username) {
+		return "", ErrUserNotFound
+	}
+	return filepath.Join(s.dir, username+".json"), nil
+}
+
+func (s *FilePuzzleStore) GetPuzzleRecord(username string) (*PuzzleRecord, error) {
+	path, err := s.path(username)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodePuzzleRecord(data)
+}
+
+func (s *FilePuzzleStore) SavePuzzleRecord(record *PuzzleRecord) error {
+	path, err := s.path(record.Username)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func decodePuzzleRecord(data []byte) (*PuzzleRecord, error) {
+	var record PuzzleRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	if record.Played == nil {
+		record.Played = map[string]bool{}
+	}
+	return &record, nil
+}
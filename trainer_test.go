@@ -0,0 +1,156 @@
+package gochess
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// backRankMate is a position where White mates with Ra8
+const backRankMate = "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1"
+
+func TestPuzzleTrainer(t *testing.T) {
+	fileStore, err := NewFilePuzzleStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFilePuzzleStore() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		store PuzzleRecordStore
+	}{
+		{name: "memory", store: NewMemoryPuzzleStore()},
+		{name: "file", store: fileStore},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			puzzles := []Puzzle{
+				{ID: "easy", FEN: backRankMate, Moves: []string{"a1a8"}, Rating: 1200},
+				{ID: "hard", FEN: backRankMate, Moves: []string{"a1a2", "g8h8", "a2a8"}, Rating: 1600},
+			}
+			trainer := NewPuzzleTrainer(puzzles, tt.store, Elo{K: 20})
+
+			// The puzzle nearest the user's rating comes first
+			attempt, err := trainer.Current("alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if attempt.Puzzle.ID != "hard" || attempt.Position.FEN() != backRankMate {
+				t.Fatalf("Expected the hard puzzle first, got %+v", attempt.Puzzle)
+			}
+			if attempt, err = trainer.Move("alice", "a1a2"); err != nil {
+				t.Fatal(err)
+			}
+			if attempt.Reply != "Kh8" || attempt.Outcome != PuzzleUnsolved || attempt.Played != 2 {
+				t.Errorf("Expected the opponent to reply Kh8, got %+v", attempt)
+			}
+			if attempt, err = trainer.Move("alice", "a2a8"); err != nil {
+				t.Fatal(err)
+			}
+			if attempt.Outcome != PuzzleSolved || attempt.RatingChange <= 0 {
+				t.Errorf("Expected the puzzle to be solved, got %+v", attempt)
+			}
+			if _, err := trainer.Move("alice", "a8a1"); err == nil {
+				t.Error("Expected no moves once the puzzle is solved")
+			}
+
+			// Giving up fails the puzzle
+			if attempt, err = trainer.Next("alice"); err != nil || attempt.Puzzle.ID != "easy" {
+				t.Fatalf("Expected the easy puzzle next, got %+v, %v", attempt.Puzzle, err)
+			}
+			if _, err := trainer.Next("alice"); !errors.Is(err, ErrNoPuzzle) {
+				t.Errorf("Expected no puzzle left, got %v", err)
+			}
+
+			// The record is kept in the store
+			record, err := NewPuzzleTrainer(puzzles, tt.store, Elo{K: 20}).Record("alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if record.Solved != 1 || record.Failed != 1 || !record.Played["easy"] || !record.Played["hard"] {
+				t.Errorf("Expected a solved and a failed puzzle, got %+v", record)
+			}
+			// Solving the hard puzzle gains less than failing the easy one loses
+			if math.Abs(record.Rating.Rating-1495.64) > 0.01 {
+				t.Errorf("Expected a rating of 1495.64, got %.2f", record.Rating.Rating)
+			}
+		})
+	}
+}
+
+func TestPuzzleTrainerMoves(t *testing.T) {
+	tests := []struct {
+		name    string
+		puzzle  Puzzle
+		moves   []string
+		outcome PuzzleOutcome
+	}{
+		{
+			name:    "solution",
+			puzzle:  Puzzle{ID: "mate", FEN: backRankMate, Moves: []string{"a1a2", "g8h8", "a2a8"}},
+			moves:   []string{"a1a2", "a2a8"},
+			outcome: PuzzleSolved,
+		},
+		{
+			name:    "other mate",
+			puzzle:  Puzzle{ID: "mate", FEN: backRankMate, Moves: []string{"a1a2", "g8h8", "a2a8"}},
+			moves:   []string{"a1a8"},
+			outcome: PuzzleSolved,
+		},
+		{
+			name:    "wrong move",
+			puzzle:  Puzzle{ID: "mate", FEN: backRankMate, Moves: []string{"a1a8"}},
+			moves:   []string{"g1f1"},
+			outcome: PuzzleFailed,
+		},
+		{
+			name:    "promotion to a queen",
+			puzzle:  Puzzle{ID: "promotion", FEN: "7k/P7/8/8/8/8/6r1/K7 w - - 0 1", Moves: []string{"a7a8q"}},
+			moves:   []string{"a7a8"},
+			outcome: PuzzleSolved,
+		},
+		{
+			name:    "underpromotion",
+			puzzle:  Puzzle{ID: "promotion", FEN: "7k/P7/8/8/8/8/6r1/K7 w - - 0 1", Moves: []string{"a7a8q"}},
+			moves:   []string{"a7a8r"},
+			outcome: PuzzleFailed,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			trainer := NewPuzzleTrainer([]Puzzle{test.puzzle}, NewMemoryPuzzleStore(), Elo{K: 20})
+			if _, err := trainer.Current("alice"); err != nil {
+				t.Fatal(err)
+			}
+			var attempt PuzzleAttempt
+			for _, move := range test.moves {
+				var err error
+				if attempt, err = trainer.Move("alice", move); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if attempt.Outcome != test.outcome {
+				t.Errorf("Expected the puzzle to be %q, got %+v", test.outcome, attempt)
+			}
+		})
+	}
+}
+
+func TestPuzzleTrainerIllegalMove(t *testing.T) {
+	trainer := NewPuzzleTrainer([]Puzzle{{ID: "mate", FEN: backRankMate, Moves: []string{"a1a8"}}}, NewMemoryPuzzleStore(), Elo{K: 20})
+	if _, err := trainer.Move("alice", "a1a8"); err == nil {
+		t.Error("Expected no move before a puzzle is served")
+	}
+	if _, err := trainer.Current("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := trainer.Move("alice", "a1h8"); err == nil {
+		t.Error("Expected an illegal move to be refused")
+	}
+	attempt, err := trainer.Current("alice")
+	if err != nil || attempt.Outcome != PuzzleUnsolved {
+		t.Errorf("Expected an illegal move not to fail the puzzle, got %+v, %v", attempt, err)
+	}
+}
@@ -0,0 +1,138 @@
+package gochess
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// DefaultHashSize is the size of the transposition table in megabytes
+const DefaultHashSize = 16
+
+// Bound tells how the score of a searched position relates to its real score.
+type Bound uint8
+
+const (
+	// ExactBound scores were searched with the full window
+	ExactBound Bound = iota + 1
+	// LowerBound scores failed high: the position is at least this good
+	LowerBound
+	// UpperBound scores failed low: the position is at most this good
+	UpperBound
+)
+
+// TTEntry is a searched position in the transposition table.
+type TTEntry struct {
+	Hash  uint64
+	Depth int
+	Score int
+	Bound Bound
+	// From, To and Promotion are the best move found, From and To are equal
+	// when there is none
+	From       Position
+	To         Position
+	Promotion  PieceType
+	generation uint8
+}
+
+// HasMove reports whether the entry holds a best move.
+func (e TTEntry) HasMove() bool {
+	return e.From != e.To
+}
+
+// TranspositionTable remembers searched positions by their Zobrist hash, so
+// positions reached again by another move order are not searched twice. It
+// has a fixed size: each hash maps to a bucket of two entries, the first kept
+// for the deepest search and the second replaced every time.
+type TranspositionTable struct {
+	mu      sync.Mutex
+	entries []TTEntry
+	buckets uint64
+	// generation counts the searches, so entries of older searches are
+	// replaced first
+	generation uint8
+}
+
+// NewTranspositionTable makes a table of at most sizeMB megabytes.
+func NewTranspositionTable(sizeMB int) *TranspositionTable {
+	buckets := uint64(1)
+	for (buckets*2)*2*uint64(unsafe.Sizeof(TTEntry{})) <= uint64(sizeMB)<<20 {
+		buckets *= 2
+	}
+	return &TranspositionTable{entries: make([]TTEntry, 2*buckets), buckets: buckets}
+}
+
+func (t *TranspositionTable) bucket(hash uint64) []TTEntry {
+	i := (hash & (t.buckets - 1)) * 2
+	return t.entries[i : i+2]
+}
+
+// Probe looks up the position with the given hash.
+func (t *TranspositionTable) Probe(hash uint64) (TTEntry, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, entry := range t.bucket(hash) {
+		if entry.Bound != 0 && entry.Hash == hash {
+			return entry, true
+		}
+	}
+	return TTEntry{}, false
+}
+
+// Store records the result of searching a position. An entry with a deeper
+// search of the current search is only replaced by a deeper one, otherwise
+// the new entry goes in the second slot of the bucket.
+func (t *TranspositionTable) Store(entry TTEntry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry.generation = t.generation
+	bucket := t.bucket(entry.Hash)
+	slot := &bucket[1]
+	if deepest := bucket[0]; deepest.Bound == 0 || deepest.Hash == entry.Hash || deepest.generation != t.generation || entry.Depth >= deepest.Depth {
+		slot = &bucket[0]
+	}
+	// A search that found no best move keeps the one found before
+	if !entry.HasMove() && slot.Hash == entry.Hash {
+		entry.From, entry.To, entry.Promotion = slot.From, slot.To, slot.Promotion
+	}
+	*slot = entry
+}
+
+// NewSearch ages the entries of the searches before.
+func (t *TranspositionTable) NewSearch() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.generation++
+}
+
+// Clear empties the table.
+func (t *TranspositionTable) Clear() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range t.entries {
+		t.entries[i] = TTEntry{}
+	}
+}
+
+// scoreToTT stores mate scores as the distance to mate from the position
+// rather than from the root, as the position can be reached at another ply.
+func scoreToTT(score, ply int) int {
+	if score > mateScore-1000 {
+		return score + ply
+	}
+	if score < -mateScore+1000 {
+		return score - ply
+	}
+	return score
+}
+
+func scoreFromTT(score, ply int) int {
+	if score > mateScore-1000 {
+		return score - ply
+	}
+	if score < -mateScore+1000 {
+		return score + ply
+	}
+	return score
+}
@@ -0,0 +1,118 @@
+package gochess
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestTranspositionTableSize(t *testing.T) {
+	tt := NewTranspositionTable(1)
+	size := len(tt.entries) * int(unsafe.Sizeof(TTEntry{}))
+	if size > 1<<20 || size <= 1<<19 {
+		t.Errorf("Expected the table to use between half and all of 1MB, got %d bytes", size)
+	}
+	if tt.buckets&(tt.buckets-1) != 0 {
+		t.Errorf("Expected a power of two buckets, got %d", tt.buckets)
+	}
+}
+
+func TestTranspositionTableReplacement(t *testing.T) {
+	tt := NewTranspositionTable(1)
+	// The hashes all fall in the same bucket
+	a, b, c := uint64(5), 5+tt.buckets, 5+2*tt.buckets
+	from, to := Position{X: 4, Y: 1}, Position{X: 4, Y: 3}
+
+	if _, ok := tt.Probe(a); ok {
+		t.Fatal("Expected an empty table")
+	}
+
+	tt.Store(TTEntry{Hash: a, Depth: 5, Score: 30, Bound: ExactBound, From: from, To: to})
+	tt.Store(TTEntry{Hash: b, Depth: 2, Score: -10, Bound: LowerBound})
+	entry, ok := tt.Probe(a)
+	if !ok || entry.Depth != 5 || entry.Score != 30 || entry.Bound != ExactBound || entry.From != from || entry.To != to {
+		t.Errorf("Expected the deeper search to be kept, got %+v", entry)
+	}
+	if entry, ok := tt.Probe(b); !ok || entry.Bound != LowerBound {
+		t.Errorf("Expected the shallower search in the second slot, got %+v", entry)
+	}
+
+	// The second slot is always replaced
+	tt.Store(TTEntry{Hash: c, Depth: 1, Score: 0, Bound: UpperBound})
+	if _, ok := tt.Probe(b); ok {
+		t.Error("Expected the second slot to be replaced")
+	}
+	if _, ok := tt.Probe(a); !ok {
+		t.Error("Expected the deeper search to survive")
+	}
+
+	// Searching the same position again keeps its best move
+	tt.Store(TTEntry{Hash: a, Depth: 6, Score: 10, Bound: UpperBound})
+	if entry, _ := tt.Probe(a); entry.Depth != 6 || entry.From != from || entry.To != to {
+		t.Errorf("Expected the new search with the old best move, got %+v", entry)
+	}
+
+	// Entries of older searches give way
+	tt.NewSearch()
+	tt.Store(TTEntry{Hash: b, Depth: 1, Score: 0, Bound: ExactBound})
+	if _, ok := tt.Probe(a); ok {
+		t.Error("Expected the entry of the last search to be replaced")
+	}
+	if _, ok := tt.Probe(b); !ok {
+		t.Error("Expected the entry of this search to be stored")
+	}
+
+	tt.Clear()
+	if _, ok := tt.Probe(b); ok {
+		t.Error("Expected Clear() to empty the table")
+	}
+}
+
+func TestMateScoresInTT(t *testing.T) {
+	// Mate in 3 from the root, found 2 plies in
+	score := mateScore - 3
+	stored := scoreToTT(score, 2)
+	if stored != mateScore-1 {
+		t.Errorf("scoreToTT() = %d, want mate in 1 from the position", stored)
+	}
+	// The same position reached 4 plies in is mate in 5 from the root
+	if got := scoreFromTT(stored, 4); got != mateScore-5 {
+		t.Errorf("scoreFromTT() = %d, want %d", got, mateScore-5)
+	}
+	if got := scoreFromTT(scoreToTT(-mateScore+6, 3), 3); got != -mateScore+6 {
+		t.Errorf("Expected a mated score to round trip, got %d", got)
+	}
+	if got := scoreToTT(150, 7); got != 150 {
+		t.Errorf("scoreToTT() = %d, want other scores unchanged", got)
+	}
+}
+
+func TestSearchWithTranspositionTable(t *testing.T) {
+	g := NewGame("alice", "bob")
+	playMoves(t, g, "e2e4", "e7e5", "g1f3", "b8c6")
+
+	without := &Engine{Evaluate: Evaluate}
+	want, err := without.Search(g, SearchLimits{Depth: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := NewEngine()
+	first, err := e.Search(g, SearchLimits{Depth: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Score != want.Score {
+		t.Errorf("Search() with the table scored %d, want %d", first.Score, want.Score)
+	}
+	if first.Nodes >= want.Nodes {
+		t.Errorf("Expected the table to save nodes, got %d against %d", first.Nodes, want.Nodes)
+	}
+
+	again, err := e.Search(g, SearchLimits{Depth: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Nodes >= first.Nodes || again.Move != first.Move {
+		t.Errorf("Expected searching again to reuse the table, got %d nodes and %v against %d and %v", again.Nodes, again.Move, first.Nodes, first.Move)
+	}
+}
@@ -0,0 +1,354 @@
+package gochess
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// moveOverhead is kept back from each move to answer the GUI in time
+const moveOverhead = 50 * time.Millisecond
+
+// UCI speaks the Universal Chess Interface over a pair of streams, so chess
+// GUIs can load gochess as an engine. Commands are handled one at a time
+// while a search runs in the background until it finishes or is stopped.
+type UCI struct {
+	engine *Engine
+	game   *Game
+
+	outMu sync.Mutex
+	out   io.Writer
+
+	// multiPV is the number of lines reported, searched with Analyze when
+	// more than one
+	multiPV int
+	// chess960 plays positions by the rules of Chess960, castling written
+	// as the king taking its own rook
+	chess960 bool
+
+	// stop and done are set while a search runs
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewUCI(engine *Engine, out io.Writer) *UCI {
+	return &UCI{engine: engine, game: NewGame("", ""), out: out, multiPV: 1}
+}
+
+// Run reads commands until quit or the end of the input.
+func (u *UCI) Run(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		if !u.Handle(scanner.Text()) {
+			return nil
+		}
+	}
+	u.stopSearch()
+	return scanner.Err()
+}
+
+func (u *UCI) send(format string, args ...interface{}) {
+	u.outMu.Lock()
+	defer u.outMu.Unlock()
+	fmt.Fprintf(u.out, format+"\n", args...)
+}
+
+// Handle runs a single command. It returns false once the GUI quits.
+func (u *UCI) Handle(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return true
+	}
+
+	switch fields[0] {
+	case "uci":
+		u.send("id name gochess")
+		u.send("id author the gochess authors")
+		u.send("option name Hash type spin default %d min 1 max 1024", DefaultHashSize)
+		u.send("option name Clear Hash type button")
+		u.send("option name MultiPV type spin default 1 min 1 max %d", MaxAnalysisLines)
+		u.send("option name UCI_Chess960 type check default false")
+		u.send("uciok")
+	case "isready":
+		u.send("readyok")
+	case "ucinewgame":
+		u.stopSearch()
+		u.engine.TT.Clear()
+		u.game = NewGame("", "")
+	case "setoption":
+		u.stopSearch()
+		if err := u.setOption(fields[1:]); err != nil {
+			u.send("info string %v", err)
+		}
+	case "position":
+		u.stopSearch()
+		if err := u.position(fields[1:]); err != nil {
+			u.send("info string %v", err)
+		}
+	case "go":
+		u.stopSearch()
+		if err := u.startSearch(fields[1:]); err != nil {
+			u.send("info string %v", err)
+		}
+	case "stop":
+		u.stopSearch()
+	case "quit":
+		u.stopSearch()
+		return false
+	case "debug", "ponderhit", "register":
+	default:
+		u.send("info string unknown command %s", fields[0])
+	}
+	return true
+}
+
+// setOption handles "setoption name <id> [value <x>]", where the name and
+// value can be several words.
+func (u *UCI) setOption(args []string) error {
+	name, value := []string{}, []string{}
+	target := &name
+	for _, arg := range args {
+		switch arg {
+		case "name":
+			target = &name
+		case "value":
+			target = &value
+		default:
+			*target = append(*target, arg)
+		}
+	}
+
+	switch strings.ToLower(strings.Join(name, " ")) {
+	case "hash":
+		size, err := strconv.Atoi(strings.Join(value, " "))
+		if err != nil || size < 1 || size > 1024 {
+			return fmt.Errorf("invalid hash size %q", strings.Join(value, " "))
+		}
+		u.engine.TT = NewTranspositionTable(size)
+	case "clear hash":
+		u.engine.TT.Clear()
+	case "multipv":
+		lines, err := strconv.Atoi(strings.Join(value, " "))
+		if err != nil || lines < 1 || lines > MaxAnalysisLines {
+			return fmt.Errorf("invalid number of lines %q", strings.Join(value, " "))
+		}
+		u.multiPV = lines
+	case "uci_chess960":
+		enabled, err := strconv.ParseBool(strings.Join(value, " "))
+		if err != nil {
+			return fmt.Errorf("invalid UCI_Chess960 value %q", strings.Join(value, " "))
+		}
+		u.chess960 = enabled
+	default:
+		return fmt.Errorf("unknown option %q", strings.Join(name, " "))
+	}
+	return nil
+}
+
+// position handles "position [startpos | fen <fen>] [moves <move>...]".
+func (u *UCI) position(args []string) error {
+	if len(args) == 0 {
+		return errors.New("position needs startpos or fen")
+	}
+
+	var g *Game
+	moves := []string{}
+	for i, arg := range args {
+		if arg == "moves" {
+			moves = args[i+1:]
+			args = args[:i]
+			break
+		}
+	}
+	switch args[0] {
+	case "startpos":
+		g = NewGame("", "")
+	case "fen":
+		var err error
+		g, err = ParseFEN(strings.Join(args[1:], " "))
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown position %q", args[0])
+	}
+	if u.chess960 {
+		g.Chess960 = true
+	}
+
+	for _, notation := range moves {
+		move, err := parseUCIMove(g, notation)
+		if err != nil {
+			return err
+		}
+		g.applyMove(move)
+	}
+	u.game = g
+	return nil
+}
+
+// parseUCIMove finds the legal move written in long algebraic notation, like
+// e2e4 or e7e8q.
+func parseUCIMove(g *Game, notation string) (Move, error) {
+	for _, move := range g.LegalMoves() {
+		if uciMove(move) == notation {
+			return move, nil
+		}
+	}
+	return Move{}, fmt.Errorf("illegal move %s", notation)
+}
+
+// uciMove writes a move in long algebraic notation. Castling in Chess960 is
+// the king taking its own rook, like e1h1.
+func uciMove(m Move) string {
+	notation := coordinatesToNotation(m.From, m.To)
+	if m.Promotion != "" {
+		notation += string(pieceLetters[m.Promotion])
+	}
+	return notation
+}
+
+// startSearch handles "go" with its depth, nodes, movetime, clock and
+// infinite limits.
+func (u *UCI) startSearch(args []string) error {
+	limits := SearchLimits{}
+	infinite := false
+	var left, increment time.Duration
+	movesToGo := 0
+	color := u.game.GetCurrentPlayerColor()
+
+	for i := 0; i < len(args); i++ {
+		if args[i] == "infinite" {
+			infinite = true
+			continue
+		}
+		if args[i] == "ponder" {
+			continue
+		}
+		if i+1 >= len(args) {
+			return fmt.Errorf("go %s needs a value", args[i])
+		}
+		value, err := strconv.Atoi(args[i+1])
+		if err != nil {
+			return fmt.Errorf("invalid value %q for go %s", args[i+1], args[i])
+		}
+		ms := time.Duration(value) * time.Millisecond
+		switch args[i] {
+		case "depth":
+			limits.Depth = value
+		case "nodes":
+			limits.Nodes = value
+		case "movetime":
+			limits.Time = ms
+		case "wtime", "btime":
+			if (args[i] == "wtime") == (color == White) {
+				left = ms
+			}
+		case "winc", "binc":
+			if (args[i] == "winc") == (color == White) {
+				increment = ms
+			}
+		case "movestogo":
+			movesToGo = value
+		}
+		i++
+	}
+	if left > 0 && limits.Time == 0 {
+		limits.Time = moveTime(left, increment, movesToGo)
+	}
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	limits.Stop = stop
+	u.stop, u.done = stop, done
+	g := u.game.searchCopy()
+	multiPV := u.multiPV
+
+	go func() {
+		defer close(done)
+		var result SearchResult
+		var err error
+		if multiPV > 1 {
+			var lines []SearchResult
+			lines, err = u.engine.Analyze(g, limits, multiPV, u.infoLines)
+			if err == nil {
+				result = lines[0]
+			}
+		} else {
+			result, err = u.engine.SearchWithInfo(g, limits, u.info)
+		}
+		// In infinite mode the best move waits for the GUI to stop the search
+		if infinite {
+			<-stop
+		}
+		if err != nil {
+			u.send("bestmove 0000")
+			return
+		}
+		u.send("bestmove %s", uciMove(result.Move))
+	}()
+	return nil
+}
+
+// stopSearch stops the running search, if any, and waits for its best move.
+func (u *UCI) stopSearch() {
+	if u.stop == nil {
+		return
+	}
+	close(u.stop)
+	<-u.done
+	u.stop, u.done = nil, nil
+}
+
+// info reports a finished depth of the search.
+func (u *UCI) info(result SearchResult) {
+	u.infoLine(result, "")
+}
+
+// infoLines reports the lines of a finished depth of a multi-PV search,
+// numbered from the best.
+func (u *UCI) infoLines(results []SearchResult) {
+	for i, result := range results {
+		u.infoLine(result, fmt.Sprintf(" multipv %d", i+1))
+	}
+}
+
+func (u *UCI) infoLine(result SearchResult, multiPV string) {
+	score := fmt.Sprintf("cp %d", result.Score)
+	if result.IsMate() {
+		// Mates are given in moves, negative when the engine is mated
+		score = fmt.Sprintf("mate %d", result.MateIn())
+	}
+
+	pv := []string{}
+	for _, move := range result.PV {
+		pv = append(pv, uciMove(move))
+	}
+	nps := 0
+	if result.Time > 0 {
+		nps = int(float64(result.Nodes) / result.Time.Seconds())
+	}
+	u.send("info depth %d%s score %s nodes %d nps %d time %d pv %s",
+		result.Depth, multiPV, score, result.Nodes, nps, result.Time.Milliseconds(), strings.Join(pv, " "))
+}
+
+// moveTime decides how long to think on a move with the time left on the
+// clock, spreading it over the moves to go before the next time control or
+// over the next 30 moves.
+func moveTime(left, increment time.Duration, movesToGo int) time.Duration {
+	if movesToGo <= 0 {
+		movesToGo = 30
+	}
+	t := left/time.Duration(movesToGo) + increment*3/4
+	if max := left/2 - moveOverhead; t > max {
+		t = max
+	}
+	if t < 10*time.Millisecond {
+		t = 10 * time.Millisecond
+	}
+	return t
+}
@@ -0,0 +1,226 @@
+package gochess
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// uciSession runs the UCI loop in the background. send writes a command and
+// expect reads the output up to the line starting with the prefix.
+func uciSession(t *testing.T) (send func(string), expect func(prefix string) []string) {
+	t.Helper()
+	return protocolSession(t, func(in io.Reader, out io.Writer) {
+		NewUCI(&Engine{Evaluate: Evaluate, TT: NewTranspositionTable(1)}, out).Run(in)
+	})
+}
+
+// protocolSession runs an engine protocol loop in the background, like
+// uciSession.
+func protocolSession(t *testing.T, run func(in io.Reader, out io.Writer)) (send func(string), expect func(prefix string) []string) {
+	t.Helper()
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	go run(inReader, outWriter)
+	t.Cleanup(func() {
+		inWriter.Close()
+		outReader.Close()
+	})
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(outReader)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	send = func(command string) {
+		if _, err := io.WriteString(inWriter, command+"\n"); err != nil {
+			t.Fatalf("Failed to send %q: %v", command, err)
+		}
+	}
+	expect = func(prefix string) []string {
+		t.Helper()
+		read := []string{}
+		for {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					t.Fatalf("Expected %q, but the output ended after %v", prefix, read)
+				}
+				read = append(read, line)
+				if strings.HasPrefix(line, prefix) {
+					return read
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatalf("Expected %q, but got %v", prefix, read)
+			}
+		}
+	}
+	return send, expect
+}
+
+func TestUCIHandshake(t *testing.T) {
+	send, expect := uciSession(t)
+
+	send("uci")
+	lines := expect("uciok")
+	if lines[0] != "id name gochess" {
+		t.Errorf("Expected the engine to introduce itself, got %q", lines[0])
+	}
+	if !strings.Contains(strings.Join(lines, "\n"), "option name Hash type spin") {
+		t.Errorf("Expected the Hash option, got %v", lines)
+	}
+
+	send("isready")
+	expect("readyok")
+}
+
+func TestUCIPosition(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    string
+	}{
+		{"Starting position", "position startpos", StartFEN},
+		{"Moves", "position startpos moves e2e4 e7e5 g1f3", "rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2"},
+		{"Castling", "position startpos moves e2e4 e7e5 g1f3 b8c6 f1c4 f8c5 e1g1", "r1bqk1nr/pppp1ppp/2n5/2b1p3/2B1P3/5N2/PPPP1PPP/RNBQ1RK1 b kq - 5 4"},
+		{"FEN", "position fen 8/P7/8/8/8/8/8/k6K w - - 0 40 moves a7a8n", "N7/8/8/8/8/8/8/k6K b - - 0 40"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := NewUCI(NewEngine(), io.Discard)
+			u.Handle(tt.command)
+			if got := u.game.FEN(); got != tt.want {
+				t.Errorf("FEN() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	var out strings.Builder
+	u := NewUCI(NewEngine(), &out)
+	u.Handle("position startpos moves e2e5")
+	if !strings.Contains(out.String(), "illegal move e2e5") {
+		t.Errorf("Expected the illegal move to be reported, got %q", out.String())
+	}
+}
+
+func TestUCISearch(t *testing.T) {
+	send, expect := uciSession(t)
+
+	send("position fen 6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1")
+	send("go depth 3")
+	lines := expect("bestmove")
+	if got := lines[len(lines)-1]; got != "bestmove a1a8" {
+		t.Errorf("Expected mate on the back rank, got %q", got)
+	}
+	info := lines[len(lines)-2]
+	for _, part := range []string{"depth 2", "score mate 1", "nodes ", "nps ", "time ", "pv a1a8"} {
+		if !strings.Contains(info, part) {
+			t.Errorf("Expected %q in the info line %q", part, info)
+		}
+	}
+
+	// An infinite search answers only once stopped
+	send("position startpos moves e2e4")
+	send("go infinite")
+	send("isready")
+	lines = expect("readyok")
+	for _, line := range lines {
+		if strings.HasPrefix(line, "bestmove") {
+			t.Fatalf("Expected no best move before stop, got %v", lines)
+		}
+	}
+	send("stop")
+	expect("bestmove")
+
+	send("position fen 7k/5Q2/8/8/8/8/8/K7 b - - 0 1")
+	send("go wtime 1000 btime 1000")
+	if lines := expect("bestmove"); lines[len(lines)-1] != "bestmove 0000" {
+		t.Errorf("Expected no move when stalemated, got %v", lines)
+	}
+}
+
+func TestUCIMultiPV(t *testing.T) {
+	send, expect := uciSession(t)
+
+	send("setoption name MultiPV value 3")
+	send("position fen 6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1")
+	send("go depth 2")
+	lines := expect("bestmove")
+	if got := lines[len(lines)-1]; got != "bestmove a1a8" {
+		t.Errorf("Expected mate on the back rank, got %q", got)
+	}
+	for i, want := range []string{"depth 2 multipv 1 score mate 1", "depth 2 multipv 2 ", "depth 2 multipv 3 "} {
+		if info := lines[len(lines)-4+i]; !strings.Contains(info, want) {
+			t.Errorf("Expected %q in the info line %q", want, info)
+		}
+	}
+}
+
+func TestUCISetOption(t *testing.T) {
+	u := NewUCI(NewEngine(), io.Discard)
+	u.Handle("setoption name Hash value 1")
+	if size := len(u.engine.TT.entries); size >= len(NewTranspositionTable(DefaultHashSize).entries) {
+		t.Errorf("Expected a smaller table, got %d entries", size)
+	}
+
+	var out strings.Builder
+	u = NewUCI(NewEngine(), &out)
+	u.Handle("setoption name Hash value lots")
+	u.Handle("setoption name Ponder value true")
+	u.Handle("setoption name MultiPV value 50")
+	if got := out.String(); !strings.Contains(got, "invalid hash size") || !strings.Contains(got, "unknown option") || !strings.Contains(got, "invalid number of lines") {
+		t.Errorf("Expected the options to be rejected, got %q", got)
+	}
+}
+
+func TestMoveTime(t *testing.T) {
+	tests := []struct {
+		name      string
+		left      time.Duration
+		increment time.Duration
+		movesToGo int
+		want      time.Duration
+	}{
+		{"Sudden death", 60 * time.Second, 0, 0, 2 * time.Second},
+		{"Increment", 60 * time.Second, 2 * time.Second, 0, 3500 * time.Millisecond},
+		{"Moves to go", 60 * time.Second, 0, 10, 6 * time.Second},
+		{"Last move before the control", 4 * time.Second, 0, 1, 2*time.Second - moveOverhead},
+		{"Almost out of time", 30 * time.Millisecond, 0, 0, 10 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := moveTime(tt.left, tt.increment, tt.movesToGo); got != tt.want {
+				t.Errorf("moveTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUCIChess960(t *testing.T) {
+	var out strings.Builder
+	u := NewUCI(NewEngine(), &out)
+	u.Handle("uci")
+	if !strings.Contains(out.String(), "option name UCI_Chess960 type check default false") {
+		t.Errorf("Expected the UCI_Chess960 option, got %q", out.String())
+	}
+
+	// Castling is the king taking its rook once the option is on
+	u.Handle("setoption name UCI_Chess960 value true")
+	u.Handle("position startpos moves e2e4 e7e5 g1f3 b8c6 f1c4 g8f6 e1h1")
+	if got, want := u.game.FEN(), "r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQ1RK1 b kq - 5 4"; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+
+	u.Handle("setoption name UCI_Chess960 value maybe")
+	if !strings.Contains(out.String(), "invalid UCI_Chess960 value") {
+		t.Errorf("Expected the value to be rejected, got %q", out.String())
+	}
+}
@@ -0,0 +1,394 @@
+package gochess
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// stopTimeout is how long an engine has to answer once told to stop
+const stopTimeout = 5 * time.Second
+
+// UCIOption is an option offered by an external engine.
+type UCIOption struct {
+	Name    string
+	Type    string
+	Default string
+	Min     string
+	Max     string
+	Vars    []string
+}
+
+// UCIEngine drives an external engine over the Universal Chess Interface.
+// One command is exchanged with the engine at a time.
+type UCIEngine struct {
+	Name    string
+	Author  string
+	Options map[string]UCIOption
+
+	mu    sync.Mutex
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	// lines are read from the engine's output, and closed when it exits
+	lines  chan string
+	exited chan struct{}
+}
+
+// StartUCIEngine launches the engine and waits for it to introduce itself
+// and its options.
+func StartUCIEngine(ctx context.Context, path string, args ...string) (*UCIEngine, error) {
+	cmd := exec.Command(path, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	e := &UCIEngine{
+		Options: map[string]UCIOption{},
+		cmd:     cmd,
+		stdin:   stdin,
+		lines:   make(chan string, 64),
+		exited:  make(chan struct{}),
+	}
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			e.lines <- scanner.Text()
+		}
+		close(e.lines)
+		cmd.Wait()
+		close(e.exited)
+	}()
+
+	err = e.send("uci")
+	if err == nil {
+		err = e.readUntil(ctx, func(fields []string) bool {
+			switch fields[0] {
+			case "id":
+				if len(fields) > 2 && fields[1] == "name" {
+					e.Name = strings.Join(fields[2:], " ")
+				} else if len(fields) > 2 && fields[1] == "author" {
+					e.Author = strings.Join(fields[2:], " ")
+				}
+			case "option":
+				option := parseUCIOption(fields[1:])
+				e.Options[strings.ToLower(option.Name)] = option
+			}
+			return fields[0] == "uciok"
+		})
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		return nil, err
+	}
+	if e.Name == "" {
+		e.Name = path
+	}
+	return e, nil
+}
+
+// parseUCIOption reads "name <id> type <t> default <x> min <x> max <x> var <x>...",
+// where names and values can be several words.
+func parseUCIOption(fields []string) UCIOption {
+	option := UCIOption{}
+	values := map[string][]string{}
+	key := ""
+	for _, field := range fields {
+		switch field {
+		case "name", "type", "default", "min", "max":
+			key = field
+			values[key] = []string{}
+		case "var":
+			key = field
+			option.Vars = append(option.Vars, "")
+		default:
+			if key == "var" {
+				last := &option.Vars[len(option.Vars)-1]
+				*last = strings.TrimSpace(*last + " " + field)
+				continue
+			}
+			values[key] = append(values[key], field)
+		}
+	}
+	option.Name = strings.Join(values["name"], " ")
+	option.Type = strings.Join(values["type"], " ")
+	option.Default = strings.Join(values["default"], " ")
+	option.Min = strings.Join(values["min"], " ")
+	option.Max = strings.Join(values["max"], " ")
+	return option
+}
+
+func (e *UCIEngine) send(command string) error {
+	_, err := io.WriteString(e.stdin, command+"\n")
+	return err
+}
+
+// readUntil passes the engine's output to handle line by line, until handle
+// returns true, the context is done or the engine exits.
+func (e *UCIEngine) readUntil(ctx context.Context, handle func(fields []string) bool) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case line, ok := <-e.lines:
+			if !ok {
+				return errors.New("engine exited")
+			}
+			fields := strings.Fields(line)
+			if len(fields) > 0 && handle(fields) {
+				return nil
+			}
+		}
+	}
+}
+
+// SetOption sets one of the options the engine offers. Buttons are pressed
+// by leaving the value empty.
+func (e *UCIEngine) SetOption(name, value string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	option, ok := e.Options[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("%s has no option %q", e.Name, name)
+	}
+	if option.Type == "button" {
+		return e.send("setoption name " + option.Name)
+	}
+	return e.send("setoption name " + option.Name + " value " + value)
+}
+
+// IsReady waits for the engine to be done with the commands sent so far.
+func (e *UCIEngine) IsReady(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.isReady(ctx)
+}
+
+func (e *UCIEngine) isReady(ctx context.Context) error {
+	if err := e.send("isready"); err != nil {
+		return err
+	}
+	return e.readUntil(ctx, func(fields []string) bool { return fields[0] == "readyok" })
+}
+
+// NewGame tells the engine the next search is from another game.
+func (e *UCIEngine) NewGame(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.send("ucinewgame"); err != nil {
+		return err
+	}
+	return e.isReady(ctx)
+}
+
+// Search asks the engine for the best move of the game's position within
+// the limits, calling info with each depth the engine reports. Once the
+// context is done or limits.Stop is closed the engine is told to stop, and
+// Search returns the context's error or the engine's move.
+func (e *UCIEngine) Search(ctx context.Context, g *Game, limits SearchLimits, info func(SearchResult)) (SearchResult, error) {
+	if g.State != Ongoing {
+		return SearchResult{}, errors.New("Game is not ongoing, got state: " + string(g.State))
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.send(uciPositionCommand(g)); err != nil {
+		return SearchResult{}, err
+	}
+	if err := e.send(uciGoCommand(limits)); err != nil {
+		return SearchResult{}, err
+	}
+
+	result := SearchResult{}
+	var cancelled error
+	stopped := false
+	var stopTimer <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+		case <-limits.Stop:
+		case <-stopTimer:
+			return SearchResult{}, errors.New(e.Name + " did not stop")
+		case line, ok := <-e.lines:
+			if !ok {
+				return SearchResult{}, errors.New("engine exited")
+			}
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+
+			switch fields[0] {
+			case "info":
+				if depth, ok := parseUCIInfo(g, fields[1:]); ok {
+					result = depth
+					if info != nil {
+						info(depth)
+					}
+				}
+			case "bestmove":
+				if len(fields) < 2 || fields[1] == "0000" || fields[1] == "(none)" {
+					return SearchResult{}, errors.New("no legal moves")
+				}
+				move, err := parseUCIMove(g, fields[1])
+				if err != nil {
+					return SearchResult{}, fmt.Errorf("%s played an %v", e.Name, err)
+				}
+				if cancelled != nil {
+					return SearchResult{}, cancelled
+				}
+				result.Move = move
+				if len(result.PV) == 0 || uciMove(result.PV[0]) != uciMove(move) {
+					result.PV = []Move{move}
+				}
+				return result, nil
+			}
+			continue
+		}
+
+		// The context is done or the search was stopped. The engine still
+		// answers with a move, which is read so the next search starts clean.
+		if !stopped {
+			stopped = true
+			cancelled = ctx.Err()
+			stopTimer = time.After(stopTimeout)
+			if err := e.send("stop"); err != nil {
+				return SearchResult{}, err
+			}
+		}
+		ctx = context.Background()
+		limits.Stop = nil
+	}
+}
+
+// Close asks the engine to quit, and kills it if it does not.
+func (e *UCIEngine) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.send("quit")
+	e.stdin.Close()
+	select {
+	case <-e.exited:
+		return nil
+	case <-time.After(stopTimeout):
+		return e.cmd.Process.Kill()
+	}
+}
+
+// uciPositionCommand writes the position command for the game: the position
+// it started from and the moves played since.
+func uciPositionCommand(g *Game) string {
+	start := g.searchCopy()
+	for len(start.History) > 0 {
+		start.takeBack()
+	}
+
+	command := "position startpos"
+	if fen := start.FEN(); fen != StartFEN {
+		command = "position fen " + fen
+	}
+	if len(g.History) > 0 {
+		moves := []string{}
+		for _, move := range g.History {
+			moves = append(moves, uciMove(move))
+		}
+		command += " moves " + strings.Join(moves, " ")
+	}
+	return command
+}
+
+// uciGoCommand writes the go command for the limits. Without any, the engine
+// searches until stopped.
+func uciGoCommand(limits SearchLimits) string {
+	command := "go"
+	if limits.Depth > 0 {
+		command += " depth " + strconv.Itoa(limits.Depth)
+	}
+	if limits.Nodes > 0 {
+		command += " nodes " + strconv.Itoa(limits.Nodes)
+	}
+	if limits.Time > 0 {
+		command += " movetime " + strconv.FormatInt(limits.Time.Milliseconds(), 10)
+	}
+	if command == "go" {
+		command += " infinite"
+	}
+	return command
+}
+
+// parseUCIInfo reads an info line of a depth the engine has searched, with
+// its score and line. Other info lines are skipped.
+func parseUCIInfo(g *Game, fields []string) (SearchResult, bool) {
+	result := SearchResult{}
+	hasScore := false
+	for i := 0; i < len(fields); i++ {
+		value := func() int {
+			if i+1 >= len(fields) {
+				return 0
+			}
+			i++
+			n, _ := strconv.Atoi(fields[i])
+			return n
+		}
+
+		switch fields[i] {
+		case "depth":
+			result.Depth = value()
+		case "nodes":
+			result.Nodes = value()
+		case "time":
+			result.Time = time.Duration(value()) * time.Millisecond
+		case "score":
+			if i+2 >= len(fields) {
+				return result, false
+			}
+			hasScore = true
+			kind := fields[i+1]
+			i++
+			score := value()
+			if kind == "mate" {
+				// Mates are given in moves, negative when the engine is mated
+				if score > 0 {
+					score = mateScore - (2*score - 1)
+				} else {
+					score = -mateScore - 2*score
+				}
+			}
+			result.Score = score
+		case "pv":
+			position := g.searchCopy()
+			for _, notation := range fields[i+1:] {
+				move, err := parseUCIMove(position, notation)
+				if err != nil {
+					break
+				}
+				position.applyMove(move)
+				result.PV = append(result.PV, move)
+			}
+			i = len(fields)
+		case "string":
+			return result, false
+		case "seldepth", "multipv", "nps", "hashfull", "tbhits", "cpuload", "currmove", "currmovenumber":
+			i++
+		}
+	}
+	return result, hasScore && result.Depth > 0 && len(result.PV) > 0
+}
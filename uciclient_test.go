@@ -0,0 +1,209 @@
+package gochess
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// startFakeEngine builds the fake engine in testdata and starts it.
+func startFakeEngine(t *testing.T) *UCIEngine {
+	t.Helper()
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("The go tool is needed to build the fake engine")
+	}
+	bin := filepath.Join(t.TempDir(), "fakeengine")
+	if out, err := exec.Command(goTool, "build", "-o", bin, "./testdata/fakeengine").CombinedOutput(); err != nil {
+		t.Fatalf("Failed to build the fake engine: %v\n%s", err, out)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	e, err := StartUCIEngine(ctx, bin)
+	if err != nil {
+		t.Fatalf("StartUCIEngine() error = %v", err)
+	}
+	t.Cleanup(func() { e.Close() })
+	return e
+}
+
+func TestUCIEngineHandshake(t *testing.T) {
+	e := startFakeEngine(t)
+	if e.Name != "Fake Engine" || e.Author != "gochess tests" {
+		t.Errorf("Expected the engine to introduce itself, got %q by %q", e.Name, e.Author)
+	}
+
+	want := map[string]UCIOption{
+		"skill level": {Name: "Skill Level", Type: "spin", Default: "10", Min: "0", Max: "20"},
+		"style":       {Name: "Style", Type: "combo", Default: "Normal", Vars: []string{"Solid", "Normal", "Risky"}},
+		"clear hash":  {Name: "Clear Hash", Type: "button"},
+	}
+	if !reflect.DeepEqual(e.Options, want) {
+		t.Errorf("Options = %+v, want %+v", e.Options, want)
+	}
+
+	if err := e.SetOption("skill level", "3"); err != nil {
+		t.Errorf("SetOption() error = %v", err)
+	}
+	if err := e.SetOption("Clear Hash", ""); err != nil {
+		t.Errorf("SetOption() error = %v", err)
+	}
+	if err := e.SetOption("Ponder", "true"); err == nil {
+		t.Error("Expected an option the engine does not offer to be rejected")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := e.NewGame(ctx); err != nil {
+		t.Errorf("NewGame() error = %v", err)
+	}
+	if err := e.IsReady(ctx); err != nil {
+		t.Errorf("IsReady() error = %v", err)
+	}
+}
+
+func TestUCIEngineSearch(t *testing.T) {
+	e := startFakeEngine(t)
+	g := NewGame("alice", "bob")
+	playMoves(t, g, "e2e4", "e7e5")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	depths := []int{}
+	result, err := e.Search(ctx, g, SearchLimits{Depth: 2}, func(info SearchResult) {
+		depths = append(depths, info.Depth)
+	})
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if got := uciMove(result.Move); got != "g1f3" {
+		t.Errorf("Search() played %s, want g1f3", got)
+	}
+	if !reflect.DeepEqual(depths, []int{1, 2}) {
+		t.Errorf("Expected info for depths 1 and 2, got %v", depths)
+	}
+	if result.Score != mateScore-5 || result.Nodes != 250 || result.Time != 2*time.Millisecond {
+		t.Errorf("Expected mate in 3 after 250 nodes in 2ms, got %+v", result)
+	}
+	if len(result.PV) != 1 || result.PV[0] != result.Move {
+		t.Errorf("Expected the move as the line, got %v", result.PV)
+	}
+
+	// The game's position is not touched
+	if len(g.History) != 2 {
+		t.Errorf("Expected the game to keep its 2 moves, got %d", len(g.History))
+	}
+}
+
+func TestUCIEngineCancel(t *testing.T) {
+	e := startFakeEngine(t)
+	g := NewGame("alice", "bob")
+
+	// Without limits the engine searches until the context is cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := e.Search(ctx, g, SearchLimits{}, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Search() error = %v, want the context's error", err)
+	}
+
+	// or until told to stop, answering with its move
+	stop := make(chan struct{})
+	time.AfterFunc(50*time.Millisecond, func() { close(stop) })
+	result, err := e.Search(context.Background(), g, SearchLimits{Stop: stop}, nil)
+	if err != nil || uciMove(result.Move) != "e2e4" {
+		t.Errorf("Search() = %v, %v, want e2e4", uciMove(result.Move), err)
+	}
+
+	// The next search is not confused by the cancelled one
+	result, err = e.Search(context.Background(), g, SearchLimits{Depth: 1}, nil)
+	if err != nil || uciMove(result.Move) != "e2e4" {
+		t.Errorf("Search() = %v, %v, want e2e4", uciMove(result.Move), err)
+	}
+
+	if err := e.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if _, err := e.Search(context.Background(), g, SearchLimits{Depth: 1}, nil); err == nil {
+		t.Error("Expected searching with a closed engine to fail")
+	}
+}
+
+func TestUCIPositionCommand(t *testing.T) {
+	g := NewGame("alice", "bob")
+	if got := uciPositionCommand(g); got != "position startpos" {
+		t.Errorf("uciPositionCommand() = %q, want the starting position", got)
+	}
+	playMoves(t, g, "e2e4", "c7c5", "g1f3")
+	if got, want := uciPositionCommand(g), "position startpos moves e2e4 c7c5 g1f3"; got != want {
+		t.Errorf("uciPositionCommand() = %q, want %q", got, want)
+	}
+
+	fen := "8/P7/8/8/8/8/8/k6K w - - 0 40"
+	g, err := ParseFEN(fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	move, err := parseUCIMove(g, "a7a8n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.applyMove(move)
+	if got, want := uciPositionCommand(g), "position fen "+fen+" moves a7a8n"; got != want {
+		t.Errorf("uciPositionCommand() = %q, want %q", got, want)
+	}
+}
+
+func TestUCIGoCommand(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits SearchLimits
+		want   string
+	}{
+		{"Depth", SearchLimits{Depth: 4}, "go depth 4"},
+		{"All limits", SearchLimits{Depth: 6, Nodes: 1000, Time: 2 * time.Second}, "go depth 6 nodes 1000 movetime 2000"},
+		{"No limits", SearchLimits{}, "go infinite"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := uciGoCommand(tt.limits); got != tt.want {
+				t.Errorf("uciGoCommand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseUCIInfo(t *testing.T) {
+	g := NewGame("alice", "bob")
+	tests := []struct {
+		name  string
+		line  []string
+		score int
+		pv    int
+		ok    bool
+	}{
+		{"Centipawns", []string{"depth", "3", "score", "cp", "-35", "nodes", "900", "pv", "d2d4", "d7d5"}, -35, 2, true},
+		{"Mated", []string{"depth", "5", "score", "mate", "-2", "pv", "f2f3"}, -mateScore + 4, 1, true},
+		{"Bound", []string{"depth", "4", "score", "cp", "12", "lowerbound", "pv", "e2e4"}, 12, 1, true},
+		{"Illegal moves end the line", []string{"depth", "2", "score", "cp", "5", "pv", "e2e4", "e2e4"}, 5, 1, true},
+		{"String", []string{"string", "depth", "3", "score", "cp", "1", "pv", "e2e4"}, 0, 0, false},
+		{"Current move", []string{"depth", "7", "currmove", "e2e4", "currmovenumber", "1"}, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, ok := parseUCIInfo(g, tt.line)
+			if ok != tt.ok {
+				t.Fatalf("parseUCIInfo() ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && (result.Score != tt.score || len(result.PV) != tt.pv) {
+				t.Errorf("parseUCIInfo() = score %d with %d moves, want %d with %d", result.Score, len(result.PV), tt.score, tt.pv)
+			}
+		})
+	}
+}
@@ -1,15 +1,67 @@
-package main
+package gochess
 
 import (
+	"errors"
 	"html/template"
+	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
-// Singe global game for now
-var game *Game
+// Games are kept in memory while they are being played and saved to the
+// store after every change
+var (
+	store   GameStore
+	gamesMu sync.Mutex
+	games   = map[string]*Game{}
+
+	users    UserStore
+	sessions = NewSessionManager()
+	rater    *Rater
+	lobby    = NewLobby(createGame)
+	hub      = NewGameHub()
+	chat     = NewChat(5, 10*time.Second, MaxLengthFilter(500))
+
+	tournaments = NewTournamentDirector(NewMemoryTournamentStore())
+	arenas      = NewArenaDirector(NewMemoryArenaStore())
+	reviewer    = NewReviewer(analysisEngine, SearchLimits{Depth: DefaultSearchDepth})
+
+	puzzleTrainer = NewPuzzleTrainer(nil, NewMemoryPuzzleStore(), Elo{K: 20})
+)
+
+// ServerConfig holds the stores and settings the server is started with
+type ServerConfig struct {
+	Games        GameStore
+	Users        UserStore
+	Rater        *Rater
+	RatingPeriod time.Duration
+	Chat         *Chat
+	// Engines are external UCI engines to offer as opponents, by name
+	Engines map[string]*UCIEngine
+	// Puzzles serves the puzzle set, if one was loaded
+	Puzzles     *PuzzleTrainer
+	Tournaments *TournamentDirector
+	Arenas      *ArenaDirector
+	// Engine plays as the computer opponent, NewEngine if nil
+	Engine *Engine
+}
+
+// gamePage is what the game templates are rendered with
+type gamePage struct {
+	*Game
+	Username       string
+	BoardURL       string
+	Spectators     int
+	PlayersChat    []ChatMessage
+	SpectatorsChat []ChatMessage
+	// Arena is the arena the game is played in, if any
+	Arena string
+}
 
 func until(count int) (slice []int) {
 	for i := 0; i < count; i++ {
@@ -18,15 +70,369 @@ func until(count int) (slice []int) {
 	return
 }
 
-func gameHandler(w http.ResponseWriter, r *http.Request) {
-	tmpl := template.Must(template.New("").Funcs(template.FuncMap{
+func parseTemplates() *template.Template {
+	return template.Must(template.New("").Funcs(template.FuncMap{
 		"until": until,
 		"mod":   func(i, j int) int { return i % j },
 		"add":   func(i, j int) int { return i + j },
 		"sub":   func(i, j int) int { return i - j },
 		"split": func(s string) []string { return strings.Split(s, "") },
 	}).ParseFiles("chess.html"))
-	err := tmpl.ExecuteTemplate(w, "body", game)
+}
+
+// watchGame registers the server wide listeners on a game.
+func watchGame(g *Game) {
+	if rater != nil {
+		g.AddListener(rater)
+	}
+	g.AddListener(tournaments)
+	g.AddListener(arenas)
+	g.AddListener(reviewer)
+}
+
+// loadGames reloads the games that were still being played when the server stopped.
+func loadGames() error {
+	stored, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+	for _, g := range stored {
+		if g.State == Ongoing || g.State == PromoteWhite || g.State == PromoteBlack {
+			if err := restoreMutes(g); err != nil {
+				return err
+			}
+			watchGame(g)
+			games[g.ID] = g
+			// The computer may have been thinking when the server stopped
+			go playComputerMove(g.ID)
+		}
+	}
+	return nil
+}
+
+// getGame looks up a game by ID, falling back to the store for finished games.
+// The caller must hold gamesMu.
+func getGame(id string) (*Game, error) {
+	if g, ok := games[id]; ok {
+		return g, nil
+	}
+	g, err := store.Load(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := restoreMutes(g); err != nil {
+		return nil, err
+	}
+	watchGame(g)
+	games[id] = g
+	return g, nil
+}
+
+func lookupGame(w http.ResponseWriter, r *http.Request) *Game {
+	g, err := getGame(mux.Vars(r)["id"])
+	if errors.Is(err, ErrGameNotFound) {
+		http.NotFound(w, r)
+		return nil
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil
+	}
+	return g
+}
+
+func indexHandler(w http.ResponseWriter, r *http.Request) {
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+
+	ongoing := []*Game{}
+	for _, g := range games {
+		if g.State == Ongoing || g.State == PromoteWhite || g.State == PromoteBlack {
+			ongoing = append(ongoing, g)
+		}
+	}
+
+	err := parseTemplates().ExecuteTemplate(w, "games", struct {
+		Username string
+		Games    []*Game
+		Engines  []string
+	}{sessions.Username(r), ongoing, externalEngineNames()})
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// saveGame stores the game together with the events that changed it.
+// The caller must hold gamesMu.
+func saveGame(g *Game, events ...GameEvent) error {
+	if g.Clock != nil && len(events) > 0 {
+		events = append(events, ClockTickEvent(g))
+	}
+	if g.Correspondence != nil && len(events) > 0 && events[0].Type != EventCreated {
+		events = append(events, DeadlineEvent(g))
+	}
+	if err := store.AppendEvents(g.ID, events...); err != nil {
+		return err
+	}
+	if err := store.Save(g); err != nil {
+		return err
+	}
+	hub.GameChanged(g)
+	return nil
+}
+
+// flagIfOutOfTime ends the game if the player on turn has run out of time.
+// The caller must hold gamesMu.
+func flagIfOutOfTime(g *Game) (bool, error) {
+	now := timeNow()
+	if !g.OutOfTime(now) {
+		return false, nil
+	}
+	color := g.GetCurrentPlayerColor()
+	if err := g.TimeOut(color); err != nil {
+		return true, err
+	}
+	return true, saveGame(g, GameEvent{Type: EventTimedOut, Time: now, Color: color})
+}
+
+// flagGames ends the games whose player on turn has run out of time, without
+// waiting for them to try to move.
+func flagGames() {
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+
+	for _, g := range games {
+		if _, err := flagIfOutOfTime(g); err != nil {
+			log.Println("Failed to end game on time", g.ID, err)
+		}
+	}
+}
+
+// requireUser returns the logged in user, or answers with 401 if there is none.
+func requireUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	username := sessions.Username(r)
+	if username == "" {
+		http.Error(w, "you need to log in", http.StatusUnauthorized)
+		return "", false
+	}
+	return username, true
+}
+
+// requirePlayer checks that the logged in user plays in the game and returns
+// the color they play.
+func requirePlayer(w http.ResponseWriter, r *http.Request, g *Game) (PieceColor, bool) {
+	username, ok := requireUser(w, r)
+	if !ok {
+		return "", false
+	}
+	color, ok := g.PlayerColor(username)
+	if !ok {
+		http.Error(w, "you are not playing in this game", http.StatusForbidden)
+		return "", false
+	}
+	return color, true
+}
+
+func newGameHandler(w http.ResponseWriter, r *http.Request) {
+	username := sessions.Username(r)
+	if username == "" {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Without an opponent the black seat stays open for anyone to join
+	opponent := r.FormValue("opponent")
+	if opponent != "" {
+		if _, err := users.GetUser(opponent); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if opponent == username {
+			http.Error(w, "you cannot play against yourself", http.StatusBadRequest)
+			return
+		}
+	}
+
+	g := NewGame(username, opponent)
+
+	// Against the computer the engine takes the other seat, searching as deep
+	// as the chosen level
+	if r.FormValue("computer") != "" {
+		level, err := strconv.Atoi(r.FormValue("level"))
+		if err != nil || level < 1 || level > 6 {
+			http.Error(w, "invalid level: "+r.FormValue("level"), http.StatusBadRequest)
+			return
+		}
+		limits := SearchLimits{Depth: level, Time: 10 * time.Second}
+		name, engineName := computerName, r.FormValue("engine")
+		if engineName != "" {
+			if _, ok := externalEngines[engineName]; !ok {
+				http.Error(w, "unknown engine: "+engineName, http.StatusBadRequest)
+				return
+			}
+			name = engineSeatName(engineName)
+		}
+		g = NewGame(username, name)
+		g.Computer = &ComputerOpponent{Color: Black, Limits: limits, Engine: engineName}
+		if r.FormValue("color") == string(Black) {
+			g = NewGame(name, username)
+			g.Computer = &ComputerOpponent{Color: White, Limits: limits, Engine: engineName}
+		}
+		// Games against the computer are never rated
+		g.Casual = true
+	}
+
+	// Broadcasts can hold back the moves shown to spectators
+	if delay := r.FormValue("delay_minutes"); delay != "" {
+		minutes, err := strconv.Atoi(delay)
+		if err != nil || minutes < 0 {
+			http.Error(w, "invalid delay: "+delay, http.StatusBadRequest)
+			return
+		}
+		g.SpectatorDelay = time.Duration(minutes) * time.Minute
+	}
+
+	// Correspondence games give days for every move instead of a clock
+	if daysPerMove := r.FormValue("days_per_move"); daysPerMove != "" {
+		days, err := strconv.Atoi(daysPerMove)
+		if err != nil || days < 1 {
+			http.Error(w, "invalid days per move: "+daysPerMove, http.StatusBadRequest)
+			return
+		}
+		vacation := 0
+		if vacationDays := r.FormValue("vacation_days"); vacationDays != "" {
+			vacation, err = strconv.Atoi(vacationDays)
+			if err != nil || vacation < 0 {
+				http.Error(w, "invalid vacation days: "+vacationDays, http.StatusBadRequest)
+				return
+			}
+		}
+		g.SetCorrespondence(NewCorrespondence(days, vacation))
+	}
+
+	// The game can open a series of games between the same players
+	bestOf, firstTo := r.FormValue("best_of"), r.FormValue("first_to")
+	if bestOf != "" || firstTo != "" {
+		n, err := strconv.Atoi(bestOf)
+		if bestOf != "" && (err != nil || n < 1) {
+			http.Error(w, "invalid best of: "+bestOf, http.StatusBadRequest)
+			return
+		}
+		points, err := strconv.ParseFloat(firstTo, 64)
+		if firstTo != "" && (err != nil || points <= 0) {
+			http.Error(w, "invalid first to: "+firstTo, http.StatusBadRequest)
+			return
+		}
+		g.Series = NewSeries(n, points)
+	}
+
+	if err := startGame(g); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	go playComputerMove(g.ID)
+
+	http.Redirect(w, r, "/games/"+g.ID, http.StatusSeeOther)
+}
+
+// createGame starts a new game between two players.
+func createGame(white, black string, tc TimeControl, rated bool) (*Game, error) {
+	g := NewGame(white, black)
+	g.Casual = !rated
+	if tc != (TimeControl{}) {
+		g.SetClock(NewClock(tc.Initial, tc.Increment))
+	}
+	if err := startGame(g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// startGame saves a newly set up game and starts serving it.
+func startGame(g *Game) error {
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+	return addGame(g)
+}
+
+// addGame saves a newly set up game and starts serving it. The caller must
+// hold gamesMu.
+func addGame(g *Game) error {
+	if err := saveGame(g, CreatedEvent(g)); err != nil {
+		return err
+	}
+	watchGame(g)
+	games[g.ID] = g
+	return nil
+}
+
+func joinHandler(w http.ResponseWriter, r *http.Request) {
+	username := sessions.Username(r)
+	if username == "" {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+
+	game := lookupGame(w, r)
+	if game == nil {
+		return
+	}
+
+	if _, ok := game.PlayerColor(username); !ok {
+		if game.Players[1].Name != "" {
+			http.Error(w, "game is already full", http.StatusConflict)
+			return
+		}
+		game.Players[1].Name = username
+		if err := saveGame(game, GameEvent{Type: EventJoined, Time: timeNow(), Players: game.Players}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	http.Redirect(w, r, "/games/"+game.ID, http.StatusSeeOther)
+}
+
+func gameHandler(w http.ResponseWriter, r *http.Request) {
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+
+	game := lookupGame(w, r)
+	if game == nil {
+		return
+	}
+
+	// Anyone else than the players watches the game from the spectator view,
+	// unless there is a seat left to join
+	username := sessions.Username(r)
+	if _, ok := game.PlayerColor(username); !ok && game.Players[1].Name != "" {
+		http.Redirect(w, r, "/watch/"+game.ID, http.StatusSeeOther)
+		return
+	}
+
+	events, err := store.Events(game.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	arena, _ := arenas.GameArena(game.ID)
+	err = parseTemplates().ExecuteTemplate(w, "body", gamePage{
+		Game:        game,
+		Username:    username,
+		BoardURL:    "/games/" + game.ID + "/board",
+		Spectators:  hub.SpectatorCount(game.ID),
+		PlayersChat: ChatMessages(events, PlayersRoom, time.Time{}),
+		Arena:       arena,
+	})
 
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -34,15 +440,28 @@ func gameHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// boardHandler renders the board of a game, live for its players and as
+// spectators see it for anyone else.
 func boardHandler(w http.ResponseWriter, r *http.Request) {
-	tmpl := template.Must(template.New("").Funcs(template.FuncMap{
-		"until": until,
-		"mod":   func(i, j int) int { return i % j },
-		"add":   func(i, j int) int { return i + j },
-		"sub":   func(i, j int) int { return i - j },
-		"split": func(s string) []string { return strings.Split(s, "") },
-	}).ParseFiles("chess.html"))
-	err := tmpl.ExecuteTemplate(w, "board", game.Board) // Only return the board component
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+
+	game := lookupGame(w, r)
+	if game == nil {
+		return
+	}
+
+	view := game
+	if _, ok := game.PlayerColor(sessions.Username(r)); !ok {
+		var err error
+		if view, err = spectatorView(game); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	page := gamePage{Game: view, BoardURL: "/games/" + game.ID + "/board"}
+	err := parseTemplates().ExecuteTemplate(w, "board", page) // Only return the board component
 
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -57,8 +476,12 @@ func moveHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// FormValue move
-	move := r.FormValue("move")
+	// FormValue move, with the piece a pawn is promoted to, like e7e8q
+	move, promotion, err := splitPromotion(r.FormValue("move"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	source, target, err := notationToCoordinates(move)
 
@@ -67,6 +490,48 @@ func moveHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+
+	game := lookupGame(w, r)
+	if game == nil {
+		return
+	}
+
+	color, ok := requirePlayer(w, r, game)
+	if !ok {
+		return
+	}
+	if color != game.GetCurrentPlayerColor() {
+		http.Error(w, "it is not your turn", http.StatusForbidden)
+		return
+	}
+	if flagged, err := flagIfOutOfTime(game); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	} else if flagged {
+		http.Error(w, "your time has run out", http.StatusConflict)
+		return
+	}
+	if forfeited, err := forfeitIfExpired(game); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	} else if forfeited {
+		http.Error(w, "the deadline for your move has passed", http.StatusConflict)
+		return
+	}
+
+	// A pawn reaching the last rank becomes a queen unless told otherwise
+	piece := game.Board[source[0]][source[1]]
+	promotes := piece != nil && piece.Type == Pawn && (target[1] == 0 || target[1] == 7)
+	if promotion != "" && !promotes {
+		http.Error(w, "only a pawn reaching the last rank is promoted", http.StatusBadRequest)
+		return
+	}
+	if promotes && promotion == "" {
+		promotion = Queen
+	}
+
 	err = game.MovePiece(source[0], source[1], target[0], target[1])
 
 	if err != nil {
@@ -74,18 +539,284 @@ func moveHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if promotes {
+		if err := game.PromotePawn(promotion); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := saveGame(game, MovedEvent(game)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := playConditional(game); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	go playComputerMove(game.ID)
 }
 
-func startServer() {
-	// Create single global game for now
-	game = NewGame("Player 1", "Player 2")
+// undoHandler asks the opponent to take back the last move, or takes it back
+// when they have asked for it.
+func undoHandler(w http.ResponseWriter, r *http.Request) {
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
 
+	game := lookupGame(w, r)
+	if game == nil {
+		return
+	}
+
+	if _, ok := requirePlayer(w, r, game); !ok {
+		return
+	}
+	if game.IsOver() || len(game.History) == 0 {
+		http.Error(w, "there is no move to take back", http.StatusBadRequest)
+		return
+	}
+
+	// The opponent has to agree to take the move back, except the computer,
+	// which always does
+	if game.Computer == nil && !offerOrAccept(takebackOffers, game, sessions.Username(r), "asks to take back the last move") {
+		return
+	}
+
+	if err := game.Undo(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := saveGame(game, GameEvent{Type: EventUndone, Time: timeNow()}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	go playComputerMove(game.ID)
+}
+
+func resignHandler(w http.ResponseWriter, r *http.Request) {
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+
+	game := lookupGame(w, r)
+	if game == nil {
+		return
+	}
+
+	color, ok := requirePlayer(w, r, game)
+	if !ok {
+		return
+	}
+
+	if err := game.Resign(color); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := saveGame(game, GameEvent{Type: EventResigned, Time: timeNow(), Color: color}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// drawHandler offers the opponent a draw, or accepts their offer.
+func drawHandler(w http.ResponseWriter, r *http.Request) {
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+
+	game := lookupGame(w, r)
+	if game == nil {
+		return
+	}
+
+	if _, ok := requirePlayer(w, r, game); !ok {
+		return
+	}
+	if game.IsOver() {
+		http.Error(w, "Game is already over, got state: "+string(game.State), http.StatusBadRequest)
+		return
+	}
+
+	// The opponent has to accept the draw before their next move, except
+	// the computer, which always does
+	if game.Computer == nil && !offerOrAccept(drawOffers, game, sessions.Username(r), "offers a draw") {
+		return
+	}
+
+	if err := game.AgreeDraw(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := saveGame(game, GameEvent{Type: EventDrawAgreed, Time: timeNow()}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// replayHandler renders the board as it stood after the given number of
+// half-moves, rebuilt from the event log. Anyone but the players only gets
+// the moves spectators have seen.
+func replayHandler(w http.ResponseWriter, r *http.Request) {
+	ply, err := strconv.Atoi(mux.Vars(r)["ply"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+
+	game := lookupGame(w, r)
+	if game == nil {
+		return
+	}
+
+	var events []GameEvent
+	if _, ok := game.PlayerColor(sessions.Username(r)); ok {
+		events, err = store.Events(game.ID)
+	} else {
+		events, err = spectatorEvents(game)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	replayed, err := GameLog(events).ReplayTo(ply)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = parseTemplates().ExecuteTemplate(w, "board", gamePage{Game: replayed})
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func newRouter() *mux.Router {
 	r := mux.NewRouter()
-	r.HandleFunc("/", gameHandler)
-	r.HandleFunc("/move", moveHandler).Methods("POST")
-	r.HandleFunc("/board", boardHandler).Methods("GET") // Add this line
+	r.HandleFunc("/", indexHandler).Methods("GET")
+	r.HandleFunc("/login", loginPageHandler).Methods("GET")
+	r.HandleFunc("/login", loginHandler).Methods("POST")
+	r.HandleFunc("/register", registerHandler).Methods("POST")
+	r.HandleFunc("/guest", guestHandler).Methods("POST")
+	r.HandleFunc("/logout", logoutHandler).Methods("POST")
+	r.HandleFunc("/games", newGameHandler).Methods("POST")
+	r.HandleFunc("/games/{id}", gameHandler).Methods("GET")
+	r.HandleFunc("/games/{id}/join", joinHandler).Methods("POST")
+	r.HandleFunc("/games/{id}/move", moveHandler).Methods("POST")
+	r.HandleFunc("/games/{id}/board", boardHandler).Methods("GET")
+	r.HandleFunc("/games/{id}/undo", undoHandler).Methods("POST")
+	r.HandleFunc("/games/{id}/resign", resignHandler).Methods("POST")
+	r.HandleFunc("/games/{id}/draw", drawHandler).Methods("POST")
+	r.HandleFunc("/games/{id}/rematch", rematchHandler).Methods("POST")
+	r.HandleFunc("/games/{id}/vacation", vacationHandler).Methods("POST")
+	r.HandleFunc("/games/{id}/conditionals", conditionalsHandler).Methods("POST")
+	r.HandleFunc("/awaiting", awaitingHandler).Methods("GET")
+	r.HandleFunc("/games/{id}/replay/{ply}", replayHandler).Methods("GET")
+	r.HandleFunc("/games/{id}/book", bookHandler).Methods("GET")
+	r.HandleFunc("/games/{id}/review", reviewHandler).Methods("GET")
+	r.HandleFunc("/games/{id}/review/moves", reviewMovesHandler).Methods("GET")
+	r.HandleFunc("/games/{id}/review.pgn", reviewPGNHandler).Methods("GET")
+	r.HandleFunc("/analysis", analysisHandler).Methods("GET")
+	r.HandleFunc("/analysis/events", analysisEventsHandler).Methods("GET")
+	r.HandleFunc("/puzzles", puzzlesHandler).Methods("GET")
+	r.HandleFunc("/puzzles/next", nextPuzzleHandler).Methods("POST")
+	r.HandleFunc("/puzzles/move", puzzleMoveHandler).Methods("POST")
+	r.HandleFunc("/games/{id}/events", gameEventsHandler).Methods("GET")
+	r.HandleFunc("/watch/{id}", watchHandler).Methods("GET")
+	r.HandleFunc("/watch/{id}/board", watchBoardHandler).Methods("GET")
+	r.HandleFunc("/watch/{id}/events", watchEventsHandler).Methods("GET")
+	r.HandleFunc("/games/{id}/chat", chatHandler).Methods("POST")
+	r.HandleFunc("/games/{id}/mute", muteHandler).Methods("POST")
+	r.HandleFunc("/games/{id}/unmute", unmuteHandler).Methods("POST")
+	r.HandleFunc("/watch/{id}/chat", spectatorChatHandler).Methods("POST")
+	r.HandleFunc("/lobby", lobbyHandler).Methods("GET")
+	r.HandleFunc("/lobby/seeks", lobbySeeksHandler).Methods("GET")
+	r.HandleFunc("/lobby/seeks", postSeekHandler).Methods("POST")
+	r.HandleFunc("/lobby/seeks/{id}/accept", acceptSeekHandler).Methods("POST")
+	r.HandleFunc("/lobby/seeks/{id}/cancel", cancelSeekHandler).Methods("POST")
+	r.HandleFunc("/lobby/challenges", challengeHandler).Methods("POST")
+	r.HandleFunc("/lobby/challenges/{id}/accept", acceptChallengeHandler).Methods("POST")
+	r.HandleFunc("/lobby/challenges/{id}/decline", declineChallengeHandler).Methods("POST")
+	r.HandleFunc("/tournaments", tournamentsHandler).Methods("GET")
+	r.HandleFunc("/tournaments", createTournamentHandler).Methods("POST")
+	r.HandleFunc("/tournaments/{id}", tournamentHandler).Methods("GET")
+	r.HandleFunc("/tournaments/{id}/join", joinTournamentHandler).Methods("POST")
+	r.HandleFunc("/tournaments/{id}/rounds", startRoundHandler).Methods("POST")
+	r.HandleFunc("/arenas", arenasHandler).Methods("GET")
+	r.HandleFunc("/arenas", createArenaHandler).Methods("POST")
+	r.HandleFunc("/arenas/{id}", arenaHandler).Methods("GET")
+	r.HandleFunc("/arenas/{id}/standings", arenaStandingsHandler).Methods("GET")
+	r.HandleFunc("/arenas/{id}/join", joinArenaHandler).Methods("POST")
+	r.HandleFunc("/arenas/{id}/pause", pauseArenaHandler).Methods("POST")
+	r.HandleFunc("/games/{id}/berserk", berserkHandler).Methods("POST")
+	r.HandleFunc("/leaderboard", leaderboardHandler).Methods("GET")
+	r.HandleFunc("/players/{name}", playerHandler).Methods("GET")
+	return r
+}
+
+// StartServer serves the games on port 8080 until it fails.
+func StartServer(config ServerConfig) {
+	store = config.Games
+	users = config.Users
+	rater = config.Rater
+	chat = config.Chat
+	if config.Engines != nil {
+		externalEngines = config.Engines
+	}
+	if config.Puzzles != nil {
+		puzzleTrainer = config.Puzzles
+	}
+	if config.Tournaments != nil {
+		tournaments = config.Tournaments
+	}
+	if config.Arenas != nil {
+		arenas = config.Arenas
+	}
+	if config.Engine != nil {
+		engine = config.Engine
+	}
+	if err := tournaments.Load(); err != nil {
+		log.Fatal(err)
+	}
+	if err := arenas.Load(); err != nil {
+		log.Fatal(err)
+	}
+	if err := loadGames(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Rating periods that ended while the server was down are closed first
+	closeRatingPeriods := func() {
+		if err := rater.ClosePeriods(config.RatingPeriod); err != nil {
+			log.Println("Failed to close rating period", err)
+		}
+	}
+	closeRatingPeriods()
+	go func() {
+		for range time.Tick(time.Minute) {
+			closeRatingPeriods()
+		}
+	}()
+
+	go func() {
+		for range time.Tick(time.Minute) {
+			forfeitExpiredGames()
+		}
+	}()
+	go func() {
+		for range time.Tick(time.Second) {
+			flagGames()
+		}
+	}()
+	go arenas.Run(createGame, time.Tick(time.Second))
 
 	// TODO render history of moves
-	// TODO handle multiple games
-	http.ListenAndServe(":8080", r)
+	log.Fatal(http.ListenAndServe(":8080", newRouter()))
 }
@@ -0,0 +1,62 @@
+package gochess
+
+import (
+	"errors"
+	"net/http"
+)
+
+func renderLogin(w http.ResponseWriter, status int, message string) {
+	w.WriteHeader(status)
+	err := parseTemplates().ExecuteTemplate(w, "login", message)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func loginPageHandler(w http.ResponseWriter, r *http.Request) {
+	renderLogin(w, http.StatusOK, "")
+}
+
+func loginHandler(w http.ResponseWriter, r *http.Request) {
+	u, err := Authenticate(users, r.FormValue("username"), r.FormValue("password"))
+	if errors.Is(err, ErrInvalidCredentials) {
+		renderLogin(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sessions.Start(w, r, u.Username)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func registerHandler(w http.ResponseWriter, r *http.Request) {
+	u, err := Register(users, r.FormValue("username"), r.FormValue("password"))
+	if err != nil {
+		renderLogin(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sessions.Start(w, r, u.Username)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func guestHandler(w http.ResponseWriter, r *http.Request) {
+	u, err := RegisterGuest(users)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sessions.Start(w, r, u.Username)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	sessions.End(w, r)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,174 @@
+package gochess
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// analysisEngine analyses the positions of the analysis board and reviews
+// finished games. It has no book, so every position is searched.
+var analysisEngine = &Engine{Evaluate: Evaluate, TT: NewTranspositionTable(DefaultHashSize)}
+
+// analysisLimits bound each analysis, which also ends when the browser leaves
+var analysisLimits = SearchLimits{Time: 10 * time.Second}
+
+// defaultAnalysisLines is the number of lines shown unless asked for more
+const defaultAnalysisLines = 3
+
+// analysisPage is the analysis board of a position.
+type analysisPage struct {
+	gamePage
+	FEN   string
+	Lines int
+	// EventsURL streams the analysis of the position
+	EventsURL string
+	Error     string
+}
+
+// analysisLineView is a line of the analysis, scored from White's point of
+// view in pawns like +0.35 or as a mate like #3 or #-2.
+type analysisLineView struct {
+	Score string
+	Moves string
+}
+
+// analysisView is the analysis of a depth, with the height of White's part
+// of the evaluation bar in percent.
+type analysisView struct {
+	Depth        int
+	Nodes        int
+	WhitePercent int
+	Lines        []analysisLineView
+}
+
+// parseAnalysisRequest reads the position, the start position by default,
+// and the number of lines to analyse.
+func parseAnalysisRequest(r *http.Request) (*Game, int, error) {
+	g := NewGame("", "")
+	if fen := strings.TrimSpace(r.FormValue("fen")); fen != "" {
+		var err error
+		if g, err = ParseFEN(fen); err != nil {
+			return nil, 0, err
+		}
+	}
+	lines := defaultAnalysisLines
+	if value := r.FormValue("lines"); value != "" {
+		var err error
+		lines, err = strconv.Atoi(value)
+		if err != nil || lines < 1 || lines > MaxAnalysisLines {
+			return nil, 0, fmt.Errorf("the number of lines must be between 1 and %d", MaxAnalysisLines)
+		}
+	}
+	return g, lines, nil
+}
+
+// analysisHandler renders the analysis board, which streams the analysis of
+// its position from analysisEventsHandler.
+func analysisHandler(w http.ResponseWriter, r *http.Request) {
+	g, lines, err := parseAnalysisRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page := analysisPage{gamePage: gamePage{Game: g}, FEN: g.FEN(), Lines: lines}
+	page.EventsURL = "/analysis/events?" + url.Values{"fen": {page.FEN}, "lines": {strconv.Itoa(lines)}}.Encode()
+	if g.State != Ongoing {
+		page.Error = "The game is over: " + string(g.State)
+	}
+
+	if err := parseTemplates().ExecuteTemplate(w, "analysis", page); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// analysisEventsHandler analyses a position, streaming the evaluation bar and
+// the lines of each depth as "evaluation" and "analysis" events, and ending
+// with a "done" event.
+func analysisEventsHandler(w http.ResponseWriter, r *http.Request) {
+	g, lines, err := parseAnalysisRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher.Flush()
+
+	send := func(name, data string) {
+		fmt.Fprintf(w, "event: %s\n", name)
+		for _, line := range strings.Split(data, "\n") {
+			fmt.Fprintf(w, "data: %s\n", line)
+		}
+		fmt.Fprint(w, "\n")
+		flusher.Flush()
+	}
+	templates := parseTemplates()
+	render := func(name, template string, data interface{}) {
+		var fragment bytes.Buffer
+		if err := templates.ExecuteTemplate(&fragment, template, data); err != nil {
+			send(name, err.Error())
+			return
+		}
+		send(name, fragment.String())
+	}
+
+	limits := analysisLimits
+	limits.Stop = r.Context().Done()
+	_, err = analysisEngine.Analyze(g, limits, lines, func(results []SearchResult) {
+		view := newAnalysisView(g, results)
+		render("evaluation", "evaluationBar", view.WhitePercent)
+		render("analysis", "analysisLines", view)
+	})
+	if err != nil {
+		send("analysis", err.Error())
+	}
+	// The browser would reconnect and start again if the stream just ended
+	send("done", "")
+}
+
+// newAnalysisView shows the lines of a depth searched in the game's position.
+func newAnalysisView(g *Game, results []SearchResult) analysisView {
+	view := analysisView{Depth: results[0].Depth, Nodes: results[0].Nodes}
+	sign := 1
+	if g.GetCurrentPlayerColor() == Black {
+		sign = -1
+	}
+	for i, result := range results {
+		score := sign * result.Score
+		mateIn := sign * result.MateIn()
+		if i == 0 {
+			view.WhitePercent = whitePercent(score, mateIn)
+		}
+		line := analysisLineView{Score: fmt.Sprintf("%+.2f", float64(score)/100), Moves: g.moveText(result.PV)}
+		if mateIn != 0 {
+			line.Score = fmt.Sprintf("#%d", mateIn)
+		}
+		view.Lines = append(view.Lines, line)
+	}
+	return view
+}
+
+// whitePercent fills the evaluation bar with White's chance of winning with
+// the score of the best line from White's point of view.
+func whitePercent(score, mateIn int) int {
+	switch {
+	case mateIn > 0:
+		return 100
+	case mateIn < 0:
+		return 0
+	}
+	return int(math.Round(winChance(score)))
+}
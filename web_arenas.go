@@ -0,0 +1,196 @@
+package gochess
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func arenasHandler(w http.ResponseWriter, r *http.Request) {
+	err := parseTemplates().ExecuteTemplate(w, "arenas", struct {
+		Username string
+		Arenas   []*Arena
+	}{sessions.Username(r), arenas.Arenas()})
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// parseMinutes reads a number of minutes of a form, zero if it is empty.
+func parseMinutes(r *http.Request, field string) (time.Duration, error) {
+	value := r.FormValue(field)
+	if value == "" {
+		return 0, nil
+	}
+	minutes, err := strconv.Atoi(value)
+	if err != nil || minutes < 0 {
+		return 0, errors.New("invalid " + field + ": " + value)
+	}
+	return time.Duration(minutes) * time.Minute, nil
+}
+
+func createArenaHandler(w http.ResponseWriter, r *http.Request) {
+	username, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	tc, err := parseTimeControl(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	startsIn, err := parseMinutes(r, "starts_in")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	duration, err := parseMinutes(r, "duration")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := r.FormValue("name")
+	if name == "" {
+		http.Error(w, "the arena needs a name", http.StatusBadRequest)
+		return
+	}
+
+	a := NewArena(name, username, tc, r.FormValue("rated") != "", timeNow().Add(startsIn), duration)
+	if err := arenas.Create(a); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, "/arenas/"+a.ID, http.StatusSeeOther)
+}
+
+// arenaError answers with the status matching an error of the director.
+func arenaError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, ErrArenaNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
+
+func renderArena(w http.ResponseWriter, r *http.Request, name string) {
+	username := sessions.Username(r)
+	err := arenas.Arena(mux.Vars(r)["id"], func(a *Arena) error {
+		_, joined := a.Players[username]
+		err := parseTemplates().ExecuteTemplate(w, name, struct {
+			*Arena
+			Username  string
+			Joined    bool
+			Running   bool
+			Standings []ArenaPlayer
+		}{a, username, joined, a.IsRunning(timeNow()), a.Standings()})
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return nil
+	})
+	if err != nil {
+		arenaError(w, r, err)
+	}
+}
+
+func arenaHandler(w http.ResponseWriter, r *http.Request) {
+	renderArena(w, r, "arena")
+}
+
+// arenaStandingsHandler renders the standings, which the arena page polls.
+// Players who have been paired are sent to their new game.
+func arenaStandingsHandler(w http.ResponseWriter, r *http.Request) {
+	if username := sessions.Username(r); username != "" {
+		if id := arenas.Matched(username); id != "" {
+			w.Header().Set("HX-Redirect", "/games/"+id)
+		}
+	}
+	renderArena(w, r, "arenaStandings")
+}
+
+func joinArenaHandler(w http.ResponseWriter, r *http.Request) {
+	username, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	ratings, err := rater.Ratings(username)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	err = arenas.Update(id, func(a *Arena) error {
+		return a.Join(username, ratings.Elo.Rating, timeNow())
+	})
+	if err != nil {
+		arenaError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/arenas/"+id, http.StatusSeeOther)
+}
+
+func pauseArenaHandler(w http.ResponseWriter, r *http.Request) {
+	username, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	err := arenas.Update(id, func(a *Arena) error {
+		return a.Pause(username)
+	})
+	if err != nil {
+		arenaError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/arenas/"+id, http.StatusSeeOther)
+}
+
+// berserkHandler halves the clock of a player before their first move of an
+// arena game, for an extra point if they win.
+func berserkHandler(w http.ResponseWriter, r *http.Request) {
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+
+	game := lookupGame(w, r)
+	if game == nil {
+		return
+	}
+	color, ok := requirePlayer(w, r, game)
+	if !ok {
+		return
+	}
+	if game.Clock == nil {
+		http.Error(w, "only games with a clock can go berserk", http.StatusBadRequest)
+		return
+	}
+	for _, move := range game.History {
+		if move.Color == color {
+			http.Error(w, "you can only go berserk before your first move", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := arenas.Berserk(game.ID, color); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if color == White {
+		game.Clock.White = game.Clock.Initial / 2
+	} else {
+		game.Clock.Black = game.Clock.Initial / 2
+	}
+	if err := saveGame(game, ClockTickEvent(game)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,53 @@
+package gochess
+
+import "net/http"
+
+// bookMoveView is a book move shown on the game page, with its share of the
+// weight of the position's book moves.
+type bookMoveView struct {
+	SAN     string
+	Percent int
+}
+
+// bookHandler renders the book moves of the game's current position, or of
+// the position spectators see for anyone but the players.
+func bookHandler(w http.ResponseWriter, r *http.Request) {
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+
+	game := lookupGame(w, r)
+	if game == nil {
+		return
+	}
+
+	view := game
+	if _, ok := game.PlayerColor(sessions.Username(r)); !ok {
+		var err error
+		if view, err = spectatorView(game); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	moves := []bookMoveView{}
+	if engine.Book != nil && view.State == Ongoing {
+		bookMoves := engine.Book.Moves(view)
+		total := 0
+		for _, move := range bookMoves {
+			total += move.Weight
+		}
+		for _, move := range bookMoves {
+			moves = append(moves, bookMoveView{SAN: view.SAN(move.Move), Percent: 100 * move.Weight / total})
+		}
+	}
+
+	err := parseTemplates().ExecuteTemplate(w, "bookMoves", struct {
+		ID      string
+		HasBook bool
+		Moves   []bookMoveView
+	}{game.ID, engine.Book != nil, moves})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
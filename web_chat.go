@@ -0,0 +1,127 @@
+package gochess
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+)
+
+// postChat moderates, stores and delivers a chat message. Messages in the
+// players room reach spectators after the spectator delay, messages in the
+// spectators room never reach the players.
+// The caller must hold gamesMu.
+func postChat(w http.ResponseWriter, g *Game, msg ChatMessage) {
+	if err := chat.Moderate(g.ID, &msg); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrMuted) {
+			status = http.StatusForbidden
+		} else if errors.Is(err, ErrRateLimited) {
+			status = http.StatusTooManyRequests
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if err := store.AppendEvents(g.ID, GameEvent{Type: EventChat, Time: msg.Time, Chat: &msg}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var html bytes.Buffer
+	if err := parseTemplates().ExecuteTemplate(&html, "chatMessage", msg); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	event := hubEvent{Name: "chat-" + string(msg.Room), Data: html.String()}
+	hub.Publish(g, event, msg.Room == PlayersRoom, true)
+}
+
+func chatHandler(w http.ResponseWriter, r *http.Request) {
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+
+	game := lookupGame(w, r)
+	if game == nil {
+		return
+	}
+	if _, ok := requirePlayer(w, r, game); !ok {
+		return
+	}
+
+	postChat(w, game, ChatMessage{Room: PlayersRoom, Username: sessions.Username(r), Text: r.FormValue("text")})
+}
+
+func spectatorChatHandler(w http.ResponseWriter, r *http.Request) {
+	username, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+
+	game := lookupGame(w, r)
+	if game == nil {
+		return
+	}
+	if _, ok := game.PlayerColor(username); ok {
+		http.Error(w, "players cannot post in the spectator chat", http.StatusForbidden)
+		return
+	}
+
+	postChat(w, game, ChatMessage{Room: SpectatorsRoom, Username: username, Text: r.FormValue("text")})
+}
+
+// muteHandler lets a player silence someone in the chats of their game.
+func muteHandler(w http.ResponseWriter, r *http.Request) {
+	setMute(w, r, EventMuted)
+}
+
+// unmuteHandler lifts a mute, which only the player who set it can do.
+func unmuteHandler(w http.ResponseWriter, r *http.Request) {
+	setMute(w, r, EventUnmuted)
+}
+
+// setMute mutes or unmutes a user and records it in the event log of the game.
+func setMute(w http.ResponseWriter, r *http.Request, eventType GameEventType) {
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+
+	game := lookupGame(w, r)
+	if game == nil {
+		return
+	}
+	if _, ok := requirePlayer(w, r, game); !ok {
+		return
+	}
+
+	mute := ChatMute{Username: r.FormValue("username"), By: sessions.Username(r)}
+	change, undo := chat.Mute, chat.Unmute
+	if eventType == EventUnmuted {
+		change, undo = chat.Unmute, chat.Mute
+	}
+	if err := change(game.ID, mute); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrNotMuter) {
+			status = http.StatusForbidden
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if err := store.AppendEvents(game.ID, GameEvent{Type: eventType, Time: timeNow(), Mute: &mute}); err != nil {
+		undo(game.ID, mute)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// restoreMutes reloads the chat mutes of a game from its event log.
+func restoreMutes(g *Game) error {
+	events, err := store.Events(g.ID)
+	if err != nil {
+		return err
+	}
+	chat.RestoreMutes(g.ID, events)
+	return nil
+}
@@ -0,0 +1,82 @@
+package gochess
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+)
+
+// engine plays the computer's side of games against the computer
+var engine = NewEngine()
+
+// externalEngines are the UCI engines the server was started with, by name.
+// They are offered as opponents next to the built-in engine.
+var externalEngines = map[string]*UCIEngine{}
+
+// externalEngineNames returns the names of the external engines, sorted.
+func externalEngineNames() []string {
+	names := []string{}
+	for name := range externalEngines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// playComputerMove lets the engine reply in a game against the computer when
+// it is the computer's turn. The search runs without holding gamesMu, and its
+// move is dropped if the game has changed in the meantime.
+func playComputerMove(id string) {
+	gamesMu.Lock()
+	g, ok := games[id]
+	if !ok || g.Computer == nil || g.State != Ongoing || g.GetCurrentPlayerColor() != g.Computer.Color {
+		gamesMu.Unlock()
+		return
+	}
+	position := g.searchCopy()
+	limits := g.Computer.Limits
+	external, ok := externalEngines[g.Computer.Engine]
+	if g.Computer.Engine != "" && !ok {
+		gamesMu.Unlock()
+		log.Println("Computer failed to find a move in game", id, errors.New("engine "+g.Computer.Engine+" is not available"))
+		return
+	}
+	gamesMu.Unlock()
+
+	var result SearchResult
+	var err error
+	if external != nil {
+		// The engine is given a little more than its time to answer
+		ctx, cancel := context.WithTimeout(context.Background(), limits.Time+stopTimeout)
+		result, err = external.Search(ctx, position, limits, nil)
+		cancel()
+	} else {
+		result, err = engine.Search(position, limits)
+	}
+	if err != nil {
+		log.Println("Computer failed to find a move in game", id, err)
+		return
+	}
+
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+
+	if len(g.History) != len(position.History) || (len(g.History) > 0 && g.History[len(g.History)-1] != position.History[len(position.History)-1]) {
+		return
+	}
+	move := result.Move
+	if err := g.MovePiece(move.From.X, move.From.Y, move.To.X, move.To.Y); err != nil {
+		log.Println("Computer played an invalid move in game", id, err)
+		return
+	}
+	if move.Promotion != "" {
+		if err := g.PromotePawn(move.Promotion); err != nil {
+			log.Println("Computer failed to promote in game", id, err)
+			return
+		}
+	}
+	if err := saveGame(g, MovedEvent(g)); err != nil {
+		log.Println("Failed to save the computer's move in game", id, err)
+	}
+}
@@ -0,0 +1,183 @@
+package gochess
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// playConditional plays the premoves of the players in reply to the last
+// move, for as long as each move is answered by a conditional line. The
+// caller must hold gamesMu.
+func playConditional(g *Game) error {
+	for g.Correspondence != nil && g.State == Ongoing && len(g.History) > 0 {
+		last := g.History[len(g.History)-1]
+		color := g.GetCurrentPlayerColor()
+		pending := len(g.Correspondence.Conditionals[color]) > 0
+		reply, ok := g.Correspondence.Respond(color, uciMove(last))
+		if !ok {
+			if pending {
+				// Record that the lines which did not follow the game are gone
+				return saveGame(g, DeadlineEvent(g))
+			}
+			return nil
+		}
+
+		move, promotion, err := splitPromotion(reply)
+		var source, target [2]int
+		if err == nil {
+			source, target, err = notationToCoordinates(move)
+		}
+		if err == nil {
+			err = g.MovePiece(source[0], source[1], target[0], target[1])
+		}
+		if err == nil && promotion != "" {
+			err = g.PromotePawn(promotion)
+		}
+		if err != nil {
+			// A premove that is no longer legal is dropped with the rest of its lines
+			g.Correspondence.Conditionals[color] = nil
+			return saveGame(g, DeadlineEvent(g))
+		}
+		if err := saveGame(g, MovedEvent(g)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forfeitIfExpired ends the correspondence game if the player on turn has
+// missed their deadline, and reports whether it did. The caller must hold
+// gamesMu.
+func forfeitIfExpired(g *Game) (bool, error) {
+	if g.Correspondence == nil || g.State != Ongoing {
+		return false, nil
+	}
+	now := timeNow()
+	color := g.GetCurrentPlayerColor()
+	if !g.Correspondence.Expired(color, now) {
+		return false, nil
+	}
+	if err := g.Forfeit(color); err != nil {
+		return true, err
+	}
+	return true, saveGame(g, GameEvent{Type: EventForfeited, Time: now, Color: color})
+}
+
+// forfeitExpiredGames ends the correspondence games whose player on turn has
+// missed their deadline.
+func forfeitExpiredGames() {
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+
+	for _, g := range games {
+		if _, err := forfeitIfExpired(g); err != nil {
+			log.Println("Failed to forfeit game", g.ID, err)
+		}
+	}
+}
+
+func vacationHandler(w http.ResponseWriter, r *http.Request) {
+	days, err := strconv.Atoi(r.FormValue("days"))
+	if err != nil {
+		http.Error(w, "invalid number of days: "+r.FormValue("days"), http.StatusBadRequest)
+		return
+	}
+
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+
+	game := lookupGame(w, r)
+	if game == nil {
+		return
+	}
+	color, ok := requirePlayer(w, r, game)
+	if !ok {
+		return
+	}
+	if game.Correspondence == nil {
+		http.Error(w, "only correspondence games have vacation", http.StatusBadRequest)
+		return
+	}
+
+	if err := game.Correspondence.TakeVacation(color, days); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := saveGame(game, DeadlineEvent(game)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// conditionalsHandler replaces the premoves of the player, given one line of
+// moves per row such as "e7e5 g1f3 b8c6 f1c4".
+func conditionalsHandler(w http.ResponseWriter, r *http.Request) {
+	lines := [][]string{}
+	for _, row := range strings.Split(r.FormValue("lines"), "\n") {
+		if moves := strings.Fields(row); len(moves) > 0 {
+			lines = append(lines, moves)
+		}
+	}
+
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+
+	game := lookupGame(w, r)
+	if game == nil {
+		return
+	}
+	color, ok := requirePlayer(w, r, game)
+	if !ok {
+		return
+	}
+	if err := game.SetConditionals(color, lines); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := saveGame(game, DeadlineEvent(game)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// awaitingHandler lists the games where it is the user's turn, the most
+// urgent deadlines first.
+func awaitingHandler(w http.ResponseWriter, r *http.Request) {
+	username := sessions.Username(r)
+	if username == "" {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+
+	awaiting := []*Game{}
+	for _, g := range games {
+		color, ok := g.PlayerColor(username)
+		if ok && g.State == Ongoing && g.GetCurrentPlayerColor() == color {
+			awaiting = append(awaiting, g)
+		}
+	}
+	// Games without a deadline come last
+	sort.Slice(awaiting, func(i, j int) bool {
+		a, b := awaiting[i].Deadline(), awaiting[j].Deadline()
+		if a.IsZero() || b.IsZero() {
+			return !a.IsZero() || (b.IsZero() && awaiting[i].ID < awaiting[j].ID)
+		}
+		return a.Before(b)
+	})
+
+	err := parseTemplates().ExecuteTemplate(w, "awaiting", struct {
+		Username string
+		Games    []*Game
+	}{username, awaiting})
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
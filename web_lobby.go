@@ -0,0 +1,223 @@
+package gochess
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// parseTimeControl reads the minutes and increment seconds of a form.
+func parseTimeControl(r *http.Request) (TimeControl, error) {
+	var tc TimeControl
+	if minutes := r.FormValue("minutes"); minutes != "" {
+		m, err := strconv.ParseFloat(minutes, 64)
+		if err != nil || m < 0 {
+			return tc, errors.New("invalid minutes: " + minutes)
+		}
+		tc.Initial = time.Duration(m * float64(time.Minute))
+	}
+	if increment := r.FormValue("increment"); increment != "" {
+		i, err := strconv.Atoi(increment)
+		if err != nil || i < 0 {
+			return tc, errors.New("invalid increment: " + increment)
+		}
+		tc.Increment = time.Duration(i) * time.Second
+	}
+	return tc, nil
+}
+
+func parseRating(value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+func renderLobby(w http.ResponseWriter, name, username string) {
+	err := parseTemplates().ExecuteTemplate(w, name, struct {
+		Username   string
+		Seeks      []Seek
+		Challenges []Challenge
+	}{username, lobby.Seeks(), lobby.Challenges(username)})
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func lobbyHandler(w http.ResponseWriter, r *http.Request) {
+	username := sessions.Username(r)
+	if username == "" {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	renderLobby(w, "lobby", username)
+}
+
+// lobbySeeksHandler renders the list of seeks and challenges, which the lobby
+// page polls. Players whose seek or challenge has been accepted are sent to
+// their new game.
+func lobbySeeksHandler(w http.ResponseWriter, r *http.Request) {
+	username, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	if matched := lobby.Matched(username); len(matched) > 0 {
+		w.Header().Set("HX-Redirect", "/games/"+matched[len(matched)-1])
+	}
+	renderLobby(w, "lobbySeeks", username)
+}
+
+func postSeekHandler(w http.ResponseWriter, r *http.Request) {
+	username, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	tc, err := parseTimeControl(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	minRating, err := parseRating(r.FormValue("min_rating"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	maxRating, err := parseRating(r.FormValue("max_rating"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ratings, err := rater.Ratings(username)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	g, err := lobby.PostSeek(&Seek{
+		Username:    username,
+		Rating:      ratings.Elo.Rating,
+		TimeControl: tc,
+		Rated:       r.FormValue("rated") != "",
+		Color:       PieceColor(r.FormValue("color")),
+		MinRating:   minRating,
+		MaxRating:   maxRating,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if g != nil {
+		http.Redirect(w, r, "/games/"+g.ID, http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/lobby", http.StatusSeeOther)
+}
+
+func acceptSeekHandler(w http.ResponseWriter, r *http.Request) {
+	username, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	ratings, err := rater.Ratings(username)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	g, err := lobby.AcceptSeek(username, ratings.Elo.Rating, mux.Vars(r)["id"])
+	if errors.Is(err, ErrSeekNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, "/games/"+g.ID, http.StatusSeeOther)
+}
+
+func cancelSeekHandler(w http.ResponseWriter, r *http.Request) {
+	username, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := lobby.CancelSeek(username, mux.Vars(r)["id"]); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	http.Redirect(w, r, "/lobby", http.StatusSeeOther)
+}
+
+func challengeHandler(w http.ResponseWriter, r *http.Request) {
+	username, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	to := r.FormValue("to")
+	if _, err := users.GetUser(to); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tc, err := parseTimeControl(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = lobby.Challenge(&Challenge{
+		From:        username,
+		To:          to,
+		TimeControl: tc,
+		Rated:       r.FormValue("rated") != "",
+		Color:       PieceColor(r.FormValue("color")),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, "/lobby", http.StatusSeeOther)
+}
+
+func acceptChallengeHandler(w http.ResponseWriter, r *http.Request) {
+	username, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	g, err := lobby.AcceptChallenge(username, mux.Vars(r)["id"])
+	if errors.Is(err, ErrChallengeNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/games/"+g.ID, http.StatusSeeOther)
+}
+
+func declineChallengeHandler(w http.ResponseWriter, r *http.Request) {
+	username, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := lobby.DeclineChallenge(username, mux.Vars(r)["id"]); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	http.Redirect(w, r, "/lobby", http.StatusSeeOther)
+}
@@ -0,0 +1,30 @@
+package gochess
+
+// gameOffer is a player's offer to their opponent, which lapses once a move
+// is made or taken back.
+type gameOffer struct {
+	Username string
+	// Ply is the number of moves played when the offer was made
+	Ply int
+}
+
+// takebackOffers map ongoing games to the player asking to take back the last
+// move, and drawOffers to the player offering a draw. Guarded by gamesMu.
+var (
+	takebackOffers = map[string]gameOffer{}
+	drawOffers     = map[string]gameOffer{}
+)
+
+// offerOrAccept accepts the opponent's standing offer, or records the
+// player's own offer and publishes it to the players. The caller must hold
+// gamesMu.
+func offerOrAccept(offers map[string]gameOffer, g *Game, username, message string) bool {
+	offer, ok := offers[g.ID]
+	if ok && offer.Username != username && offer.Ply == len(g.History) {
+		delete(offers, g.ID)
+		return true
+	}
+	offers[g.ID] = gameOffer{Username: username, Ply: len(g.History)}
+	hub.Publish(g, hubEvent{Name: "offer", Data: username + " " + message}, true, false)
+	return false
+}
@@ -0,0 +1,105 @@
+package gochess
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// puzzlePage is a user's attempt at a puzzle, with their puzzle record.
+type puzzlePage struct {
+	gamePage
+	Attempt PuzzleAttempt
+	Record  *PuzzleRecord
+	// Solver is the color the user plays
+	Solver PieceColor
+	// Solution is the solution in SAN, shown once the attempt is over
+	Solution string
+	Rating   string
+	Change   string
+	Error    string
+}
+
+// newPuzzlePage shows the attempt, or the error of starting it.
+func newPuzzlePage(username string, attempt PuzzleAttempt, err error) (puzzlePage, error) {
+	page := puzzlePage{gamePage: gamePage{Username: username}}
+	if errors.Is(err, ErrNoPuzzle) {
+		page.Error = "You have tried every puzzle. Well done!"
+	} else if err != nil {
+		return page, err
+	}
+
+	if page.Record, err = puzzleTrainer.Record(username); err != nil {
+		return page, err
+	}
+	page.Rating = fmt.Sprintf("%.0f", page.Record.Rating.Rating)
+	if page.Error != "" {
+		return page, nil
+	}
+
+	page.Game = attempt.Position
+	page.Attempt = attempt
+	start, solution, err := attempt.Puzzle.solution()
+	if err != nil {
+		return page, err
+	}
+	page.Solver = start.GetCurrentPlayerColor()
+	if attempt.Outcome != PuzzleUnsolved {
+		page.Solution = start.moveText(solution)
+		page.Change = fmt.Sprintf("%+.0f", attempt.RatingChange)
+	}
+	return page, nil
+}
+
+// puzzlesHandler renders the user's current puzzle.
+func puzzlesHandler(w http.ResponseWriter, r *http.Request) {
+	username, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	attempt, err := puzzleTrainer.Current(username)
+	renderPuzzle(w, "puzzle", username, attempt, err)
+}
+
+// nextPuzzleHandler moves on to the next puzzle, failing the current one if
+// it is unsolved.
+func nextPuzzleHandler(w http.ResponseWriter, r *http.Request) {
+	username, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	if _, err := puzzleTrainer.Next(username); err != nil && !errors.Is(err, ErrNoPuzzle) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/puzzles", http.StatusSeeOther)
+}
+
+// puzzleMoveHandler plays the user's move in their puzzle and renders the
+// attempt after the opponent's reply.
+func puzzleMoveHandler(w http.ResponseWriter, r *http.Request) {
+	username, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	attempt, err := puzzleTrainer.Move(username, strings.TrimSpace(r.FormValue("move")))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	renderPuzzle(w, "puzzleAttempt", username, attempt, nil)
+}
+
+func renderPuzzle(w http.ResponseWriter, name, username string, attempt PuzzleAttempt, err error) {
+	page, err := newPuzzlePage(username, attempt, err)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := parseTemplates().ExecuteTemplate(w, name, page); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,51 @@
+package gochess
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func leaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	system := EloSystem
+	if r.FormValue("system") == "glicko" {
+		system = GlickoSystem
+	}
+
+	leaders, err := rater.Leaderboard(system)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = parseTemplates().ExecuteTemplate(w, "leaderboard", struct {
+		System  string
+		Players []*PlayerRatings
+	}{system, leaders})
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func playerHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if _, err := users.GetUser(name); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	ratings, err := rater.Ratings(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = parseTemplates().ExecuteTemplate(w, "player", ratings)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,140 @@
+package gochess
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// reviewPage is the review of a finished game, or just its players while
+// the review is running.
+type reviewPage struct {
+	ID      string
+	Ready   bool
+	Players [2]reviewPlayerView
+	Moves   []reviewMoveView
+}
+
+// reviewPlayerView sums up the play of one side.
+type reviewPlayerView struct {
+	Name         string
+	Accuracy     string
+	AverageLoss  int
+	Inaccuracies int
+	Mistakes     int
+	Blunders     int
+}
+
+// reviewMoveView is a reviewed move, with the evaluation after it from
+// White's point of view and the engine's move when it was better.
+type reviewMoveView struct {
+	Number     string
+	SAN        string
+	Eval       string
+	Class      MoveClass
+	Best       string
+	MissedMate int
+}
+
+// newReviewPage shows the review of the game, if it is done.
+func newReviewPage(g *Game, review *GameReview) reviewPage {
+	page := reviewPage{ID: g.ID, Ready: review != nil}
+	for i := range page.Players {
+		page.Players[i].Name = g.Players[i].Name
+	}
+	if review == nil {
+		return page
+	}
+
+	position := g.startPosition()
+	for i := range page.Players {
+		page.Players[i].Accuracy = fmt.Sprintf("%.1f%%", review.Accuracy[i])
+		page.Players[i].AverageLoss = review.AverageLoss[i]
+	}
+	for _, move := range review.Moves {
+		ply := position.ply()
+		view := reviewMoveView{Number: fmt.Sprintf("%d.", ply/2+1), SAN: move.SAN, Class: move.Class, MissedMate: move.MissedMate}
+		if ply%2 == 1 {
+			view.Number = fmt.Sprintf("%d...", ply/2+1)
+		}
+		if !move.Ended {
+			view.Eval = move.eval()
+		}
+		if move.Class != GoodMove || move.MissedMate > 0 {
+			view.Best = move.BestSAN
+		}
+		player := &page.Players[colorIndex(move.Move.Color)]
+		switch move.Class {
+		case Inaccuracy:
+			player.Inaccuracies++
+		case Mistake:
+			player.Mistakes++
+		case Blunder:
+			player.Blunders++
+		}
+		page.Moves = append(page.Moves, view)
+		position.applyMove(move.Move)
+	}
+	return page
+}
+
+// lookupReview finds the finished game of the request and its review,
+// starting the review if the game ended before the server started.
+func lookupReview(w http.ResponseWriter, r *http.Request) (*Game, *GameReview) {
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+
+	game := lookupGame(w, r)
+	if game == nil {
+		return nil, nil
+	}
+	if !game.IsOver() {
+		http.Error(w, "the game is not over", http.StatusBadRequest)
+		return nil, nil
+	}
+	reviewer.Start(game)
+	return game.searchCopy(), reviewer.Review(game.ID)
+}
+
+// reviewHandler renders the review page of a finished game.
+func reviewHandler(w http.ResponseWriter, r *http.Request) {
+	renderReview(w, r, "review")
+}
+
+// reviewMovesHandler renders the reviewed moves, which the review page
+// polls for until the review is done.
+func reviewMovesHandler(w http.ResponseWriter, r *http.Request) {
+	renderReview(w, r, "reviewMoves")
+}
+
+func renderReview(w http.ResponseWriter, r *http.Request, name string) {
+	game, review := lookupReview(w, r)
+	if game == nil {
+		return
+	}
+
+	err := parseTemplates().ExecuteTemplate(w, name, newReviewPage(game, review))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// reviewPGNHandler downloads the game annotated with its review.
+func reviewPGNHandler(w http.ResponseWriter, r *http.Request) {
+	game, review := lookupReview(w, r)
+	if game == nil {
+		return
+	}
+	if review == nil {
+		w.Header().Set("Retry-After", "2")
+		http.Error(w, "the game is still being reviewed", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-chess-pgn")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.pgn\"", game.ID))
+	if err := WriteAnnotatedPGN(w, pgnTags(game), game, review); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,70 @@
+package gochess
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// rematchOffers maps finished games to the player offering a rematch.
+// Guarded by gamesMu.
+var rematchOffers = map[string]string{}
+
+// rematchHandler offers a rematch to the opponent, or accepts their offer and
+// starts the next game of the series. The computer accepts straight away.
+func rematchHandler(w http.ResponseWriter, r *http.Request) {
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+
+	game := lookupGame(w, r)
+	if game == nil {
+		return
+	}
+	if _, ok := requirePlayer(w, r, game); !ok {
+		return
+	}
+	username := sessions.Username(r)
+
+	offeredBy, offered := rematchOffers[game.ID]
+	if game.Computer == nil && (!offered || offeredBy == username) {
+		if !game.IsOver() {
+			http.Error(w, "Game is not over, got state: "+string(game.State), http.StatusBadRequest)
+			return
+		}
+		if game.Rematch != "" {
+			http.Error(w, "the game has already been rematched", http.StatusBadRequest)
+			return
+		}
+		rematchOffers[game.ID] = username
+		hub.Publish(game, hubEvent{Name: "rematch", Data: username + " offers a rematch"}, true, false)
+		return
+	}
+
+	next, err := Rematch(game)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := addGame(next); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	delete(rematchOffers, game.ID)
+	// A game is only rematched once, so that the series does not fork. The
+	// link is recorded once the next game has been saved.
+	game.Rematch = next.ID
+	if err := saveGame(game, GameEvent{Type: EventRematched, Time: timeNow(), GameID: next.ID}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	go playComputerMove(next.ID)
+
+	var link bytes.Buffer
+	if err := parseTemplates().ExecuteTemplate(&link, "rematchLink", next); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	hub.Publish(game, hubEvent{Name: "rematch", Data: link.String()}, true, false)
+
+	w.Header().Set("HX-Redirect", "/games/"+next.ID)
+	http.Redirect(w, r, "/games/"+next.ID, http.StatusSeeOther)
+}
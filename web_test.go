@@ -0,0 +1,1105 @@
+package gochess
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupServer resets the server globals and returns a router to test against.
+func setupServer(t *testing.T) http.Handler {
+	t.Helper()
+	// Moves of the computer from earlier tests may still be in flight
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+	store = NewMemoryGameStore()
+	users = NewMemoryUserStore()
+	sessions = NewSessionManager()
+	games = map[string]*Game{}
+	rater = NewRater(NewMemoryRatingStore(), Elo{K: 20}, Glicko2{Tau: 0.5})
+	lobby = NewLobby(createGame)
+	hub = NewGameHub()
+	chat = NewChat(5, 10*time.Second, MaxLengthFilter(500))
+	rematchOffers = map[string]string{}
+	takebackOffers = map[string]gameOffer{}
+	drawOffers = map[string]gameOffer{}
+	tournaments = NewTournamentDirector(NewMemoryTournamentStore())
+	arenas = NewArenaDirector(NewMemoryArenaStore())
+	// Games are reviewed in the background when they end
+	reviewer = NewReviewer(analysisEngine, SearchLimits{Depth: 2})
+	t.Cleanup(reviewer.Wait)
+	puzzleTrainer = NewPuzzleTrainer(nil, NewMemoryPuzzleStore(), Elo{K: 20})
+	return newRouter()
+}
+
+// login registers the user and returns their session cookie.
+func login(t *testing.T, router http.Handler, username string) *http.Cookie {
+	t.Helper()
+	w := postForm(router, "/register", nil, url.Values{"username": {username}, "password": {"correct horse"}})
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("Expected register to redirect, but got %d: %s", w.Code, w.Body)
+	}
+	return w.Result().Cookies()[0]
+}
+
+func postForm(router http.Handler, path string, cookie *http.Cookie, form url.Values) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestMoveHandlerOnlyLetsPlayersMoveTheirColor(t *testing.T) {
+	router := setupServer(t)
+	alice := login(t, router, "alice")
+	bob := login(t, router, "bob")
+	eve := login(t, router, "eve")
+
+	w := postForm(router, "/games", alice, url.Values{"opponent": {"bob"}})
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("Expected new game to redirect, but got %d: %s", w.Code, w.Body)
+	}
+	gamePath := w.Header().Get("Location")
+
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+		move   string
+		want   int
+	}{
+		{name: "anonymous", cookie: nil, move: "e2e4", want: http.StatusUnauthorized},
+		{name: "not a player", cookie: eve, move: "e2e4", want: http.StatusForbidden},
+		{name: "black on white's turn", cookie: bob, move: "e7e5", want: http.StatusForbidden},
+		{name: "white", cookie: alice, move: "e2e4", want: http.StatusOK},
+		{name: "white twice", cookie: alice, move: "d2d4", want: http.StatusForbidden},
+		{name: "black", cookie: bob, move: "e7e5", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postForm(router, gamePath+"/move", tt.cookie, url.Values{"move": {tt.move}})
+			if w.Code != tt.want {
+				t.Errorf("Expected status %d, but got %d: %s", tt.want, w.Code, w.Body)
+			}
+		})
+	}
+}
+
+func TestJoinOpenSeat(t *testing.T) {
+	router := setupServer(t)
+	alice := login(t, router, "alice")
+
+	w := postForm(router, "/guest", nil, nil)
+	guest := w.Result().Cookies()[0]
+
+	gamePath := postForm(router, "/games", alice, nil).Header().Get("Location")
+	if w := postForm(router, gamePath+"/join", guest, nil); w.Code != http.StatusSeeOther {
+		t.Fatalf("Expected join to redirect, but got %d: %s", w.Code, w.Body)
+	}
+
+	r := httptest.NewRequest("GET", gamePath, nil)
+	r.AddCookie(guest)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "guest-") {
+		t.Errorf("Expected the game page to show the guest, but got %d: %s", w.Code, w.Body)
+	}
+
+	postForm(router, gamePath+"/move", alice, url.Values{"move": {"e2e4"}})
+	if w := postForm(router, gamePath+"/move", guest, url.Values{"move": {"e7e5"}}); w.Code != http.StatusOK {
+		t.Errorf("Expected the guest to move as Black, but got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestMoveHandlerPromotes(t *testing.T) {
+	router := setupServer(t)
+	alice := login(t, router, "alice")
+	bob := login(t, router, "bob")
+
+	tests := []struct {
+		name      string
+		move      string
+		promotion PieceType
+		fen       string
+	}{
+		{name: "to a knight", move: "b7a8n", promotion: Knight, fen: "N2qkbnr/2pppppp/2n5/8/8/8/1PPPPPPP/RNBQKBNR b KQk - 0 5"},
+		{name: "to a queen unless told otherwise", move: "b7a8", promotion: Queen, fen: "Q2qkbnr/2pppppp/2n5/8/8/8/1PPPPPPP/RNBQKBNR b KQk - 0 5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gamePath := postForm(router, "/games", alice, url.Values{"opponent": {"bob"}}).Header().Get("Location")
+			cookies := []*http.Cookie{alice, bob}
+			for i, move := range []string{"a2a4", "b7b5", "a4b5", "a7a6", "b5a6", "c8b7", "a6b7", "b8c6"} {
+				if w := postForm(router, gamePath+"/move", cookies[i%2], url.Values{"move": {move}}); w.Code != http.StatusOK {
+					t.Fatalf("Expected %s to be played, but got %d: %s", move, w.Code, w.Body)
+				}
+			}
+			for _, move := range []string{"e2e4q", "b7a8k"} {
+				if w := postForm(router, gamePath+"/move", alice, url.Values{"move": {move}}); w.Code != http.StatusBadRequest {
+					t.Errorf("Expected %s to be refused, but got %d: %s", move, w.Code, w.Body)
+				}
+			}
+			if w := postForm(router, gamePath+"/move", alice, url.Values{"move": {tt.move}}); w.Code != http.StatusOK {
+				t.Fatalf("Expected %s to be played, but got %d: %s", tt.move, w.Code, w.Body)
+			}
+
+			id := strings.TrimPrefix(gamePath, "/games/")
+			gamesMu.Lock()
+			g := games[id]
+			gamesMu.Unlock()
+			if g.State != Ongoing || g.FEN() != tt.fen {
+				t.Errorf("Expected %s, but got %s in %s", tt.fen, g.FEN(), g.State)
+			}
+			if w := postForm(router, gamePath+"/move", bob, url.Values{"move": {"e7e5"}}); w.Code != http.StatusOK {
+				t.Errorf("Expected Black to move after the promotion, but got %d: %s", w.Code, w.Body)
+			}
+
+			events, _ := store.Events(id)
+			replayed, err := GameLog(events).Replay()
+			if err != nil || len(replayed.History) != 10 || replayed.History[8].Promotion != tt.promotion {
+				t.Errorf("Expected the replay to promote to a %s, but got %v", tt.promotion, err)
+			}
+		})
+	}
+}
+
+func TestTakeback(t *testing.T) {
+	router := setupServer(t)
+	alice := login(t, router, "alice")
+	bob := login(t, router, "bob")
+	gamePath := postForm(router, "/games", alice, url.Values{"opponent": {"bob"}}).Header().Get("Location")
+	id := strings.TrimPrefix(gamePath, "/games/")
+	moves := func() int {
+		gamesMu.Lock()
+		defer gamesMu.Unlock()
+		return len(games[id].History)
+	}
+
+	if w := postForm(router, gamePath+"/undo", alice, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected no takeback before a move, but got %d", w.Code)
+	}
+	postForm(router, gamePath+"/move", alice, url.Values{"move": {"e2e4"}})
+
+	// Asking twice does not take the move back
+	for i := 0; i < 2; i++ {
+		if w := postForm(router, gamePath+"/undo", alice, nil); w.Code != http.StatusOK || moves() != 1 {
+			t.Fatalf("Expected Alice's request to wait for Bob, but got %d with %d moves", w.Code, moves())
+		}
+	}
+	// The request lapses once a move is made
+	postForm(router, gamePath+"/move", bob, url.Values{"move": {"e7e5"}})
+	if postForm(router, gamePath+"/undo", bob, nil); moves() != 2 {
+		t.Fatalf("Expected Alice's request to lapse, but got %d moves", moves())
+	}
+	if w := postForm(router, gamePath+"/undo", alice, nil); w.Code != http.StatusOK || moves() != 1 {
+		t.Errorf("Expected Alice to agree to take e7e5 back, but got %d with %d moves", w.Code, moves())
+	}
+
+	events, _ := store.Events(id)
+	if replayed, err := GameLog(events).Replay(); err != nil || len(replayed.History) != 1 {
+		t.Errorf("Expected the replay to take the move back, but got %v", err)
+	}
+}
+
+func TestDrawOffer(t *testing.T) {
+	router := setupServer(t)
+	alice := login(t, router, "alice")
+	bob := login(t, router, "bob")
+	gamePath := postForm(router, "/games", alice, url.Values{"opponent": {"bob"}}).Header().Get("Location")
+	id := strings.TrimPrefix(gamePath, "/games/")
+	state := func() GameState {
+		gamesMu.Lock()
+		defer gamesMu.Unlock()
+		return games[id].State
+	}
+
+	// The offer lapses once a move is made
+	postForm(router, gamePath+"/draw", alice, nil)
+	postForm(router, gamePath+"/draw", alice, nil)
+	postForm(router, gamePath+"/move", alice, url.Values{"move": {"e2e4"}})
+	if w := postForm(router, gamePath+"/draw", bob, nil); w.Code != http.StatusOK || state() != Ongoing {
+		t.Fatalf("Expected Alice's offer to lapse, but got %d in %s", w.Code, state())
+	}
+	if w := postForm(router, gamePath+"/draw", alice, nil); w.Code != http.StatusOK || state() != Draw {
+		t.Fatalf("Expected Alice to accept Bob's offer, but got %d in %s", w.Code, state())
+	}
+	if w := postForm(router, gamePath+"/draw", bob, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected no draw offer once the game is over, but got %d", w.Code)
+	}
+}
+
+func TestLoseOnTime(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	router := setupServer(t)
+	alice := login(t, router, "alice")
+	bob := login(t, router, "bob")
+	g, err := createGame("alice", "bob", TimeControl{Initial: time.Minute}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gamePath := "/games/" + g.ID
+
+	postForm(router, gamePath+"/move", alice, url.Values{"move": {"e2e4"}})
+	now = now.Add(time.Minute)
+	if w := postForm(router, gamePath+"/move", bob, url.Values{"move": {"e7e5"}}); w.Code != http.StatusConflict {
+		t.Errorf("Expected Bob's move to be refused, but got %d: %s", w.Code, w.Body)
+	}
+	if g.State != WhiteWon {
+		t.Errorf("Expected Bob to lose on time, but got %s", g.State)
+	}
+
+	events, _ := store.Events(g.ID)
+	if replayed, err := GameLog(events).Replay(); err != nil || replayed.State != WhiteWon || len(replayed.History) != 1 {
+		t.Errorf("Expected the replay to end on time, but got %v", err)
+	}
+
+	// Players who do not try to move lose on time too
+	g, _ = createGame("alice", "bob", TimeControl{Initial: time.Minute}, true)
+	postForm(router, "/games/"+g.ID+"/move", alice, url.Values{"move": {"e2e4"}})
+	now = now.Add(30 * time.Second)
+	if flagGames(); g.State != Ongoing {
+		t.Errorf("Expected Bob to have time left, but got %s", g.State)
+	}
+	now = now.Add(30 * time.Second)
+	if flagGames(); g.State != WhiteWon {
+		t.Errorf("Expected Bob to lose on time, but got %s", g.State)
+	}
+}
+
+func TestResignUpdatesLeaderboard(t *testing.T) {
+	router := setupServer(t)
+	alice := login(t, router, "alice")
+	login(t, router, "bob")
+
+	gamePath := postForm(router, "/games", alice, url.Values{"opponent": {"bob"}}).Header().Get("Location")
+	if w := postForm(router, gamePath+"/resign", alice, nil); w.Code != http.StatusOK {
+		t.Fatalf("Expected resign to succeed, but got %d: %s", w.Code, w.Body)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/leaderboard", nil))
+	body := w.Body.String()
+	if w.Code != http.StatusOK || strings.Index(body, "bob") > strings.Index(body, "alice") || !strings.Contains(body, "1510") {
+		t.Errorf("Expected Bob to lead the leaderboard with 1510, but got %d: %s", w.Code, body)
+	}
+}
+
+func TestLobbySeekStartsGame(t *testing.T) {
+	router := setupServer(t)
+	alice := login(t, router, "alice")
+	bob := login(t, router, "bob")
+
+	seek := url.Values{"minutes": {"5"}, "increment": {"3"}, "rated": {"1"}}
+	if w := postForm(router, "/lobby/seeks", alice, seek); w.Header().Get("Location") != "/lobby" {
+		t.Fatalf("Expected Alice to wait in the lobby, but got %d: %s", w.Code, w.Header())
+	}
+	w := postForm(router, "/lobby/seeks", bob, seek)
+	gamePath := w.Header().Get("Location")
+	if !strings.HasPrefix(gamePath, "/games/") {
+		t.Fatalf("Expected Bob to be sent to a game, but got %d: %s", w.Code, w.Header())
+	}
+
+	// Alice finds out about the game the next time the lobby is polled
+	r := httptest.NewRequest("GET", "/lobby/seeks", nil)
+	r.AddCookie(alice)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Header().Get("HX-Redirect") != gamePath {
+		t.Errorf("Expected Alice to be redirected to %v, but got %v", gamePath, w.Header())
+	}
+
+	g := games[strings.TrimPrefix(gamePath, "/games/")]
+	if g.Clock == nil || g.Clock.Initial != 5*time.Minute || g.Clock.Increment != 3*time.Second || g.Casual {
+		t.Errorf("Expected a rated 5+3 game, but got %+v", g)
+	}
+}
+
+func get(router http.Handler, path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", path, nil)
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestSpectators(t *testing.T) {
+	router := setupServer(t)
+	alice := login(t, router, "alice")
+	bob := login(t, router, "bob")
+	eve := login(t, router, "eve")
+
+	gamePath := postForm(router, "/games", alice, url.Values{"opponent": {"bob"}}).Header().Get("Location")
+	id := strings.TrimPrefix(gamePath, "/games/")
+	postForm(router, gamePath+"/move", alice, url.Values{"move": {"e2e4"}})
+
+	// Spectators are sent to the read-only view, which has no way to move
+	w := get(router, gamePath, eve)
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/watch/"+id {
+		t.Errorf("Expected spectators to be redirected to the watch page, but got %d: %v", w.Code, w.Header())
+	}
+	w = get(router, "/watch/"+id, nil)
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), gamePath+"/") {
+		t.Errorf("Expected a read-only watch page, but got %d: %s", w.Code, w.Body)
+	}
+
+	if w := postForm(router, gamePath+"/move", eve, url.Values{"move": {"e7e5"}}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected spectators to not be able to move, but got %d", w.Code)
+	}
+	if w := get(router, gamePath+"/events", eve); w.Code != http.StatusForbidden {
+		t.Errorf("Expected spectators to not get the player events, but got %d", w.Code)
+	}
+
+	// Players keep using the game page
+	if w := get(router, gamePath, bob); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "moveForm") {
+		t.Errorf("Expected Bob to get the game page, but got %d", w.Code)
+	}
+}
+
+func TestSpectatorDelay(t *testing.T) {
+	router := setupServer(t)
+	alice := login(t, router, "alice")
+	login(t, router, "bob")
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	gamePath := postForm(router, "/games", alice, url.Values{"opponent": {"bob"}, "delay_minutes": {"15"}}).Header().Get("Location")
+	id := strings.TrimPrefix(gamePath, "/games/")
+
+	now = now.Add(time.Minute)
+	postForm(router, gamePath+"/move", alice, url.Values{"move": {"e2e4"}})
+
+	g, err := spectatorView(games[id])
+	if err != nil {
+		t.Fatalf("spectatorView() error = %v", err)
+	}
+	if len(g.History) != 0 {
+		t.Errorf("Expected spectators to not see the move yet, but got %v", g.History)
+	}
+
+	// The board and replay routes of the game hold the move back too, except
+	// for the players
+	live := get(router, gamePath+"/board", alice).Body.String()
+	if body := get(router, gamePath+"/board", nil).Body.String(); body == live {
+		t.Error("Expected the board to hold the move back from spectators")
+	}
+	if w := get(router, gamePath+"/replay/1", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected spectators to not replay the move yet, but got %d", w.Code)
+	}
+	if w := get(router, gamePath+"/replay/1", alice); w.Code != http.StatusOK {
+		t.Errorf("Expected Alice to replay her move, but got %d: %s", w.Code, w.Body)
+	}
+
+	now = now.Add(15 * time.Minute)
+	g, err = spectatorView(games[id])
+	if err != nil {
+		t.Fatalf("spectatorView() error = %v", err)
+	}
+	if len(g.History) != 1 {
+		t.Errorf("Expected spectators to see the move after the delay, but got %v", g.History)
+	}
+	if body := get(router, gamePath+"/board", nil).Body.String(); body != live {
+		t.Error("Expected the board to show the move after the delay")
+	}
+	if w := get(router, gamePath+"/replay/1", nil); w.Code != http.StatusOK {
+		t.Errorf("Expected spectators to replay the move after the delay, but got %d", w.Code)
+	}
+}
+
+func TestChatRooms(t *testing.T) {
+	router := setupServer(t)
+	alice := login(t, router, "alice")
+	bob := login(t, router, "bob")
+	eve := login(t, router, "eve")
+
+	gamePath := postForm(router, "/games", alice, url.Values{"opponent": {"bob"}}).Header().Get("Location")
+	id := strings.TrimPrefix(gamePath, "/games/")
+
+	player := hub.Subscribe(id, false)
+	defer hub.Unsubscribe(id, player)
+
+	if w := postForm(router, gamePath+"/chat", alice, url.Values{"text": {"good luck"}}); w.Code != http.StatusOK {
+		t.Fatalf("Expected Alice to chat, but got %d: %s", w.Code, w.Body)
+	}
+	if event := <-player.events; event.Name != "chat-players" || !strings.Contains(event.Data, "good luck") {
+		t.Errorf("Expected the players to get the message, but got %v", event)
+	}
+
+	if w := postForm(router, gamePath+"/chat", eve, url.Values{"text": {"hi"}}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected spectators to not post in the players room, but got %d", w.Code)
+	}
+	if w := postForm(router, "/watch/"+id+"/chat", bob, url.Values{"text": {"hi"}}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected players to not post in the spectators room, but got %d", w.Code)
+	}
+	if w := postForm(router, "/watch/"+id+"/chat", eve, url.Values{"text": {"play e4"}}); w.Code != http.StatusOK {
+		t.Fatalf("Expected Eve to chat with spectators, but got %d: %s", w.Code, w.Body)
+	}
+	select {
+	case event := <-player.events:
+		if event.Name != "spectators" {
+			t.Errorf("Expected the spectator message to not reach the players, but got %v", event)
+		}
+	default:
+	}
+
+	// Messages are kept with the game
+	body := get(router, "/watch/"+id, nil).Body.String()
+	if !strings.Contains(body, "good luck") || !strings.Contains(body, "play e4") {
+		t.Errorf("Expected the watch page to show both rooms, but got %s", body)
+	}
+	if body := get(router, gamePath, bob).Body.String(); strings.Contains(body, "play e4") {
+		t.Errorf("Expected the game page to not show the spectators room")
+	}
+
+	postForm(router, gamePath+"/mute", bob, url.Values{"username": {"eve"}})
+	if w := postForm(router, "/watch/"+id+"/chat", eve, url.Values{"text": {"hi"}}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected muted users to not chat, but got %d", w.Code)
+	}
+}
+
+func TestChatMutes(t *testing.T) {
+	router := setupServer(t)
+	alice := login(t, router, "alice")
+	bob := login(t, router, "bob")
+	eve := login(t, router, "eve")
+
+	gamePath := postForm(router, "/games", alice, url.Values{"opponent": {"bob"}}).Header().Get("Location")
+	id := strings.TrimPrefix(gamePath, "/games/")
+
+	if w := postForm(router, gamePath+"/mute", bob, url.Values{"username": {"bob"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected players to not mute themselves, but got %d", w.Code)
+	}
+	if w := postForm(router, gamePath+"/mute", bob, url.Values{"username": {"alice"}}); w.Code != http.StatusOK {
+		t.Fatalf("Expected Bob to mute Alice, but got %d: %s", w.Code, w.Body)
+	}
+	if w := postForm(router, gamePath+"/unmute", alice, url.Values{"username": {"alice"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a muted player to not unmute themselves, but got %d", w.Code)
+	}
+	if w := postForm(router, gamePath+"/chat", alice, url.Values{"text": {"hi"}}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected Alice to stay muted, but got %d", w.Code)
+	}
+
+	if w := postForm(router, gamePath+"/mute", alice, url.Values{"username": {"eve"}}); w.Code != http.StatusOK {
+		t.Fatalf("Expected Alice to mute Eve, but got %d: %s", w.Code, w.Body)
+	}
+	if w := postForm(router, gamePath+"/unmute", bob, url.Values{"username": {"eve"}}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected only Alice to unmute Eve, but got %d", w.Code)
+	}
+	if w := postForm(router, gamePath+"/unmute", eve, url.Values{"username": {"eve"}}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected spectators to not unmute, but got %d", w.Code)
+	}
+
+	// Mutes are kept in the event log, so they survive a restart
+	gamesMu.Lock()
+	games = map[string]*Game{}
+	chat = NewChat(5, 10*time.Second, MaxLengthFilter(500))
+	gamesMu.Unlock()
+	if err := loadGames(); err != nil {
+		t.Fatal(err)
+	}
+	if !chat.IsMuted(id, "alice") || !chat.IsMuted(id, "eve") {
+		t.Errorf("Expected the mutes to be restored after a restart")
+	}
+	if w := postForm(router, gamePath+"/unmute", bob, url.Values{"username": {"eve"}}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected only Alice to unmute Eve after a restart, but got %d", w.Code)
+	}
+	if w := postForm(router, gamePath+"/unmute", bob, url.Values{"username": {"alice"}}); w.Code != http.StatusOK {
+		t.Fatalf("Expected Bob to unmute Alice, but got %d: %s", w.Code, w.Body)
+	}
+	if w := postForm(router, gamePath+"/chat", alice, url.Values{"text": {"hi"}}); w.Code != http.StatusOK {
+		t.Errorf("Expected Alice to chat once unmuted, but got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestRematchOffer(t *testing.T) {
+	router := setupServer(t)
+	alice := login(t, router, "alice")
+	bob := login(t, router, "bob")
+
+	gamePath := postForm(router, "/games", alice, url.Values{"opponent": {"bob"}, "best_of": {"3"}}).Header().Get("Location")
+	if w := postForm(router, gamePath+"/rematch", alice, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected no rematch before the game is over, but got %d", w.Code)
+	}
+	postForm(router, gamePath+"/resign", bob, nil)
+
+	if w := postForm(router, gamePath+"/rematch", alice, nil); w.Code != http.StatusOK {
+		t.Fatalf("Expected Alice to offer a rematch, but got %d: %s", w.Code, w.Body)
+	}
+	w := postForm(router, gamePath+"/rematch", bob, nil)
+	next := w.Header().Get("Location")
+	if w.Code != http.StatusSeeOther || next == gamePath {
+		t.Fatalf("Expected Bob to accept the rematch, but got %d: %s", w.Code, w.Body)
+	}
+
+	gamesMu.Lock()
+	g := games[strings.TrimPrefix(next, "/games/")]
+	gamesMu.Unlock()
+	if g.Players[0].Name != "bob" || g.Players[1].Name != "alice" {
+		t.Errorf("Expected colours to be swapped, but got %v", g.Players)
+	}
+	if body := get(router, next, alice).Body.String(); !strings.Contains(body, "best of 3") || !strings.Contains(body, "game 2") {
+		t.Errorf("Expected the game page to show the series, but got %s", body)
+	}
+
+	// The finished game cannot be rematched again
+	for _, cookie := range []*http.Cookie{alice, bob} {
+		if w := postForm(router, gamePath+"/rematch", cookie, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected no second rematch, but got %d: %s", w.Code, w.Body)
+		}
+	}
+	events, _ := store.Events(strings.TrimPrefix(gamePath, "/games/"))
+	if replayed, err := GameLog(events).Replay(); err != nil || "/games/"+replayed.Rematch != next {
+		t.Errorf("Expected the replay to keep the rematch, but got %v", err)
+	}
+
+	// Once the series is decided its final score is shown
+	postForm(router, next+"/resign", bob, nil)
+	if body := get(router, next, alice).Body.String(); !strings.Contains(body, "Series (best of 3) over: bob 0 – 2 alice") {
+		t.Errorf("Expected the game page to show the final score, but got %s", body)
+	}
+}
+
+func TestRematchComputer(t *testing.T) {
+	router := setupServer(t)
+	alice := login(t, router, "alice")
+
+	gamePath := postForm(router, "/games", alice, url.Values{"computer": {"1"}, "level": {"1"}}).Header().Get("Location")
+	postForm(router, gamePath+"/resign", alice, nil)
+
+	// The computer accepts the rematch and opens the next game as White
+	w := postForm(router, gamePath+"/rematch", alice, nil)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("Expected the computer to accept the rematch, but got %d: %s", w.Code, w.Body)
+	}
+	id := strings.TrimPrefix(w.Header().Get("Location"), "/games/")
+	for i := 0; i < 500; i++ {
+		gamesMu.Lock()
+		moves := len(games[id].History)
+		gamesMu.Unlock()
+		if moves == 1 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Expected the computer to play the first move of the rematch")
+}
+
+// failingCreateStore fails to save the first events of new games.
+type failingCreateStore struct {
+	GameStore
+}
+
+func (s failingCreateStore) AppendEvents(id string, events ...GameEvent) error {
+	if len(events) > 0 && events[0].Type == EventCreated {
+		return errors.New("disk full")
+	}
+	return s.GameStore.AppendEvents(id, events...)
+}
+
+func TestRematchStartFails(t *testing.T) {
+	router := setupServer(t)
+	alice := login(t, router, "alice")
+	bob := login(t, router, "bob")
+
+	gamePath := postForm(router, "/games", alice, url.Values{"opponent": {"bob"}}).Header().Get("Location")
+	postForm(router, gamePath+"/resign", bob, nil)
+	postForm(router, gamePath+"/rematch", alice, nil)
+
+	saved := store
+	store = failingCreateStore{saved}
+	if w := postForm(router, gamePath+"/rematch", bob, nil); w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected the rematch to fail, but got %d", w.Code)
+	}
+	store = saved
+
+	gamesMu.Lock()
+	rematch := games[strings.TrimPrefix(gamePath, "/games/")].Rematch
+	gamesMu.Unlock()
+	if rematch != "" {
+		t.Errorf("Expected no rematch to be recorded, but got %s", rematch)
+	}
+	if w := postForm(router, gamePath+"/rematch", bob, nil); w.Code != http.StatusSeeOther {
+		t.Errorf("Expected the rematch to be tried again, but got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestCorrespondenceGame(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	router := setupServer(t)
+	alice := login(t, router, "alice")
+	bob := login(t, router, "bob")
+
+	gamePath := postForm(router, "/games", alice, url.Values{"opponent": {"bob"}, "days_per_move": {"3"}, "vacation_days": {"2"}}).Header().Get("Location")
+	if w := postForm(router, gamePath+"/conditionals", bob, url.Values{"lines": {"e2e4 e7e5\nd2d4 d7d5"}}); w.Code != http.StatusOK {
+		t.Fatalf("Expected Bob to set conditional moves, but got %d: %s", w.Code, w.Body)
+	}
+	if body := get(router, "/awaiting", bob).Body.String(); strings.Contains(body, gamePath) {
+		t.Errorf("Expected the game to not await Bob's move")
+	}
+
+	postForm(router, gamePath+"/move", alice, url.Values{"move": {"d2d4"}})
+	if body := get(router, "/awaiting", alice).Body.String(); !strings.Contains(body, gamePath) || !strings.Contains(body, "Thu 4 Jan") {
+		t.Errorf("Expected Bob's premove to put the game back to Alice, but got %s", body)
+	}
+
+	// Alice misses her deadline, even with her vacation
+	postForm(router, gamePath+"/vacation", alice, url.Values{"days": {"2"}})
+	now = now.Add(4 * day)
+	forfeitExpiredGames()
+	id := strings.TrimPrefix(gamePath, "/games/")
+	if g, _ := store.Load(id); g.State != Ongoing {
+		t.Errorf("Expected the vacation to extend the deadline, but got %s", g.State)
+	}
+	now = now.Add(2 * day)
+	forfeitExpiredGames()
+	g, err := store.Load(id)
+	if err != nil || g.State != BlackWon {
+		t.Fatalf("Expected Alice to forfeit, but got %v %v", g, err)
+	}
+
+	// The log rebuilds the same game
+	events, _ := store.Events(id)
+	replayed, err := GameLog(events).Replay()
+	if err != nil || replayed.State != BlackWon || len(replayed.History) != 2 || replayed.Correspondence.VacationLeft[White] != 0 {
+		t.Errorf("Expected the replay to match, but got %+v %v", replayed, err)
+	}
+}
+
+func TestConditionalLine(t *testing.T) {
+	router := setupServer(t)
+	alice := login(t, router, "alice")
+	bob := login(t, router, "bob")
+
+	gamePath := postForm(router, "/games", alice, url.Values{"opponent": {"bob"}, "days_per_move": {"3"}}).Header().Get("Location")
+	if w := postForm(router, gamePath+"/conditionals", bob, url.Values{"lines": {"z9z9 e7e5"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a move off the board to be rejected, but got %d", w.Code)
+	}
+	if w := postForm(router, gamePath+"/conditionals", bob, url.Values{"lines": {"e2e4 e7e5 g1f3 b8c6 f1c4 g8f6"}}); w.Code != http.StatusOK {
+		t.Fatalf("Expected Bob to set conditional moves, but got %d: %s", w.Code, w.Body)
+	}
+
+	// Bob's line answers every move of Alice that follows it
+	for _, move := range []string{"e2e4", "g1f3", "f1c4"} {
+		if w := postForm(router, gamePath+"/move", alice, url.Values{"move": {move}}); w.Code != http.StatusOK {
+			t.Fatalf("Expected Alice to play %s, but got %d: %s", move, w.Code, w.Body)
+		}
+	}
+
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+	g := games[strings.TrimPrefix(gamePath, "/games/")]
+	if got := g.FEN(); got != "r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4" {
+		t.Errorf("Expected the whole line to be played, but got %s", got)
+	}
+	if len(g.Correspondence.Conditionals[Black]) != 0 {
+		t.Errorf("Expected the line to be used up, but got %v", g.Correspondence.Conditionals[Black])
+	}
+}
+
+func TestMoveAfterDeadline(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	router := setupServer(t)
+	alice := login(t, router, "alice")
+	login(t, router, "bob")
+
+	gamePath := postForm(router, "/games", alice, url.Values{"opponent": {"bob"}, "days_per_move": {"1"}}).Header().Get("Location")
+	now = now.Add(2 * day)
+	if w := postForm(router, gamePath+"/move", alice, url.Values{"move": {"e2e4"}}); w.Code != http.StatusConflict {
+		t.Errorf("Expected a move after the deadline to be refused, but got %d", w.Code)
+	}
+
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+	if g := games[strings.TrimPrefix(gamePath, "/games/")]; g.State != BlackWon || len(g.History) != 0 {
+		t.Errorf("Expected Alice to forfeit without moving, but got %s after %d moves", g.State, len(g.History))
+	}
+}
+
+func TestTournament(t *testing.T) {
+	router := setupServer(t)
+	alice := login(t, router, "alice")
+	bob := login(t, router, "bob")
+
+	w := postForm(router, "/tournaments", alice, url.Values{"name": {"Club blitz"}, "format": {"RoundRobin"}, "minutes": {"3"}, "rated": {"1"}})
+	tournamentPath := w.Header().Get("Location")
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("Expected the tournament to be created, but got %d: %s", w.Code, w.Body)
+	}
+	postForm(router, tournamentPath+"/join", alice, nil)
+	postForm(router, tournamentPath+"/join", bob, nil)
+
+	if w := postForm(router, tournamentPath+"/rounds", bob, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected only the organizer to start a round, but got %d", w.Code)
+	}
+	if w := postForm(router, tournamentPath+"/rounds", alice, nil); w.Code != http.StatusSeeOther {
+		t.Fatalf("Expected the round to start, but got %d: %s", w.Code, w.Body)
+	}
+
+	gamesMu.Lock()
+	var gamePath string
+	for id := range games {
+		gamePath = "/games/" + id
+	}
+	gamesMu.Unlock()
+	postForm(router, gamePath+"/resign", bob, nil)
+
+	body := get(router, tournamentPath, nil).Body.String()
+	if !strings.Contains(body, "round 1 of 1") || !strings.Contains(body, "WhiteWon") {
+		t.Errorf("Expected the tournament page to show the result, but got %s", body)
+	}
+}
+
+func TestArena(t *testing.T) {
+	router := setupServer(t)
+	alice := login(t, router, "alice")
+	bob := login(t, router, "bob")
+
+	w := postForm(router, "/arenas", alice, url.Values{"name": {"Hourly blitz"}, "minutes": {"3"}, "duration": {"60"}})
+	arenaPath := w.Header().Get("Location")
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("Expected the arena to be created, but got %d: %s", w.Code, w.Body)
+	}
+	postForm(router, arenaPath+"/join", alice, nil)
+	postForm(router, arenaPath+"/join", bob, nil)
+	if err := arenas.PairWaiting(createGame); err != nil {
+		t.Fatal(err)
+	}
+
+	gamePath := get(router, arenaPath+"/standings", bob).Header().Get("HX-Redirect")
+	if gamePath == "" {
+		t.Fatalf("Expected Bob to be sent to his game")
+	}
+	if w := postForm(router, gamePath+"/berserk", bob, nil); w.Code != http.StatusOK {
+		t.Fatalf("Expected Bob to go berserk, but got %d: %s", w.Code, w.Body)
+	}
+	gamesMu.Lock()
+	g := games[strings.TrimPrefix(gamePath, "/games/")]
+	color, _ := g.PlayerColor("bob")
+	remaining := g.Clock.Remaining(color, "", timeNow())
+	gamesMu.Unlock()
+	if remaining != 90*time.Second {
+		t.Errorf("Expected Bob to have half his time, but got %v", remaining)
+	}
+
+	postForm(router, gamePath+"/resign", alice, nil)
+	body := get(router, arenaPath+"/standings", nil).Body.String()
+	if !strings.Contains(body, "<td>3</td>") {
+		t.Errorf("Expected Bob to score 3 points for a berserk win, but got %s", body)
+	}
+}
+
+func TestArenaBerserkLosesOnTime(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	router := setupServer(t)
+	cookies := map[string]*http.Cookie{"alice": login(t, router, "alice"), "bob": login(t, router, "bob")}
+	arenaPath := postForm(router, "/arenas", cookies["alice"], url.Values{"name": {"Hourly blitz"}, "minutes": {"3"}, "duration": {"60"}}).Header().Get("Location")
+	postForm(router, arenaPath+"/join", cookies["alice"], nil)
+	postForm(router, arenaPath+"/join", cookies["bob"], nil)
+	if err := arenas.PairWaiting(createGame); err != nil {
+		t.Fatal(err)
+	}
+	gamePath := get(router, arenaPath+"/standings", cookies["bob"]).Header().Get("HX-Redirect")
+	gamesMu.Lock()
+	g := games[strings.TrimPrefix(gamePath, "/games/")]
+	white, black := cookies[g.Players[0].Name], cookies[g.Players[1].Name]
+	gamesMu.Unlock()
+
+	// Black goes berserk and spends more than half the time on a move
+	postForm(router, gamePath+"/move", white, url.Values{"move": {"e2e4"}})
+	if w := postForm(router, gamePath+"/berserk", black, nil); w.Code != http.StatusOK {
+		t.Fatalf("Expected Black to go berserk, but got %d: %s", w.Code, w.Body)
+	}
+	now = now.Add(100 * time.Second)
+	if w := postForm(router, gamePath+"/move", black, url.Values{"move": {"e7e5"}}); w.Code != http.StatusConflict {
+		t.Errorf("Expected Black to be out of time, but got %d: %s", w.Code, w.Body)
+	}
+
+	for _, player := range arenas.Arenas()[0].Standings() {
+		want := 0
+		if player.Name == g.Players[0].Name {
+			want = 2
+		}
+		if player.Points != want {
+			t.Errorf("Expected %s to score %d, but got %d", player.Name, want, player.Points)
+		}
+	}
+}
+
+func TestPlayComputer(t *testing.T) {
+	router := setupServer(t)
+	alice := login(t, router, "alice")
+
+	gamePath := postForm(router, "/games", alice, url.Values{"computer": {"1"}, "level": {"1"}, "color": {"Black"}}).Header().Get("Location")
+	id := strings.TrimPrefix(gamePath, "/games/")
+
+	// waitForMoves waits for the computer to reply in the background
+	waitForMoves := func(n int) {
+		t.Helper()
+		for i := 0; i < 100; i++ {
+			gamesMu.Lock()
+			moves := len(games[id].History)
+			gamesMu.Unlock()
+			if moves == n {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("Expected %d moves to be played", n)
+	}
+
+	// The computer plays White and opens the game
+	waitForMoves(1)
+	gamesMu.Lock()
+	g := games[id]
+	reply := g.LegalMoves()[0]
+	gamesMu.Unlock()
+	move := coordinatesToNotation(reply.From, reply.To)
+	if w := postForm(router, gamePath+"/move", alice, url.Values{"move": {move}}); w.Code != http.StatusOK {
+		t.Fatalf("Expected Alice to move, but got %d: %s", w.Code, w.Body)
+	}
+	waitForMoves(3)
+
+	if _, err := Register(users, "computer", "correct horse"); err == nil {
+		t.Errorf("Expected the computer's name to be reserved")
+	}
+}
+
+func TestPlayExternalEngine(t *testing.T) {
+	router := setupServer(t)
+	fake := startFakeEngine(t)
+	gamesMu.Lock()
+	externalEngines = map[string]*UCIEngine{fake.Name: fake}
+	gamesMu.Unlock()
+	t.Cleanup(func() {
+		gamesMu.Lock()
+		externalEngines = map[string]*UCIEngine{}
+		gamesMu.Unlock()
+	})
+	alice := login(t, router, "alice")
+
+	if w := get(router, "/", alice); !strings.Contains(w.Body.String(), `<option value="Fake Engine">`) {
+		t.Errorf("Expected the engine to be offered, got %s", w.Body)
+	}
+	form := url.Values{"computer": {"1"}, "level": {"2"}, "color": {"Black"}, "engine": {"Stockfish"}}
+	if w := postForm(router, "/games", alice, form); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an unknown engine to be rejected, but got %d", w.Code)
+	}
+
+	form.Set("engine", "Fake Engine")
+	gamePath := postForm(router, "/games", alice, form).Header().Get("Location")
+	id := strings.TrimPrefix(gamePath, "/games/")
+	waitFor := func(n int) *Game {
+		t.Helper()
+		for i := 0; i < 500; i++ {
+			gamesMu.Lock()
+			g := games[id]
+			moves := len(g.History)
+			gamesMu.Unlock()
+			if moves == n {
+				return g
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("Expected %d moves to be played", n)
+		return nil
+	}
+
+	g := waitFor(1)
+	gamesMu.Lock()
+	if g.Players[0].Name != "Computer (Fake Engine)" || uciMove(g.History[0]) != "e2e4" {
+		t.Errorf("Expected the engine to open with e2e4 as White, got %s playing %v", g.Players[0].Name, g.History)
+	}
+	gamesMu.Unlock()
+	// Nobody can take the engine's seat by registering under its name
+	if _, err := Register(users, "Computer (Fake Engine)", "password123"); err == nil {
+		t.Error("Expected the engine's player name to be reserved")
+	}
+
+	if w := postForm(router, gamePath+"/move", alice, url.Values{"move": {"e7e5"}}); w.Code != http.StatusOK {
+		t.Fatalf("Expected Alice to move, but got %d: %s", w.Code, w.Body)
+	}
+	g = waitFor(3)
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+	if got := uciMove(g.History[2]); got != "g1f3" {
+		t.Errorf("Expected the engine to reply g1f3, got %s", got)
+	}
+}
+
+func TestBookMoves(t *testing.T) {
+	router := setupServer(t)
+	alice := login(t, router, "alice")
+	gamePath := postForm(router, "/games", alice, url.Values{}).Header().Get("Location")
+
+	if w := get(router, gamePath+"/book", alice); strings.Contains(w.Body.String(), "Book moves") {
+		t.Errorf("Expected no book moves without a book, got %s", w.Body)
+	}
+
+	gamesMu.Lock()
+	engine.Book = testBook(t)
+	gamesMu.Unlock()
+	t.Cleanup(func() {
+		gamesMu.Lock()
+		engine.Book = nil
+		gamesMu.Unlock()
+	})
+
+	body := get(router, gamePath+"/book", alice).Body.String()
+	for _, want := range []string{"Book moves", "e4 <span class=\"text-gray-400\">80%</span>", "d4 <span class=\"text-gray-400\">20%</span>"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in the book moves, got %s", want, body)
+		}
+	}
+
+	// Spectators get the book moves of the delayed position
+	login(t, router, "bob")
+	gamePath = postForm(router, "/games", alice, url.Values{"opponent": {"bob"}, "delay_minutes": {"15"}}).Header().Get("Location")
+	postForm(router, gamePath+"/move", alice, url.Values{"move": {"e2e4"}})
+	if body := get(router, gamePath+"/book", alice).Body.String(); strings.Contains(body, "80%") {
+		t.Errorf("Expected Alice to get the book moves after 1. e4, got %s", body)
+	}
+	if body := get(router, gamePath+"/book", nil).Body.String(); !strings.Contains(body, "80%") {
+		t.Errorf("Expected spectators to get the book moves of the starting position, got %s", body)
+	}
+}
+
+func TestAnalysisBoard(t *testing.T) {
+	router := setupServer(t)
+	limits := analysisLimits
+	analysisLimits = SearchLimits{Depth: 2}
+	t.Cleanup(func() { analysisLimits = limits })
+
+	fen := "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1"
+	query := "?" + url.Values{"fen": {fen}, "lines": {"2"}}.Encode()
+	body := get(router, "/analysis"+query, nil).Body.String()
+	if !strings.Contains(body, "/analysis/events?fen=6k1%2F5ppp") {
+		t.Errorf("Expected the page to stream the analysis of the position, got %s", body)
+	}
+
+	body = get(router, "/analysis/events"+query, nil).Body.String()
+	for _, want := range []string{"event: evaluation", `style="height: 100%"`, "event: analysis", "Depth 2", "#1</span> 1. Ra8#", "event: done"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in the analysis, got %s", want, body)
+		}
+	}
+	if strings.Count(body, "event: analysis") != 2 {
+		t.Errorf("Expected the analysis of depths 1 and 2, got %s", body)
+	}
+
+	// Scores are from White's point of view when Black is on turn
+	body = get(router, "/analysis/events?"+url.Values{"fen": {"r5k1/8/8/8/8/8/5PPP/6K1 b - - 0 1"}, "lines": {"1"}}.Encode(), nil).Body.String()
+	if !strings.Contains(body, "#-1</span> 1... Ra1#") || !strings.Contains(body, `style="height: 0%"`) {
+		t.Errorf("Expected Black to mate, got %s", body)
+	}
+
+	if w := get(router, "/analysis?lines=20", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected too many lines to be rejected, got %d", w.Code)
+	}
+}
+
+func TestGameReview(t *testing.T) {
+	router := setupServer(t)
+	alice := login(t, router, "alice")
+	bob := login(t, router, "bob")
+	gamePath := postForm(router, "/games", alice, url.Values{"opponent": {"bob"}}).Header().Get("Location")
+
+	if w := get(router, gamePath+"/review", alice); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected no review before the game is over, got %d", w.Code)
+	}
+
+	for i, move := range []string{"e2e4", "e7e5", "d1h5", "b8c6", "f1c4", "g8f6", "h5f7"} {
+		player := alice
+		if i%2 == 1 {
+			player = bob
+		}
+		if w := postForm(router, gamePath+"/move", player, url.Values{"move": {move}}); w.Code != http.StatusOK {
+			t.Fatalf("Expected %s to be played, but got %d: %s", move, w.Code, w.Body)
+		}
+	}
+
+	// The game is reviewed in the background once it is over
+	body := ""
+	for i := 0; i < 500 && !strings.Contains(body, "Accuracy"); i++ {
+		if i > 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		body = get(router, gamePath+"/review/moves", nil).Body.String()
+	}
+	for _, want := range []string{"alice", "bob", "3... Nf6", "Blunder.", "4. Qxf7#"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in the review, got %s", want, body)
+		}
+	}
+
+	w := get(router, gamePath+"/review.pgn", nil)
+	pgn := strings.Join(strings.Fields(w.Body.String()), " ")
+	if w.Code != http.StatusOK || !strings.Contains(pgn, "[White \"alice\"]") || !strings.Contains(pgn, "Nf6 $4") || !strings.Contains(pgn, "Qxf7# 1-0") {
+		t.Errorf("Expected the annotated PGN, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestPuzzles(t *testing.T) {
+	router := setupServer(t)
+	puzzleTrainer = NewPuzzleTrainer([]Puzzle{
+		{ID: "mate", FEN: "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", Moves: []string{"a1a2", "g8h8", "a2a8"}},
+		{ID: "promotion", FEN: "7k/P7/8/8/8/8/6r1/K7 w - - 0 1", Moves: []string{"a7a8q"}},
+	}, NewMemoryPuzzleStore(), Elo{K: 20})
+	alice := login(t, router, "alice")
+
+	if w := get(router, "/puzzles", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected puzzles to need a login, got %d", w.Code)
+	}
+	w := get(router, "/puzzles", alice)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Find the best move for White") {
+		t.Fatalf("Expected the first puzzle, got %d: %s", w.Code, w.Body)
+	}
+
+	if w := postForm(router, "/puzzles/move", alice, url.Values{"move": {"a1b8"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an illegal move to be refused, got %d", w.Code)
+	}
+	w = postForm(router, "/puzzles/move", alice, url.Values{"move": {"a1a2"}})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Your opponent played Kh8") {
+		t.Errorf("Expected the opponent to reply, got %d: %s", w.Code, w.Body)
+	}
+	w = postForm(router, "/puzzles/move", alice, url.Values{"move": {"a2a8"}})
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(body, "Solved! 1. Ra2 Kh8 2. Ra8#") || !strings.Contains(body, "1 solved, 0 failed") {
+		t.Errorf("Expected the puzzle to be solved, got %d: %s", w.Code, body)
+	}
+
+	// Giving up on the next puzzle fails it
+	if w := postForm(router, "/puzzles/next", alice, nil); w.Code != http.StatusSeeOther {
+		t.Fatalf("Expected the next puzzle, got %d: %s", w.Code, w.Body)
+	}
+	if w := postForm(router, "/puzzles/next", alice, nil); w.Code != http.StatusSeeOther {
+		t.Fatalf("Expected to give up the puzzle, got %d: %s", w.Code, w.Body)
+	}
+	body = get(router, "/puzzles", alice).Body.String()
+	if !strings.Contains(body, "You have tried every puzzle") || !strings.Contains(body, "1 solved, 1 failed") {
+		t.Errorf("Expected every puzzle to be tried, got %s", body)
+	}
+}
@@ -0,0 +1,119 @@
+package gochess
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+func tournamentsHandler(w http.ResponseWriter, r *http.Request) {
+	err := parseTemplates().ExecuteTemplate(w, "tournaments", struct {
+		Username    string
+		Tournaments []*Tournament
+	}{sessions.Username(r), tournaments.Tournaments()})
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func createTournamentHandler(w http.ResponseWriter, r *http.Request) {
+	username, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	tc, err := parseTimeControl(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rounds := 0
+	if value := r.FormValue("rounds"); value != "" {
+		rounds, err = strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "invalid number of rounds: "+value, http.StatusBadRequest)
+			return
+		}
+	}
+	name := r.FormValue("name")
+	if name == "" {
+		http.Error(w, "the tournament needs a name", http.StatusBadRequest)
+		return
+	}
+
+	t := NewTournament(name, username, TournamentFormat(r.FormValue("format")), rounds, tc, r.FormValue("rated") != "")
+	if err := tournaments.Create(t); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, "/tournaments/"+t.ID, http.StatusSeeOther)
+}
+
+// tournamentError answers with the status matching an error of the director.
+func tournamentError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, ErrTournamentNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
+
+func tournamentHandler(w http.ResponseWriter, r *http.Request) {
+	username := sessions.Username(r)
+	err := tournaments.Tournament(mux.Vars(r)["id"], func(t *Tournament) error {
+		err := parseTemplates().ExecuteTemplate(w, "tournament", struct {
+			*Tournament
+			Username  string
+			Standings []Standing
+		}{t, username, t.Standings()})
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return nil
+	})
+	if err != nil {
+		tournamentError(w, r, err)
+	}
+}
+
+func joinTournamentHandler(w http.ResponseWriter, r *http.Request) {
+	username, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	ratings, err := rater.Ratings(username)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	err = tournaments.Update(id, func(t *Tournament) error {
+		return t.Register(username, ratings.Elo.Rating)
+	})
+	if err != nil {
+		tournamentError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/tournaments/"+id, http.StatusSeeOther)
+}
+
+func startRoundHandler(w http.ResponseWriter, r *http.Request) {
+	username, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if _, err := tournaments.StartRound(id, username, createGame); err != nil {
+		tournamentError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/tournaments/"+id, http.StatusSeeOther)
+}
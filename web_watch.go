@@ -0,0 +1,149 @@
+package gochess
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// spectatorView returns the game as spectators may see it, which is the
+// position from SpectatorDelay ago, rebuilt from the event log.
+// The caller must hold gamesMu.
+func spectatorView(g *Game) (*Game, error) {
+	if g.SpectatorDelay == 0 {
+		return g, nil
+	}
+
+	events, err := spectatorEvents(g)
+	if err != nil {
+		return nil, err
+	}
+	return GameLog(events).Replay()
+}
+
+// spectatorEvents returns the events of the game that spectators may see,
+// those from before SpectatorDelay ago.
+func spectatorEvents(g *Game) ([]GameEvent, error) {
+	events, err := store.Events(g.ID)
+	if err != nil || g.SpectatorDelay == 0 {
+		return events, err
+	}
+
+	cutoff := timeNow().Add(-g.SpectatorDelay)
+	n := 1
+	for n < len(events) && !events[n].Time.After(cutoff) {
+		n++
+	}
+	return events[:n], nil
+}
+
+func renderSpectatorView(w http.ResponseWriter, r *http.Request, name string) {
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+
+	game := lookupGame(w, r)
+	if game == nil {
+		return
+	}
+
+	view, err := spectatorView(game)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	events, err := store.Events(game.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var cutoff time.Time
+	if game.SpectatorDelay > 0 {
+		cutoff = timeNow().Add(-game.SpectatorDelay)
+	}
+
+	err = parseTemplates().ExecuteTemplate(w, name, gamePage{
+		Game:           view,
+		Username:       sessions.Username(r),
+		BoardURL:       "/watch/" + game.ID + "/board",
+		Spectators:     hub.SpectatorCount(game.ID),
+		PlayersChat:    ChatMessages(events, PlayersRoom, cutoff),
+		SpectatorsChat: ChatMessages(events, SpectatorsRoom, time.Time{}),
+	})
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// watchHandler shows a read-only view of a game. Nothing on it leads to the
+// player routes of the game.
+func watchHandler(w http.ResponseWriter, r *http.Request) {
+	renderSpectatorView(w, r, "watch")
+}
+
+func watchBoardHandler(w http.ResponseWriter, r *http.Request) {
+	renderSpectatorView(w, r, "board")
+}
+
+func watchEventsHandler(w http.ResponseWriter, r *http.Request) {
+	gamesMu.Lock()
+	game := lookupGame(w, r)
+	gamesMu.Unlock()
+	if game == nil {
+		return
+	}
+
+	serveEvents(w, r, game.ID, true)
+}
+
+// gameEventsHandler streams updates to the players of a game, without the
+// spectator delay.
+func gameEventsHandler(w http.ResponseWriter, r *http.Request) {
+	gamesMu.Lock()
+	game := lookupGame(w, r)
+	if game == nil {
+		gamesMu.Unlock()
+		return
+	}
+	_, ok := requirePlayer(w, r, game)
+	gamesMu.Unlock()
+	if !ok {
+		return
+	}
+
+	serveEvents(w, r, game.ID, false)
+}
+
+// serveEvents streams the updates of a game as server-sent events until the
+// client goes away.
+func serveEvents(w http.ResponseWriter, r *http.Request, gameID string, spectator bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher.Flush()
+
+	s := hub.Subscribe(gameID, spectator)
+	defer hub.Unsubscribe(gameID, s)
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-s.events:
+			fmt.Fprintf(w, "event: %s\n", event.Name)
+			for _, line := range strings.Split(event.Data, "\n") {
+				fmt.Fprintf(w, "data: %s\n", line)
+			}
+			fmt.Fprint(w, "\n")
+			flusher.Flush()
+		}
+	}
+}
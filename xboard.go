@@ -0,0 +1,309 @@
+package gochess
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// XBoard speaks the Chess Engine Communication Protocol of XBoard and
+// WinBoard, for GUIs and test harnesses that do not speak UCI. The engine
+// plays the side it is given, replying to each move of the other side, and
+// searches in the background like UCI so the GUI can interrupt it.
+type XBoard struct {
+	engine *Engine
+	game   *Game
+
+	outMu sync.Mutex
+	out   io.Writer
+
+	// force is set while the engine only records moves, and engineColor is
+	// the side it plays otherwise
+	force       bool
+	engineColor PieceColor
+	post        bool
+
+	// The time control: moves per session, base time and increment, or a
+	// fixed time per move, with the engine's clock as last told by the GUI
+	movesPerSession int
+	increment       time.Duration
+	moveTime        time.Duration
+	left            time.Duration
+	depth           int
+
+	// stop and done are set while a search runs; discard is set under mu
+	// when the move it finds should not be played
+	mu      sync.Mutex
+	discard bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func NewXBoard(engine *Engine, out io.Writer) *XBoard {
+	return &XBoard{engine: engine, game: NewGame("", ""), out: out, engineColor: Black}
+}
+
+// Run reads commands until quit or the end of the input.
+func (x *XBoard) Run(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		if !x.Handle(scanner.Text()) {
+			return nil
+		}
+	}
+	x.stopSearch(true)
+	return scanner.Err()
+}
+
+func (x *XBoard) send(format string, args ...interface{}) {
+	x.outMu.Lock()
+	defer x.outMu.Unlock()
+	fmt.Fprintf(x.out, format+"\n", args...)
+}
+
+// Handle runs a single command. It returns false once the GUI quits.
+func (x *XBoard) Handle(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return true
+	}
+
+	switch fields[0] {
+	case "protover":
+		x.send(`feature myname="gochess" usermove=1 setboard=1 ping=1 san=0 colors=0 analyze=0 sigint=0 sigterm=0 reuse=1 done=1`)
+	case "new":
+		x.stopSearch(true)
+		x.engine.TT.Clear()
+		x.game = NewGame("", "")
+		x.force, x.engineColor, x.depth = false, Black, 0
+	case "force":
+		x.stopSearch(true)
+		x.force = true
+	case "go":
+		x.stopSearch(true)
+		x.force = false
+		x.engineColor = x.game.GetCurrentPlayerColor()
+		x.think()
+	case "usermove":
+		x.stopSearch(true)
+		if len(fields) < 2 {
+			x.send("Error (usermove needs a move): %s", line)
+			return true
+		}
+		x.userMove(fields[1])
+	case "?":
+		x.stopSearch(false)
+	case "setboard":
+		x.stopSearch(true)
+		g, err := ParseFEN(strings.Join(fields[1:], " "))
+		if err != nil {
+			x.send("tellusererror Illegal position: %v", err)
+			return true
+		}
+		x.game = g
+	case "undo", "remove":
+		x.stopSearch(true)
+		plies := 1
+		if fields[0] == "remove" {
+			plies = 2
+		}
+		for i := 0; i < plies && len(x.game.History) > 0; i++ {
+			x.game.takeBack()
+			x.game.State = Ongoing
+		}
+	case "level":
+		if err := x.level(fields[1:]); err != nil {
+			x.send("Error (%v): %s", err, line)
+		}
+	case "st", "sd", "time", "otim":
+		value := 0
+		if len(fields) > 1 {
+			value, _ = strconv.Atoi(fields[1])
+		}
+		switch fields[0] {
+		case "st":
+			x.moveTime = time.Duration(value) * time.Second
+		case "sd":
+			x.depth = value
+		case "time":
+			// Clocks are given in centiseconds
+			x.left = time.Duration(value) * 10 * time.Millisecond
+		}
+	case "result":
+		x.stopSearch(true)
+		x.force = true
+	case "ping":
+		x.send("pong %s", strings.Join(fields[1:], " "))
+	case "post", "nopost":
+		x.post = fields[0] == "post"
+	case "quit":
+		x.stopSearch(true)
+		return false
+	case "xboard", "accepted", "rejected", "random", "hard", "easy", "computer", "name", "rating", "ics", "draw":
+	default:
+		x.send("Error (unknown command): %s", fields[0])
+	}
+	return true
+}
+
+// level handles "level <moves per session> <base> <increment>", where the
+// base is in minutes, optionally with seconds, and the increment in seconds.
+func (x *XBoard) level(args []string) error {
+	if len(args) != 3 {
+		return fmt.Errorf("level needs 3 values")
+	}
+	moves, err := strconv.Atoi(args[0])
+	if err != nil || moves < 0 {
+		return fmt.Errorf("invalid moves per session %q", args[0])
+	}
+	minutes, seconds, _ := strings.Cut(args[1], ":")
+	m, err := strconv.Atoi(minutes)
+	if err != nil {
+		return fmt.Errorf("invalid base time %q", args[1])
+	}
+	base := time.Duration(m) * time.Minute
+	if seconds != "" {
+		s, err := strconv.Atoi(seconds)
+		if err != nil {
+			return fmt.Errorf("invalid base time %q", args[1])
+		}
+		base += time.Duration(s) * time.Second
+	}
+	increment, err := strconv.ParseFloat(args[2], 64)
+	if err != nil {
+		return fmt.Errorf("invalid increment %q", args[2])
+	}
+
+	x.movesPerSession = moves
+	x.left = base
+	x.increment = time.Duration(increment * float64(time.Second))
+	x.moveTime = 0
+	return nil
+}
+
+// userMove plays the move of the other side, written as e2e4 or in SAN, and
+// replies if the engine is on turn.
+func (x *XBoard) userMove(notation string) {
+	move, err := parseUCIMove(x.game, notation)
+	if err != nil {
+		move, err = x.game.ParseSAN(notation)
+	}
+	if err != nil || x.game.State != Ongoing {
+		x.send("Illegal move: %s", notation)
+		return
+	}
+	x.play(move)
+	if !x.force && x.game.State == Ongoing && x.game.GetCurrentPlayerColor() == x.engineColor {
+		x.think()
+	}
+}
+
+// play plays a legal move and reports the result if it ends the game.
+func (x *XBoard) play(move Move) {
+	x.game.applyMove(move)
+	x.game.finishTurn(x.game.GetCurrentPlayerColor())
+
+	switch x.game.State {
+	case WhiteWon:
+		x.send("1-0 {White mates}")
+	case BlackWon:
+		x.send("0-1 {Black mates}")
+	case Draw:
+		x.send("1/2-1/2 {Stalemate}")
+	}
+}
+
+// limits works out how long to think on this move from the time control.
+func (x *XBoard) limits() SearchLimits {
+	limits := SearchLimits{Depth: x.depth}
+	switch {
+	case x.moveTime > 0:
+		limits.Time = x.moveTime
+	case x.left > 0:
+		movesToGo := 0
+		if x.movesPerSession > 0 {
+			played := 0
+			for _, move := range x.game.History {
+				if move.Color == x.engineColor {
+					played++
+				}
+			}
+			movesToGo = x.movesPerSession - played%x.movesPerSession
+		}
+		limits.Time = moveTime(x.left, x.increment, movesToGo)
+	}
+	// Without a time control the search would only end when stopped
+	if limits.Depth == 0 && limits.Time == 0 {
+		limits.Depth = DefaultSearchDepth
+	}
+	return limits
+}
+
+// think searches for the engine's move in the background and plays it,
+// unless the search is stopped with its move discarded.
+func (x *XBoard) think() {
+	if x.game.State != Ongoing {
+		return
+	}
+
+	limits := x.limits()
+	stop, done := make(chan struct{}), make(chan struct{})
+	limits.Stop = stop
+	x.mu.Lock()
+	x.discard = false
+	x.mu.Unlock()
+	x.stop, x.done = stop, done
+	g := x.game.searchCopy()
+	post := x.post
+
+	go func() {
+		defer close(done)
+		result, err := x.engine.SearchWithInfo(g, limits, func(result SearchResult) {
+			if post {
+				x.thinking(g, result)
+			}
+		})
+
+		x.mu.Lock()
+		discard := x.discard
+		x.mu.Unlock()
+		if err != nil || discard {
+			return
+		}
+		x.send("move %s", uciMove(result.Move))
+		x.play(result.Move)
+	}()
+}
+
+// stopSearch stops the running search, if any, and waits for it to finish.
+// The move found so far is played unless discard is set.
+func (x *XBoard) stopSearch(discard bool) {
+	if x.stop == nil {
+		return
+	}
+	x.mu.Lock()
+	x.discard = discard
+	x.mu.Unlock()
+	close(x.stop)
+	<-x.done
+	x.stop, x.done = nil, nil
+}
+
+// thinking reports a finished depth of the search as "depth score time
+// nodes line", with the time in centiseconds and the line in SAN.
+func (x *XBoard) thinking(root *Game, result SearchResult) {
+	score := result.Score
+	if result.IsMate() {
+		// Mates are given as 100000 plus the moves to mate
+		score = 100000 + abs(result.MateIn())
+		if result.Score < 0 {
+			score = -score
+		}
+	}
+	x.send("%d %d %d %d %s", result.Depth, score, result.Time.Milliseconds()/10, result.Nodes,
+		strings.Join(root.sanLine(result.PV), " "))
+}
@@ -0,0 +1,163 @@
+package gochess
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// xboardSession runs the CECP loop in the background, like uciSession.
+func xboardSession(t *testing.T) (send func(string), expect func(prefix string) []string) {
+	t.Helper()
+	return protocolSession(t, func(in io.Reader, out io.Writer) {
+		NewXBoard(&Engine{Evaluate: Evaluate, TT: NewTranspositionTable(1)}, out).Run(in)
+	})
+}
+
+// lastMove returns the move sent by the engine in the lines.
+func lastMove(t *testing.T, lines []string) string {
+	t.Helper()
+	last := lines[len(lines)-1]
+	if !strings.HasPrefix(last, "move ") {
+		t.Fatalf("Expected a move, got %v", lines)
+	}
+	return strings.TrimPrefix(last, "move ")
+}
+
+func TestXBoardHandshake(t *testing.T) {
+	send, expect := xboardSession(t)
+
+	send("xboard")
+	send("protover 2")
+	lines := expect("feature")
+	for _, feature := range []string{"usermove=1", "setboard=1", "ping=1", "done=1"} {
+		if !strings.Contains(lines[0], feature) {
+			t.Errorf("Expected %s in %q", feature, lines[0])
+		}
+	}
+
+	send("ping 7")
+	expect("pong 7")
+	send("frobnicate")
+	if lines := expect("Error"); lines[0] != "Error (unknown command): frobnicate" {
+		t.Errorf("Expected the unknown command to be reported, got %v", lines)
+	}
+}
+
+func TestXBoardGame(t *testing.T) {
+	send, expect := xboardSession(t)
+
+	// The engine plays Black after new and replies to each move
+	send("new")
+	send("sd 2")
+	send("usermove e2e4")
+	reply := lastMove(t, expect("move"))
+	g := NewGame("", "")
+	playMoves(t, g, "e2e4", reply)
+
+	// Moves can be given in SAN too
+	send("usermove Nf3")
+	reply = lastMove(t, expect("move"))
+	playMoves(t, g, "g1f3", reply)
+
+	send("usermove e2e5")
+	if lines := expect("Illegal move"); lines[0] != "Illegal move: e2e5" {
+		t.Errorf("Expected the illegal move to be rejected, got %v", lines)
+	}
+
+	// In force mode moves are only recorded, until go hands the engine the
+	// side on turn
+	send("force")
+	send("usermove d2d4")
+	send("ping 1")
+	if lines := expect("pong 1"); len(lines) != 1 {
+		t.Errorf("Expected no reply in force mode, got %v", lines)
+	}
+	send("go")
+	reply = lastMove(t, expect("move"))
+	playMoves(t, g, "d2d4", reply)
+
+	send("result 1/2-1/2 {Agreed}")
+	send("usermove Bc4")
+	send("ping 2")
+	if lines := expect("pong 2"); len(lines) != 1 {
+		t.Errorf("Expected no reply after the result, got %v", lines)
+	}
+}
+
+func TestXBoardMate(t *testing.T) {
+	send, expect := xboardSession(t)
+
+	send("new")
+	send("setboard 6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1")
+	send("post")
+	send("sd 3")
+	send("go")
+	lines := expect("1-0")
+	if got := lines[len(lines)-2]; got != "move a1a8" {
+		t.Errorf("Expected mate on the back rank, got %v", lines)
+	}
+	if got := lines[len(lines)-1]; got != "1-0 {White mates}" {
+		t.Errorf("Expected the result, got %q", got)
+	}
+	// Thinking is reported as depth, score, centiseconds, nodes and the line
+	fields := strings.Fields(lines[len(lines)-3])
+	if len(fields) != 5 || fields[1] != "100001" || fields[4] != "Ra8#" {
+		t.Errorf("Expected mate in 1 in the thinking output, got %q", lines[len(lines)-3])
+	}
+
+	send("setboard 8/8/8/8/8/8/8/8 w - - 0 1")
+	if lines := expect("tellusererror"); !strings.HasPrefix(lines[0], "tellusererror Illegal position") {
+		t.Errorf("Expected the position to be rejected, got %v", lines)
+	}
+}
+
+func TestXBoardMoveNow(t *testing.T) {
+	send, expect := xboardSession(t)
+
+	send("new")
+	send("st 1000")
+	send("go")
+	send("?")
+	move := lastMove(t, expect("move"))
+	if _, err := parseUCIMove(NewGame("", ""), move); err != nil {
+		t.Errorf("Expected a legal move, got %v", err)
+	}
+}
+
+func TestXBoardLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands []string
+		want     SearchLimits
+	}{
+		{"Nothing set", nil, SearchLimits{Depth: DefaultSearchDepth}},
+		{"Depth", []string{"sd 4"}, SearchLimits{Depth: 4}},
+		{"Time per move", []string{"st 5"}, SearchLimits{Time: 5 * time.Second}},
+		{"Sudden death", []string{"level 0 2 0"}, SearchLimits{Time: 4 * time.Second}},
+		{"Clock", []string{"level 0 2 0", "time 3000"}, SearchLimits{Time: time.Second}},
+		{"Increment", []string{"level 0 1:30 2.5", "time 9000"}, SearchLimits{Time: 4875 * time.Millisecond}},
+		{"Moves per session", []string{"level 40 5 0", "time 6000"}, SearchLimits{Time: 1500 * time.Millisecond}},
+		{"Level after st", []string{"st 5", "level 0 2 0"}, SearchLimits{Time: 4 * time.Second}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x := NewXBoard(NewEngine(), io.Discard)
+			for _, command := range tt.commands {
+				x.Handle(command)
+			}
+			if got := x.limits(); got != tt.want {
+				t.Errorf("limits() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	var out strings.Builder
+	x := NewXBoard(NewEngine(), &out)
+	x.Handle("level 40 five 0")
+	if !strings.Contains(out.String(), "Error (invalid base time") {
+		t.Errorf("Expected the level to be rejected, got %q", out.String())
+	}
+}
@@ -0,0 +1,105 @@
+package gochess
+
+// zobristKeys are the random numbers the Zobrist hash of a position is made
+// of: one for each piece on each square, one for Black to move, one for each
+// combination of castling rights and one for each en passant file.
+type zobristKeys struct {
+	pieces      [12][64]uint64
+	blackToMove uint64
+	castling    [16]uint64
+	enPassant   [8]uint64
+}
+
+var zobrist = newZobristKeys(0x9e3779b97f4a7c15)
+
+// newZobristKeys draws the keys with splitmix64, so hashes are the same
+// from one run to the next.
+func newZobristKeys(seed uint64) *zobristKeys {
+	next := func() uint64 {
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		return z ^ (z >> 31)
+	}
+
+	keys := &zobristKeys{}
+	for piece := range keys.pieces {
+		for square := range keys.pieces[piece] {
+			keys.pieces[piece][square] = next()
+		}
+	}
+	keys.blackToMove = next()
+	// No castling rights hash to zero, like a position set up without them
+	for rights := 1; rights < len(keys.castling); rights++ {
+		keys.castling[rights] = next()
+	}
+	for file := range keys.enPassant {
+		keys.enPassant[file] = next()
+	}
+	return keys
+}
+
+var zobristPieceIndex = map[PieceType]int{Pawn: 0, Knight: 1, Bishop: 2, Rook: 3, Queen: 4, King: 5}
+
+// pieceKey returns the key of a piece on a square, zero for an empty square.
+func (k *zobristKeys) pieceKey(piece *Piece, x, y int) uint64 {
+	if piece == nil {
+		return 0
+	}
+	index := zobristPieceIndex[piece.Type]
+	if piece.Color == Black {
+		index += 6
+	}
+	return k.pieces[index][x*8+y]
+}
+
+// Hash returns the Zobrist hash of the position: the pieces, the player on
+// turn, the castling rights and the en passant file. Positions that are the
+// same for the rules hash the same, however they were reached.
+func (g *Game) Hash() uint64 {
+	if !g.hashed {
+		g.hash = g.computeHash()
+		g.hashed = true
+	}
+	return g.hash
+}
+
+// computeHash hashes the position from scratch.
+func (g *Game) computeHash() uint64 {
+	var hash uint64
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 8; y++ {
+			hash ^= zobrist.pieceKey(g.Board[x][y], x, y)
+		}
+	}
+	if g.GetCurrentPlayerColor() == Black {
+		hash ^= zobrist.blackToMove
+	}
+	hash ^= zobrist.castling[g.Castling]
+	if g.EnPassant != nil {
+		hash ^= zobrist.enPassant[g.EnPassant.X]
+	}
+	return hash
+}
+
+// setSquare puts a piece on a square, or empties it, and updates the hash.
+func (g *Game) setSquare(x, y int, piece *Piece) {
+	g.hash ^= zobrist.pieceKey(g.Board[x][y], x, y) ^ zobrist.pieceKey(piece, x, y)
+	g.Board[x][y] = piece
+}
+
+func (g *Game) setCastling(rights CastlingRights) {
+	g.hash ^= zobrist.castling[g.Castling] ^ zobrist.castling[rights]
+	g.Castling = rights
+}
+
+func (g *Game) setEnPassant(square *Position) {
+	if g.EnPassant != nil {
+		g.hash ^= zobrist.enPassant[g.EnPassant.X]
+	}
+	if square != nil {
+		g.hash ^= zobrist.enPassant[square.X]
+	}
+	g.EnPassant = square
+}
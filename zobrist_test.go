@@ -0,0 +1,109 @@
+package gochess
+
+import "testing"
+
+func TestHashIncremental(t *testing.T) {
+	g := NewGame("alice", "bob")
+	start := g.Hash()
+
+	// Captures, en passant, both castlings and a promotion
+	moves := []string{
+		"e2e4", "d7d5", "e4d5", "g8f6", "g1f3", "e7e5", "d5e6", "f8c5", "f1e2", "e8g8",
+		"e1g1", "b8c6", "e6f7", "g8h8", "d2d4", "c5b4", "c1g5", "d8d6", "b1c3", "c8d7",
+		"a2a3", "f8e8",
+	}
+	for i, move := range moves {
+		playMoves(t, g, move)
+		if got, want := g.Hash(), g.computeHash(); got != want {
+			t.Fatalf("Hash() after %v = %x, want %x", moves[:i+1], got, want)
+		}
+	}
+
+	playMoves(t, g, "f7e8")
+	if err := g.PromotePawn(Queen); err != nil {
+		t.Fatalf("PromotePawn() error = %v", err)
+	}
+	if got, want := g.Hash(), g.computeHash(); got != want {
+		t.Fatalf("Hash() after promoting = %x, want %x", got, want)
+	}
+
+	for len(g.History) > 0 {
+		if err := g.Undo(); err != nil {
+			t.Fatalf("Undo() error = %v", err)
+		}
+		if got, want := g.Hash(), g.computeHash(); got != want {
+			t.Fatalf("Hash() after undoing to %d moves = %x, want %x", len(g.History), got, want)
+		}
+	}
+	if g.Hash() != start {
+		t.Errorf("Hash() after undoing every move = %x, want the starting hash %x", g.Hash(), start)
+	}
+}
+
+func TestHashTranspositions(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		same bool
+	}{
+		{
+			name: "Knights out and back",
+			a:    []string{"g1f3", "g8f6", "f3g1", "f6g8"},
+			b:    []string{},
+			same: true,
+		},
+		{
+			name: "Move order",
+			a:    []string{"e2e4", "e7e5", "g1f3"},
+			b:    []string{"g1f3", "e7e5", "e2e4"},
+			same: true,
+		},
+		{
+			name: "Side to move",
+			a:    []string{"a2a4", "a7a5", "a1a3", "b8c6", "a3a2", "c6b8", "a2a1"},
+			b:    []string{"a2a4", "a7a5"},
+			same: false,
+		},
+		{
+			name: "Castling rights",
+			a:    []string{"e2e4", "e7e5", "e1e2", "e8e7", "e2e1", "e7e8"},
+			b:    []string{"e2e4", "e7e5"},
+			same: false,
+		},
+		{
+			name: "En passant",
+			a:    []string{"e2e4", "g8f6", "e4e5", "d7d5"},
+			b:    []string{"e2e4", "g8f6", "e4e5", "d7d5", "g1f3", "f6g8", "f3g1", "g8f6"},
+			same: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := NewGame("alice", "bob"), NewGame("alice", "bob")
+			playMoves(t, a, tt.a...)
+			playMoves(t, b, tt.b...)
+			if same := a.Hash() == b.Hash(); same != tt.same {
+				t.Errorf("Hashes equal = %v, want %v", same, tt.same)
+			}
+		})
+	}
+}
+
+func TestEnPassantSquare(t *testing.T) {
+	g := NewGame("alice", "bob")
+	playMoves(t, g, "e2e4")
+	if g.EnPassant != nil {
+		t.Errorf("EnPassant = %v, want none without a pawn to take", g.EnPassant)
+	}
+
+	playMoves(t, g, "g8f6", "e4e5", "d7d5")
+	if g.EnPassant == nil || *g.EnPassant != (Position{X: 3, Y: 5}) {
+		t.Errorf("EnPassant = %v, want d6", g.EnPassant)
+	}
+
+	playMoves(t, g, "g1f3")
+	if g.EnPassant != nil {
+		t.Errorf("EnPassant = %v, want none a move later", g.EnPassant)
+	}
+}